@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Limits for the number of /abstract sentences.
+const (
+	defaultAbstractSentences = 3
+	maxAbstractSentences     = 50
+)
+
+// AbstractResponse is the JSON body returned by /abstract. Sentences is the
+// number of sentences in Abstract, fewer than Requested for short articles.
+type AbstractResponse struct {
+	Topic     string `json:"topic"`
+	Title     string `json:"title"`
+	Lang      string `json:"lang"`
+	Abstract  string `json:"abstract"`
+	Sentences int    `json:"sentences"`
+	Requested int    `json:"requested"`
+}
+
+// abstractTailSections are the headings of the reference and navigation
+// sections that close an article; their text isn't prose.
+var abstractTailSections = map[string]bool{
+	"see also": true, "notes": true, "references": true, "citations": true, "sources": true,
+	"bibliography": true, "further reading": true, "external links": true,
+}
+
+// abstractHandler returns the first "sentences" sentences of an article's
+// body (default 3, max 50), read across the lead and the following sections
+// rather than stopping at the lead like /lookup's sentences parameter.
+func abstractHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	n, ok := intRangeParam(w, r, "sentences", defaultAbstractSentences, 1, maxAbstractSentences)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, content, err := wikiClient.Content(r.Context(), topic, lang)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "abstract lookup")
+		return
+	}
+
+	abstract, count := firstSentences(articleProse(content), n)
+	if abstract == "" {
+		writeError(w, r, http.StatusNotFound, "NO_SUMMARY", fmt.Sprintf("no text available for %q", title))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, AbstractResponse{Topic: topic, Title: title, Lang: lang, Abstract: abstract, Sentences: count, Requested: n})
+}
+
+// articleProse joins the paragraphs of plain-text page content into one
+// running text, dropping section headings and stopping at the first of
+// abstractTailSections.
+func articleProse(content string) string {
+	var paragraphs []string
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if headingPattern.MatchString(line) {
+			if abstractTailSections[strings.ToLower(strings.TrimSpace(strings.Trim(line, "=")))] {
+				break
+			}
+			continue
+		}
+		if line != "" {
+			paragraphs = append(paragraphs, line)
+		}
+	}
+	return strings.Join(paragraphs, " ")
+}
+
+// firstSentences returns the first n sentences of s and how many it
+// returned: all of s, counted, when it has fewer than n.
+func firstSentences(s string, n int) (string, int) {
+	s = strings.TrimSpace(s)
+	ends := sentenceEnds(s)
+	if len(ends) >= n {
+		return strings.TrimSpace(s[:ends[n-1]]), n
+	}
+	// Text after the last sentence end, such as a final sentence without
+	// punctuation, counts as one more
+	count, last := len(ends), 0
+	if count > 0 {
+		last = ends[count-1]
+	}
+	if strings.TrimSpace(s[last:]) != "" {
+		count++
+	}
+	return s, count
+}
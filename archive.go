@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+// defaultArchiveAPIURL is the Wayback Machine's availability API, which
+// names the snapshot of a URL closest to a date, or the latest one.
+const defaultArchiveAPIURL = "https://archive.org/wayback/available"
+
+// maxArchivedPageBytes caps how much of an archived page is read. Lead
+// sections come early, so even a cut page yields a summary.
+const maxArchivedPageBytes = 4 << 20
+
+// ErrNotArchived is returned when the archive holds no usable snapshot of
+// a page.
+var ErrNotArchived = errors.New("no archived snapshot")
+
+// ArchiveSnapshot identifies the archived copy a summary was read from.
+type ArchiveSnapshot struct {
+	URL  string
+	Time time.Time
+}
+
+// upstreamDown reports whether err means Wikipedia couldn't be reached or
+// failed on its side: an open circuit breaker, a timeout, a network error,
+// Wikipedia rate limiting us or a 5xx or 429 status. Missing pages, API
+// errors and our own rate limit don't count.
+func upstreamDown(err error) bool {
+	var (
+		open    *CircuitOpenError
+		limited *RateLimitError
+		status  *UpstreamStatusError
+		netErr  *url.Error
+	)
+	switch {
+	case errors.As(err, &open), isTimeout(err), errors.As(err, &netErr):
+		return true
+	case errors.As(err, &limited):
+		return limited.Upstream
+	case errors.As(err, &status):
+		return status.StatusCode >= 500 || status.StatusCode == http.StatusTooManyRequests
+	}
+	return false
+}
+
+// fetchArchivedSummary reads the summary of topic from the latest
+// snapshot of its article in the Wayback Machine (ARCHIVE_API_URL), for
+// /lookup to fall back on when Wikipedia is down. The snapshot is looked up
+// by the topic's article URL, so a topic that is only a redirect finds the
+// redirect's snapshot, if any. Summaries from the archive may be stale and
+// are never cached.
+func fetchArchivedSummary(ctx context.Context, topic, lang string) (PageSummary, ArchiveSnapshot, error) {
+	ctx, cancel := context.WithTimeout(ctx, currentConfig().WikiTimeout)
+	defer cancel()
+
+	live := projectArticleURL(topic, lang, projectFrom(ctx))
+	var res struct {
+		ArchivedSnapshots struct {
+			Closest *struct {
+				Available bool   `json:"available"`
+				URL       string `json:"url"`
+				Timestamp string `json:"timestamp"`
+				Status    string `json:"status"`
+			} `json:"closest"`
+		} `json:"archived_snapshots"`
+	}
+	if err := archiveGet(ctx, currentConfig().ArchiveAPIURL+"?url="+url.QueryEscape(live), func(body io.Reader) error {
+		return json.NewDecoder(body).Decode(&res)
+	}); err != nil {
+		return PageSummary{}, ArchiveSnapshot{}, err
+	}
+	closest := res.ArchivedSnapshots.Closest
+	if closest == nil || !closest.Available || closest.Status != "200" {
+		return PageSummary{}, ArchiveSnapshot{}, fmt.Errorf("%w of %s", ErrNotArchived, live)
+	}
+	taken, err := time.Parse("20060102150405", closest.Timestamp)
+	if err != nil {
+		return PageSummary{}, ArchiveSnapshot{}, fmt.Errorf("archived snapshot timestamp %q: %w", closest.Timestamp, err)
+	}
+
+	// The "id_" flag serves the page as archived, without the Wayback
+	// Machine's banner and rewritten links
+	raw := strings.Replace(closest.URL, "/"+closest.Timestamp+"/", "/"+closest.Timestamp+"id_/", 1)
+	var title, summary string
+	if err := archiveGet(ctx, raw, func(body io.Reader) error {
+		doc, err := html.Parse(io.LimitReader(body, maxArchivedPageBytes))
+		if err != nil {
+			return err
+		}
+		title, summary = archivedLead(doc)
+		return nil
+	}); err != nil {
+		return PageSummary{}, ArchiveSnapshot{}, err
+	}
+	if summary == "" {
+		return PageSummary{}, ArchiveSnapshot{}, fmt.Errorf("%w of %s with a lead section", ErrNotArchived, live)
+	}
+	if title == "" {
+		title = topic
+	}
+	result := PageSummary{Title: title, Summary: summary, URL: projectArticleURL(title, lang, projectFrom(ctx))}
+	return result, ArchiveSnapshot{URL: closest.URL, Time: taken.UTC()}, nil
+}
+
+// archiveGet fetches rawURL from the archive and passes the body to read.
+// Archive calls go through the shared upstream client but not the circuit
+// breaker or the retries, which are Wikipedia's.
+func archiveGet(ctx context.Context, rawURL string, read func(io.Reader) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	start := time.Now()
+	res, err := upstreamClient.Do(req)
+	logger.Debug("archive request", "url", rawURL, "latency", time.Since(start), "error", err)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("archive returned %s for %s", res.Status, rawURL)
+	}
+	return read(res.Body)
+}
+
+// archivedLead extracts the title and the lead section of an archived
+// article: the text of the paragraphs before the first section heading,
+// one per line like an API extract, without reference markers.
+func archivedLead(doc *html.Node) (title, lead string) {
+	var content *html.Node
+	var find func(n *html.Node)
+	find = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch {
+			case n.Data == "h1" && attr(n, "id") == "firstHeading" && title == "":
+				title = nodeText(n)
+			case n.Data == "div" && hasClass(n, "mw-parser-output") && content == nil:
+				content = n
+				return
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			find(c)
+		}
+	}
+	find(doc)
+	if content == nil {
+		return title, ""
+	}
+
+	var paragraphs []string
+	for c := content.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type != html.ElementNode {
+			continue
+		}
+		if c.Data == "h2" || hasClass(c, "mw-heading") {
+			break
+		}
+		if c.Data == "p" && !hasClass(c, "mw-empty-elt") {
+			if text := nodeText(c); text != "" {
+				paragraphs = append(paragraphs, text)
+			}
+		}
+	}
+	return title, strings.Join(paragraphs, "\n")
+}
+
+// nodeText returns the text within n with whitespace collapsed, leaving
+// out reference markers, edit links, styles and scripts.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case n.Type != html.ElementNode:
+		case n.Data == "style", n.Data == "script":
+			return
+		case n.Data == "sup" && hasClass(n, "reference"), hasClass(n, "mw-editsection"):
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// attr returns the value of n's attribute key, or "".
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// hasClass reports whether n's class attribute lists class.
+func hasClass(n *html.Node, class string) bool {
+	return slices.Contains(strings.Fields(attr(n, "class")), class)
+}
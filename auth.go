@@ -0,0 +1,56 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"net/http"
+)
+
+// publicPaths are served without an API key: probes, metrics and the API
+// description.
+var publicPaths = map[string]bool{
+	"/health":       true,
+	"/livez":        true,
+	"/readyz":       true,
+	"/version":      true,
+	"/metrics":      true,
+	"/openapi.json": true,
+	"/docs":         true,
+}
+
+// withAPIKey rejects requests to non-public paths with 401 unless they carry
+// one of the configured API_KEYS in the X-API-Key header or the "api_key"
+// query parameter. Authentication is disabled while the list is empty.
+func withAPIKey(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := currentConfig().APIKeys
+		if len(keys) == 0 || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := r.Header.Get("X-API-Key")
+		if key == "" {
+			key = r.URL.Query().Get("api_key")
+		}
+		if !validAPIKey(keys, key) {
+			writeError(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "missing or invalid API key")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validAPIKey reports whether key is one of keys. Keys are compared as
+// hashes in constant time so response timing doesn't leak their contents.
+func validAPIKey(keys []string, key string) bool {
+	if key == "" {
+		return false
+	}
+	got := sha256.Sum256([]byte(key))
+	valid := 0
+	for _, k := range keys {
+		want := sha256.Sum256([]byte(k))
+		valid |= subtle.ConstantTimeCompare(got[:], want[:])
+	}
+	return valid == 1
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// BatchItem is one entry of the /batch response. Exactly one of Summary and
+// Error is set; Code is the error code /lookup would have answered with,
+// UPSTREAM_TIMEOUT for an item that exceeded BATCH_ITEM_TIMEOUT.
+type BatchItem struct {
+	Topic   string `json:"topic"`
+	Summary string `json:"summary,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"`
+}
+
+// maxBatchItemBytes is the body size a batch request may spend per entry:
+// a 255-byte title, the longest MediaWiki allows, even fully escaped, plus
+// its quotes and comma.
+const maxBatchItemBytes = 2048
+
+// readJSONList decodes the JSON array of strings posted as the body of a
+// batch request such as /batch. The body may take maxBatchItemBytes per
+// BATCH_MAX_SIZE entry, so an oversized one is cut off while it is read,
+// before it is held in memory, and gets a 413. A body that isn't such an
+// array, named by what, gets a 400. Both return false.
+func readJSONList(w http.ResponseWriter, r *http.Request, what string) ([]string, bool) {
+	limit := int64(currentConfig().BatchMaxSize) * maxBatchItemBytes
+	var list []string
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, limit)).Decode(&list); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeBodyError(w, r, err)
+		} else {
+			writeError(w, r, http.StatusBadRequest, "INVALID_BODY", "body must be a JSON array of "+what)
+		}
+		return nil, false
+	}
+	return list, true
+}
+
+// batchHandler looks up a JSON array of at most BATCH_MAX_SIZE topics
+// posted in the body and returns one BatchItem per topic, in input order.
+// Topics are fetched by a bounded pool of workers, each item within
+// BATCH_ITEM_TIMEOUT; a failed or slow topic is reported in its own item
+// without failing or holding up the batch.
+func batchHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	topics, ok := readJSONList(w, r, "topics")
+	if !ok {
+		return
+	}
+	if limit := currentConfig().BatchMaxSize; len(topics) > limit {
+		writeError(w, r, http.StatusBadRequest, "INVALID_BODY", fmt.Sprintf("batch holds %d topics, at most %d allowed", len(topics), limit))
+		return
+	}
+
+	// Feed the indexes to a fixed pool of workers; each writes only its own
+	// slot in results, so no further locking is needed.
+	results := make([]BatchItem, len(topics))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(currentConfig().BatchConcurrency, len(topics)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = lookupBatchItem(r, topics[i], lang)
+			}
+		}()
+	}
+	for i := range topics {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	writeJSON(w, r, http.StatusOK, results)
+}
+
+// lookupBatchItem fetches the summary for one batch topic, giving up after
+// BATCH_ITEM_TIMEOUT.
+func lookupBatchItem(r *http.Request, topic, lang string) BatchItem {
+	normalized := normalizeTopic(topic)
+	if normalized == "" {
+		return BatchItem{Topic: topic, Error: ErrTopicRequired.Error(), Code: "TOPIC_REQUIRED"}
+	}
+	if !topicAllowed(normalized) {
+		return BatchItem{Topic: topic, Error: ErrTopicBlocked.Error(), Code: "TOPIC_BLOCKED"}
+	}
+
+	timeout := currentConfig().BatchItemTimeout
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+	result, err := fetchWikipediaSummary(ctx, normalized, lang)
+	switch {
+	case err == nil:
+		return BatchItem{Topic: topic, Summary: result.Summary, URL: result.URL}
+	case clientGone(r.Context(), err):
+		logger.Info("batch lookup cancelled by client", "topic", normalized)
+		return BatchItem{Topic: topic, Error: err.Error()}
+	case ctx.Err() != nil && r.Context().Err() == nil:
+		logger.Warn("batch lookup timed out", "topic", normalized, "timeout", timeout)
+		return BatchItem{Topic: topic, Error: fmt.Sprintf("lookup exceeded the %s batch item timeout", timeout), Code: "UPSTREAM_TIMEOUT"}
+	}
+	logUpstreamError(err, normalized, "batch lookup")
+	_, code, _ := classifyUpstreamError(err, normalized, "batch lookup")
+	return BatchItem{Topic: topic, Error: err.Error(), Code: code}
+}
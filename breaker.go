@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// Circuit breaker states.
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half-open"
+)
+
+// CircuitOpenError is returned instead of calling Wikipedia while the
+// circuit breaker is open.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("wikipedia is failing, upstream calls suspended for %s", e.RetryAfter.Round(time.Second))
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds for the
+// Retry-After header.
+func (e *CircuitOpenError) RetryAfterSeconds() int {
+	return max(int(math.Ceil(e.RetryAfter.Seconds())), 1)
+}
+
+// BreakerStats is a snapshot of the circuit breaker, reported by /health.
+// OpenedAt is set while the breaker is open or half-open.
+type BreakerStats struct {
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	OpenedAt            *time.Time `json:"opened_at,omitempty"`
+}
+
+// circuitBreaker stops upstream calls while Wikipedia is broadly failing.
+// BREAKER_FAILURES consecutive failed calls open it, and calls fail fast
+// with a *CircuitOpenError for BREAKER_COOLDOWN. It then half-opens and
+// lets a single probe through: success closes it again, failure reopens it
+// for another cool-down. Both settings are read on every call, so a reload
+// applies at once; BREAKER_FAILURES=0 disables the breaker.
+type circuitBreaker struct {
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool // a half-open probe is in flight
+}
+
+// upstreamBreaker guards every call to Wikipedia.
+var upstreamBreaker = &circuitBreaker{state: breakerClosed}
+
+// Allow reports whether a call may go upstream now, returning a
+// *CircuitOpenError when it may not. A nil error obliges the caller to
+// pass the call's outcome to Done.
+func (b *circuitBreaker) Allow() error {
+	cfg := currentConfig()
+	if cfg.BreakerFailures <= 0 {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerOpen:
+		wait := cfg.BreakerCooldown - time.Since(b.openedAt)
+		if wait > 0 {
+			return &CircuitOpenError{RetryAfter: wait}
+		}
+		b.state = breakerHalfOpen
+		logger.Info("circuit breaker half-open, probing wikipedia")
+		fallthrough
+	case breakerHalfOpen:
+		if b.probing {
+			return &CircuitOpenError{RetryAfter: time.Second}
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Done records the outcome of a call Allow let through. Only failures that
+// point at Wikipedia itself count against it; missing pages and client
+// cancellations don't, and rate limiting, ours or Wikipedia's, is backed
+// off from by the limiter instead.
+func (b *circuitBreaker) Done(err error) {
+	cfg := currentConfig()
+	if cfg.BreakerFailures <= 0 {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	probe := b.state == breakerHalfOpen && b.probing
+	b.probing = false
+
+	var limited *RateLimitError
+	switch {
+	case errors.As(err, &limited):
+		return // no verdict: Wikipedia answered, it just asked us to slow down
+	case err == nil || !upstreamFailure(err):
+		if errors.Is(err, context.Canceled) {
+			return // no verdict: a cancelled probe lets the next call probe
+		}
+		if probe {
+			logger.Info("circuit breaker closed, wikipedia recovered")
+		}
+		b.state, b.failures = breakerClosed, 0
+	case probe:
+		b.state, b.openedAt = breakerOpen, time.Now()
+		logger.Warn("circuit breaker reopened, probe failed", "error", err, "cooldown", cfg.BreakerCooldown)
+	default:
+		b.failures++
+		if b.state == breakerClosed && b.failures >= cfg.BreakerFailures {
+			b.state, b.openedAt = breakerOpen, time.Now()
+			logger.Warn("circuit breaker opened", "consecutive_failures", b.failures, "error", err, "cooldown", cfg.BreakerCooldown)
+		}
+	}
+}
+
+// Stats returns the breaker's state. An open breaker whose cool-down has
+// passed reports half-open, as the next call will probe.
+func (b *circuitBreaker) Stats() BreakerStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := BreakerStats{State: b.state, ConsecutiveFailures: b.failures}
+	if b.state != breakerClosed {
+		openedAt := b.openedAt
+		stats.OpenedAt = &openedAt
+		if b.state == breakerOpen && time.Since(b.openedAt) >= currentConfig().BreakerCooldown {
+			stats.State = breakerHalfOpen
+		}
+	}
+	return stats
+}
+
+// upstreamFailure reports whether err, returned by an upstream call, means
+// Wikipedia is failing: transient errors that outlasted the retries, and
+// timeouts.
+func upstreamFailure(err error) bool {
+	return isTimeout(err) || isTransient(err)
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// qidPattern matches a Wikidata item ID such as Q42.
+var qidPattern = regexp.MustCompile(`^Q[1-9][0-9]*$`)
+
+// QIDSummary is one item's entry in a /by-qid response. Exactly one of
+// Summary and Error is set. Code is the error code /lookup would have
+// answered with, NO_ENTITY for an unknown item and NO_SITELINK for one
+// without an article in the requested edition.
+type QIDSummary struct {
+	Title   string `json:"title,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Code    string `json:"code,omitempty"`
+}
+
+// QIDSummariesResponse is the JSON body returned by /by-qid. Summaries is
+// keyed by item ID.
+type QIDSummariesResponse struct {
+	Lang      string                `json:"lang"`
+	Summaries map[string]QIDSummary `json:"summaries"`
+}
+
+// byQIDHandler returns the summaries of the lang edition's articles about
+// a list of Wikidata items, given as "qids" (comma-separated) or, in a
+// POST, as a JSON array body. The items' sitelinks give the titles, and
+// the summaries are fetched like /batch's topics: by BATCH_CONCURRENCY
+// workers, each within BATCH_ITEM_TIMEOUT, with failures reported per item.
+func byQIDHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	ids, ok := requestQIDs(w, r)
+	if !ok {
+		return
+	}
+
+	titles, err := wikiClient.Sitelinks(r.Context(), ids, lang)
+	if err != nil {
+		writeUpstreamError(w, r, err, strings.Join(ids, ","), "wikidata lookup")
+		return
+	}
+
+	resp := QIDSummariesResponse{Lang: lang, Summaries: make(map[string]QIDSummary, len(ids))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	jobs := make(chan string)
+	for range min(currentConfig().BatchConcurrency, len(ids)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for id := range jobs {
+				item := lookupQID(r, id, titles, lang)
+				mu.Lock()
+				resp.Summaries[id] = item
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, id := range ids {
+		jobs <- id
+	}
+	close(jobs)
+	wg.Wait()
+
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// lookupQID fetches the summary for one item of a /by-qid request, given
+// the titles Sitelinks found.
+func lookupQID(r *http.Request, id string, titles map[string]string, lang string) QIDSummary {
+	title, ok := titles[id]
+	switch {
+	case !ok:
+		return QIDSummary{Error: fmt.Sprintf("no Wikidata item %s", id), Code: "NO_ENTITY"}
+	case title == "":
+		return QIDSummary{Error: fmt.Sprintf("%s has no article on the %s Wikipedia", id, lang), Code: "NO_SITELINK"}
+	}
+	item := lookupBatchItem(r, title, lang)
+	return QIDSummary{Title: title, Summary: item.Summary, URL: item.URL, Error: item.Error, Code: item.Code}
+}
+
+// requestQIDs returns the distinct item IDs of the request, in order: the
+// JSON array body of a POST, otherwise the comma-separated "qids" query
+// parameter. IDs are case-insensitive. When they are missing, malformed or
+// more than BATCH_MAX_SIZE it writes a 400 response, and when the body is
+// larger than /batch allows, a 413, returning false.
+func requestQIDs(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	var raw []string
+	if r.Method == http.MethodPost {
+		var ok bool
+		if raw, ok = readJSONList(w, r, "Wikidata item IDs"); !ok {
+			return nil, false
+		}
+	} else {
+		raw = strings.Split(r.URL.Query().Get("qids"), ",")
+	}
+
+	var ids []string
+	seen := make(map[string]bool)
+	for _, id := range raw {
+		id = strings.ToUpper(strings.TrimSpace(id))
+		if id == "" || seen[id] {
+			continue
+		}
+		if !qidPattern.MatchString(id) {
+			paramError{name: "qids", msg: fmt.Sprintf("%q is not a Wikidata item ID such as Q42", id)}.write(w, r)
+			return nil, false
+		}
+		seen[id] = true
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		paramError{name: "qids", msg: "qids is required, e.g. qids=Q1,Q42"}.write(w, r)
+		return nil, false
+	}
+	if limit := currentConfig().BatchMaxSize; len(ids) > limit {
+		paramError{name: "qids", msg: fmt.Sprintf("%d item IDs given, at most %d allowed", len(ids), limit)}.write(w, r)
+		return nil, false
+	}
+	return ids, true
+}
@@ -0,0 +1,432 @@
+package main
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// summaryCache holds recently fetched summaries. It is nil when caching is
+// disabled (CACHE_SIZE=0).
+var summaryCache summaryStore
+
+// summaryStore is a cache backend for summaries: the in-memory lruCache or,
+// with CACHE_BACKEND=redis, a redisCache shared by every replica. Entries
+// expire after the TTL in force when they were stored, negative ones after
+// the negative TTL. Expired summaries with a known revision are kept for
+// the stale TTL longer, so they can be revalidated instead of refetched.
+type summaryStore interface {
+	// Get returns the cached summary for key, if present and not expired.
+	// negative reports that the entry records a missing page instead.
+	Get(key cacheKey) (summary PageSummary, negative, ok bool)
+	// Stale returns the expired summary for key while it is kept for
+	// revalidation. It doesn't count as a hit or a miss.
+	Stale(key cacheKey) (summary PageSummary, ok bool)
+	// Put stores summary under key.
+	Put(key cacheKey, summary PageSummary)
+	// PutNegative records that the page for key doesn't exist, unless
+	// negative caching is disabled.
+	PutNegative(key cacheKey)
+	// SetTTL changes the lifetimes given to entries stored from now on.
+	SetTTL(ttl, negativeTTL, staleTTL time.Duration)
+	// Stats returns the hit/miss counters and occupancy.
+	Stats() CacheStats
+	// Check verifies the backend works with a put/get round-trip.
+	Check() error
+	// Entries lists the live entries, sorted by key.
+	Entries() ([]CacheEntryInfo, error)
+	// Delete removes the entries for keys and returns how many there were.
+	Delete(keys ...cacheKey) (int, error)
+	// Purge removes every entry and returns how many there were.
+	Purge() (int, error)
+}
+
+// cacheKey identifies a cached summary: by topic, or by pageID for pages
+// requested by ID. rest marks summaries from the REST API, and project is
+// the Wikimedia project the page belongs to.
+//
+// The cache holds summaries as Wikipedia returned them, never responses:
+// options that only shape a response, such as sentences, chars, format,
+// fields or callback, are applied to the cached summary on every request,
+// so they have no place in the key and one entry serves every variant.
+// Options that change what is fetched either have a field here, like
+// source, or bypass the cache, like mode=lead and the archive fallback.
+type cacheKey struct {
+	topic   string
+	pageID  int
+	lang    string
+	project string
+	rest    bool
+}
+
+// String describes the page the key refers to, for error messages.
+func (k cacheKey) String() string {
+	if k.topic == "" {
+		return fmt.Sprintf("page id %d", k.pageID)
+	}
+	return strconv.Quote(k.topic)
+}
+
+// ID identifies the key in /admin/cache: the language, followed by "." and
+// the project for projects other than Wikipedia, then "t:" and the topic
+// or "id:" and the page ID, prefixed with "rest:" for REST summaries, e.g.
+// "en:t:Go", "de:rest:id:42" or "en.wiktionary:t:go".
+func (k cacheKey) ID() string {
+	id := "t:" + k.topic
+	if k.topic == "" {
+		id = "id:" + strconv.Itoa(k.pageID)
+	}
+	if k.rest {
+		id = "rest:" + id
+	}
+	return k.edition() + ":" + id
+}
+
+// edition names the wiki the key's page belongs to: its language, followed
+// by "." and the project for projects other than Wikipedia, e.g. "en" or
+// "en.wiktionary".
+func (k cacheKey) edition() string {
+	if k.project != defaultProject {
+		return k.lang + "." + k.project
+	}
+	return k.lang
+}
+
+// parseCacheKeyID is the inverse of cacheKey.ID.
+func parseCacheKeyID(id string) (cacheKey, error) {
+	edition, rest, _ := strings.Cut(id, ":")
+	lang, project, found := strings.Cut(edition, ".")
+	if !isSupportedLanguage(lang) {
+		return cacheKey{}, fmt.Errorf("invalid cache key %q: unsupported language %q", id, lang)
+	}
+	if !found {
+		project = defaultProject
+	} else if !slices.Contains(wikiProjects, project) {
+		return cacheKey{}, fmt.Errorf("invalid cache key %q: unsupported project %q", id, project)
+	}
+	key := cacheKey{lang: lang, project: project}
+	rest, key.rest = strings.CutPrefix(rest, "rest:")
+	if topic, ok := strings.CutPrefix(rest, "t:"); ok && topic != "" {
+		key.topic = topic
+		return key, nil
+	}
+	if n, err := strconv.Atoi(strings.TrimPrefix(rest, "id:")); strings.HasPrefix(rest, "id:") && err == nil && n > 0 {
+		key.pageID = n
+		return key, nil
+	}
+	return cacheKey{}, fmt.Errorf("invalid cache key %q; want e.g. en:t:Go or en:id:42, with rest: after the language for REST summaries", id)
+}
+
+// CacheEntryInfo describes a cached entry for /admin/cache. AgeMS is
+// missing for Redis entries stored by versions that didn't record it.
+type CacheEntryInfo struct {
+	Key      string   `json:"key"`
+	Negative bool     `json:"negative,omitempty"`
+	AgeMS    *float64 `json:"age_ms,omitempty"`
+	TTLMS    float64  `json:"ttl_ms"`
+}
+
+// cacheEntry is the value stored in each list element. A negative entry
+// records that the page doesn't exist and carries no summary.
+type cacheEntry struct {
+	key      cacheKey
+	summary  PageSummary
+	negative bool
+	stored   time.Time
+	expires  time.Time
+}
+
+// CacheStats is a snapshot of the cache counters, reported by /health.
+// Capacity is 0 for the Redis backend, whose memory limit Redis enforces.
+// Langs breaks the counters down by edition, keyed like cache key IDs ("en",
+// "en.wiktionary"); editions never looked up are left out.
+type CacheStats struct {
+	Backend  string                    `json:"backend"`
+	Hits     uint64                    `json:"hits"`
+	Misses   uint64                    `json:"misses"`
+	Size     int                       `json:"size"`
+	Capacity int                       `json:"capacity"`
+	Langs    map[string]LangCacheStats `json:"langs,omitempty"`
+}
+
+// LangCacheStats are the cache counters of one edition. HitRate is the
+// share of lookups served from the cache, from 0 to 1.
+type LangCacheStats struct {
+	Hits    uint64  `json:"hits"`
+	Misses  uint64  `json:"misses"`
+	HitRate float64 `json:"hit_rate"`
+}
+
+// cacheCounters tallies cache hits and misses, overall and per edition, for
+// both backends. It is safe for concurrent use.
+type cacheCounters struct {
+	mu     sync.Mutex
+	hits   uint64
+	misses uint64
+	langs  map[string]*LangCacheStats
+}
+
+// record counts a lookup of key as a hit or a miss.
+func (c *cacheCounters) record(key cacheKey, hit bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.langs == nil {
+		c.langs = make(map[string]*LangCacheStats)
+	}
+	edition := key.edition()
+	lang, ok := c.langs[edition]
+	if !ok {
+		lang = &LangCacheStats{}
+		c.langs[edition] = lang
+	}
+	if hit {
+		c.hits++
+		lang.Hits++
+	} else {
+		c.misses++
+		lang.Misses++
+	}
+}
+
+// fill copies the counters into stats.
+func (c *cacheCounters) fill(stats *CacheStats) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats.Hits, stats.Misses = c.hits, c.misses
+	if len(c.langs) == 0 {
+		return
+	}
+	stats.Langs = make(map[string]LangCacheStats, len(c.langs))
+	for edition, lang := range c.langs {
+		s := *lang
+		s.HitRate = math.Round(float64(s.Hits)/float64(s.Hits+s.Misses)*1000) / 1000
+		stats.Langs[edition] = s
+	}
+}
+
+// lruCache is a bounded, concurrency-safe LRU cache whose entries expire
+// after a fixed TTL. Negative entries use their own, usually shorter, TTL so
+// pages created after a failed lookup show up soon.
+type lruCache struct {
+	mu          sync.Mutex
+	capacity    int
+	ttl         time.Duration
+	negativeTTL time.Duration // 0 disables negative caching
+	staleTTL    time.Duration // 0 disables revalidation
+	order       *list.List    // front = most recently used
+	items       map[cacheKey]*list.Element
+	counters    cacheCounters
+}
+
+// newLRUCache returns a cache holding at most capacity entries, summaries for
+// ttl each and negative results for negativeTTL, and expired summaries for
+// staleTTL more while they can be revalidated.
+func newLRUCache(capacity int, ttl, negativeTTL, staleTTL time.Duration) *lruCache {
+	return &lruCache{
+		capacity:    capacity,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		staleTTL:    staleTTL,
+		order:       list.New(),
+		items:       make(map[cacheKey]*list.Element, capacity),
+	}
+}
+
+// Get returns the cached summary for key, if present and not expired.
+// negative reports that the entry records a missing page instead.
+func (c *lruCache) Get(key cacheKey) (summary PageSummary, negative, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		c.counters.record(key, false)
+		return PageSummary{}, false, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if now := time.Now(); now.After(entry.expires) {
+		if !c.revalidatable(entry, now) {
+			c.removeElement(el)
+		}
+		c.counters.record(key, false)
+		return PageSummary{}, false, false
+	}
+	c.order.MoveToFront(el)
+	c.counters.record(key, true)
+	return entry.summary, entry.negative, true
+}
+
+// Stale returns the expired summary for key while it is kept for
+// revalidation.
+func (c *lruCache) Stale(key cacheKey) (summary PageSummary, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[key]
+	if !ok {
+		return PageSummary{}, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if now := time.Now(); !now.After(entry.expires) || !c.revalidatable(entry, now) {
+		return PageSummary{}, false
+	}
+	return entry.summary, true
+}
+
+// revalidatable reports whether the expired entry is kept for
+// revalidation: a summary whose revision is known, expired for less than
+// the stale TTL. Callers must hold c.mu.
+func (c *lruCache) revalidatable(entry *cacheEntry, now time.Time) bool {
+	return !entry.negative && entry.summary.Revision > 0 && now.Before(entry.expires.Add(c.staleTTL))
+}
+
+// Put stores summary under key, evicting the least recently used entry when
+// the cache is full.
+func (c *lruCache) Put(key cacheKey, summary PageSummary) {
+	c.store(&cacheEntry{key: key, summary: summary})
+}
+
+// PutNegative records that the page for key doesn't exist. It does nothing
+// when negative caching is disabled.
+func (c *lruCache) PutNegative(key cacheKey) {
+	c.store(&cacheEntry{key: key, negative: true})
+}
+
+// SetTTL changes the lifetimes given to entries stored from now on. The
+// stale TTL applies at once, to entries already expired too.
+func (c *lruCache) SetTTL(ttl, negativeTTL, staleTTL time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl, c.negativeTTL, c.staleTTL = ttl, negativeTTL, staleTTL
+}
+
+// store inserts or replaces the entry for entry.key, setting its expiry
+// from the TTL for its kind.
+func (c *lruCache) store(entry *cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ttl := c.ttl
+	if entry.negative {
+		if c.negativeTTL <= 0 {
+			return
+		}
+		ttl = c.negativeTTL
+	}
+	entry.stored = time.Now()
+	entry.expires = entry.stored.Add(ttl)
+
+	if el, ok := c.items[entry.key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+	if c.order.Len() >= c.capacity {
+		c.removeElement(c.order.Back())
+	}
+	c.items[entry.key] = c.order.PushFront(entry)
+}
+
+// Stats returns the current hit/miss counters and occupancy.
+func (c *lruCache) Stats() CacheStats {
+	c.mu.Lock()
+	stats := CacheStats{Backend: "memory", Size: c.order.Len(), Capacity: c.capacity}
+	c.mu.Unlock()
+	c.counters.fill(&stats)
+	return stats
+}
+
+// probeKey is the key Check stores its probe entry under. No lookup uses
+// it: topics never contain a NUL byte.
+var probeKey = cacheKey{topic: "\x00probe"}
+
+// Check verifies that the cache works with a put/get round-trip of a probe
+// entry. The probe bypasses eviction and the hit/miss counters, so it
+// doesn't disturb cached summaries or the stats, and is removed again.
+func (c *lruCache) Check() (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("cache round-trip panicked: %v", v)
+		}
+	}()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	want := time.Now().Format(time.RFC3339Nano)
+	c.items[probeKey] = c.order.PushFront(&cacheEntry{key: probeKey, summary: PageSummary{Summary: want}, expires: time.Now().Add(time.Minute)})
+	el, ok := c.items[probeKey]
+	if !ok {
+		return errors.New("cache probe entry not found after put")
+	}
+	c.removeElement(el)
+	if got := el.Value.(*cacheEntry).summary.Summary; got != want {
+		return fmt.Errorf("cache probe entry read back as %q, want %q", got, want)
+	}
+	if _, ok := c.items[probeKey]; ok {
+		return errors.New("cache probe entry still present after removal")
+	}
+	return nil
+}
+
+// Entries lists the unexpired entries, sorted by key.
+func (c *lruCache) Entries() ([]CacheEntryInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	entries := make([]CacheEntryInfo, 0, len(c.items))
+	for _, el := range c.items {
+		entry := el.Value.(*cacheEntry)
+		if now.After(entry.expires) {
+			continue
+		}
+		age := durationMS(now.Sub(entry.stored))
+		entries = append(entries, CacheEntryInfo{
+			Key:      entry.key.ID(),
+			Negative: entry.negative,
+			AgeMS:    &age,
+			TTLMS:    durationMS(entry.expires.Sub(now)),
+		})
+	}
+	slices.SortFunc(entries, func(a, b CacheEntryInfo) int { return strings.Compare(a.Key, b.Key) })
+	return entries, nil
+}
+
+// Delete removes the entries for keys and returns how many there were.
+func (c *lruCache) Delete(keys ...cacheKey) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := 0
+	for _, key := range keys {
+		if el, ok := c.items[key]; ok {
+			c.removeElement(el)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Purge removes every entry and returns how many there were. The hit/miss
+// counters are kept.
+func (c *lruCache) Purge() (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	n := c.order.Len()
+	c.order.Init()
+	clear(c.items)
+	return n, nil
+}
+
+// removeElement drops el from the cache. Callers must hold c.mu.
+func (c *lruCache) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*cacheEntry).key)
+}
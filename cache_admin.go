@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+)
+
+// CacheListResponse is the JSON body returned by GET /admin/cache. Total
+// counts every live entry; Entries holds only the requested window.
+type CacheListResponse struct {
+	Backend string           `json:"backend"`
+	Total   int              `json:"total"`
+	Offset  int              `json:"offset"`
+	Limit   int              `json:"limit"`
+	Entries []CacheEntryInfo `json:"entries"`
+}
+
+// CachePurgeResponse is the JSON body returned by DELETE /admin/cache.
+type CachePurgeResponse struct {
+	Deleted int `json:"deleted"`
+}
+
+// adminCacheHandler lets operators inspect and evict cached summaries. GET
+// lists the live entries with their ages and remaining TTLs, paginated like
+// /links; DELETE evicts the entries named by repeated "key" parameters, or
+// every entry with "all=true". Like /admin/drain it needs API_KEYS to be
+// set.
+func adminCacheHandler(w http.ResponseWriter, r *http.Request) {
+	if len(currentConfig().APIKeys) == 0 {
+		writeError(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "/admin/cache requires API_KEYS to be set")
+		return
+	}
+	if summaryCache == nil {
+		writeError(w, r, http.StatusNotFound, "CACHE_DISABLED", "the summary cache is disabled (CACHE_SIZE=0)")
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		offset, limit, ok := parsePagination(w, r)
+		if !ok {
+			return
+		}
+		entries, err := summaryCache.Entries()
+		if err != nil {
+			writeUpstreamError(w, r, err, "", "cache listing")
+			return
+		}
+		writeJSON(w, r, http.StatusOK, CacheListResponse{
+			Backend: summaryCache.Stats().Backend,
+			Total:   len(entries),
+			Offset:  offset,
+			Limit:   limit,
+			Entries: paginate(entries, offset, limit),
+		})
+		return
+	}
+
+	all, ok := boolParam(w, r, "all", false)
+	if !ok {
+		return
+	}
+	ids := r.URL.Query()["key"]
+	var (
+		deleted int
+		err     error
+	)
+	switch {
+	case all && len(ids) > 0:
+		paramError{name: "all", msg: "all=true can't be combined with key"}.write(w, r)
+		return
+	case all:
+		deleted, err = summaryCache.Purge()
+	case len(ids) == 0:
+		paramError{name: "key", msg: "key is required, e.g. key=en:t:Go, or all=true to purge every entry"}.write(w, r)
+		return
+	default:
+		keys := make([]cacheKey, len(ids))
+		for i, id := range ids {
+			if keys[i], err = parseCacheKeyID(id); err != nil {
+				paramError{name: "key", msg: err.Error()}.write(w, r)
+				return
+			}
+		}
+		deleted, err = summaryCache.Delete(keys...)
+	}
+	if err != nil {
+		writeUpstreamError(w, r, err, "", "cache purge")
+		return
+	}
+	logger.Info("cache entries evicted", "deleted", deleted, "keys", ids, "all", all)
+	writeJSON(w, r, http.StatusOK, CachePurgeResponse{Deleted: deleted})
+}
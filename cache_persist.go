@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// cacheFileVersion is the format of the files written by lruCache.Save.
+// Files of another version are ignored rather than misread.
+const cacheFileVersion = 1
+
+// cacheFile is the content of a CACHE_PERSIST_PATH file: the live entries,
+// most recently used first.
+type cacheFile struct {
+	Version int          `json:"version"`
+	Saved   time.Time    `json:"saved"`
+	Entries []savedEntry `json:"entries"`
+}
+
+// savedEntry is a cacheEntry as persisted, its key written as its ID.
+type savedEntry struct {
+	Key      string      `json:"key"`
+	Summary  PageSummary `json:"summary,omitzero"`
+	Negative bool        `json:"negative,omitempty"`
+	Stored   time.Time   `json:"stored"`
+	Expires  time.Time   `json:"expires"`
+}
+
+// Save writes the unexpired entries to the file at path, replacing it
+// atomically so a crash while saving leaves the previous file intact, and
+// returns how many were written.
+func (c *lruCache) Save(path string) (int, error) {
+	c.mu.Lock()
+	now := time.Now()
+	file := cacheFile{Version: cacheFileVersion, Saved: now, Entries: make([]savedEntry, 0, c.order.Len())}
+	for el := c.order.Front(); el != nil; el = el.Next() {
+		entry := el.Value.(*cacheEntry)
+		if now.After(entry.expires) {
+			continue
+		}
+		file.Entries = append(file.Entries, savedEntry{
+			Key:      entry.key.ID(),
+			Summary:  entry.summary,
+			Negative: entry.negative,
+			Stored:   entry.stored,
+			Expires:  entry.expires,
+		})
+	}
+	c.mu.Unlock()
+
+	data, err := json.Marshal(file)
+	if err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name()) // fails harmlessly once renamed
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return 0, err
+	}
+	return len(file.Entries), nil
+}
+
+// Load reinstates the entries saved at path that haven't expired, keeping
+// their recency order, and returns how many it restored. Entries never
+// outlive the current TTLs, and beyond the capacity the least recently used
+// are dropped. A missing file restores nothing; an unreadable or corrupt
+// one is an error and leaves the cache as it was.
+func (c *lruCache) Load(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	var file cacheFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return 0, err
+	}
+	if file.Version != cacheFileVersion {
+		return 0, fmt.Errorf("unsupported cache file version %d", file.Version)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	entries := make([]*cacheEntry, 0, min(len(file.Entries), c.capacity))
+	for _, saved := range file.Entries {
+		if len(entries)+c.order.Len() >= c.capacity {
+			break
+		}
+		key, err := parseCacheKeyID(saved.Key)
+		if err != nil {
+			logger.Debug("skipping saved cache entry", "key", saved.Key, "error", err)
+			continue
+		}
+		ttl := c.ttl
+		if saved.Negative {
+			ttl = c.negativeTTL
+		}
+		expires := saved.Expires
+		if limit := saved.Stored.Add(ttl); limit.Before(expires) {
+			expires = limit
+		}
+		if _, ok := c.items[key]; ok || !now.Before(expires) {
+			continue
+		}
+		entries = append(entries, &cacheEntry{key: key, summary: saved.Summary, negative: saved.Negative, stored: saved.Stored, expires: expires})
+	}
+	// Pushing the least recently used first leaves the most recent in front
+	for i := len(entries) - 1; i >= 0; i-- {
+		c.items[entries[i].key] = c.order.PushFront(entries[i])
+	}
+	return len(entries), nil
+}
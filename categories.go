@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+)
+
+// CategoriesResponse is the JSON body returned by /categories.
+type CategoriesResponse struct {
+	Topic      string   `json:"topic"`
+	Title      string   `json:"title"`
+	Lang       string   `json:"lang"`
+	Categories []string `json:"categories"`
+}
+
+// categoriesHandler returns the categories a page belongs to, with the
+// "Category:" prefix stripped. Uncategorized pages yield an empty list.
+func categoriesHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, categories, err := wikiClient.Categories(r.Context(), topic, lang)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "categories lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, CategoriesResponse{Topic: topic, Title: title, Lang: lang, Categories: categories})
+}
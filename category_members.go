@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultCategoryMembersLimit = 50
+	maxCategoryMembersLimit     = 500
+)
+
+// CategoryMembersResponse is the JSON body returned by /categories-members.
+// Continue is the token for the next page of members, empty on the last one.
+type CategoryMembersResponse struct {
+	Category string           `json:"category"`
+	Lang     string           `json:"lang"`
+	Members  []CategoryMember `json:"members"`
+	Continue string           `json:"continue,omitempty"`
+}
+
+// categoryMembersHandler lists the pages in a category, the reverse of
+// /categories. The category name comes from the "category" parameter (GET)
+// or the body (POST), with or without the "Category:" prefix. "limit" sets
+// the page size (default 50, max 500) and "continue" resumes after a
+// previous page. Subcategories are listed with type "subcat", so clients can
+// crawl a whole category tree.
+func categoryMembersHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Resolve the language edition, page size and category
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	limit, ok := intRangeParam(w, r, "limit", defaultCategoryMembersLimit, 1, maxCategoryMembersLimit)
+	if !ok {
+		return
+	}
+	category := r.URL.Query().Get("category")
+	if r.Method == http.MethodPost {
+		body, err := readBodyText(w, r)
+		if err != nil {
+			writeBodyError(w, r, err)
+			return
+		}
+		category = body
+	}
+	category = normalizeTopic(category)
+	if prefix, name, found := strings.Cut(category, ":"); found && strings.EqualFold(prefix, "category") {
+		category = strings.TrimSpace(name)
+	}
+	if category == "" {
+		paramError{name: "category", msg: "category is required"}.write(w, r)
+		return
+	}
+	requestInfoFrom(r.Context()).Topic = category
+
+	// 2. Fetch one page of members
+	members, next, err := wikiClient.CategoryMembers(r.Context(), category, lang, limit, r.URL.Query().Get("continue"))
+	if err != nil {
+		writeUpstreamError(w, r, err, category, "category members lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, CategoryMembersResponse{Category: category, Lang: lang, Members: members, Continue: next})
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// runLookupCommand implements "wikipedia-agent lookup [-lang code] <topic>":
+// it prints the topic's summary to stdout and returns the process exit code.
+// Words after the flags are joined, so the topic needn't be quoted.
+func runLookupCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("lookup", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	lang := fs.String("lang", defaultLang, "Wikipedia language edition to query")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: wikipedia-agent lookup [-lang code] <topic>")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	topic := normalizeTopic(strings.Join(fs.Args(), " "))
+	if topic == "" {
+		fs.Usage()
+		return 2
+	}
+	if !isSupportedLanguage(*lang) {
+		fmt.Fprintf(stderr, "unsupported language code %q\n", *lang)
+		return 2
+	}
+
+	result, err := fetchWikipediaSummary(context.Background(), topic, *lang)
+	var disambig *DisambiguationError
+	if errors.As(err, &disambig) {
+		fmt.Fprintf(stderr, "%q is ambiguous, try one of:\n", disambig.Title)
+		for _, option := range disambig.Options {
+			fmt.Fprintf(stderr, "  %s\n", option)
+		}
+		return 1
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "lookup error: %v\n", err)
+		return 1
+	}
+
+	fmt.Fprintln(stdout, result.Summary)
+	return 0
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// WikipediaClient is the set of Wikipedia operations the handlers rely on.
+// Page methods return the resolved page title alongside their result and
+// report unknown pages with ErrPageNotFound.
+type WikipediaClient interface {
+	Summary(ctx context.Context, topic, lang string) (PageSummary, error)
+	SummaryByID(ctx context.Context, id int, lang string) (PageSummary, error)
+	RESTSummary(ctx context.Context, topic, lang string) (PageSummary, error)
+	LatestRevision(ctx context.Context, title, lang string) (resolved string, revision int, err error)
+	Search(ctx context.Context, query, lang string, limit int) ([]string, error)
+	Suggest(ctx context.Context, query, lang string) (string, error)
+	Random(ctx context.Context, lang string, n int) ([]string, error)
+	Nearby(ctx context.Context, lang string, lat, lon float64, radius, limit int) ([]NearbyPage, error)
+	Related(ctx context.Context, topic, lang string, limit int) (title string, pages []RelatedPage, err error)
+	Resolve(ctx context.Context, topic, lang string) (title string, err error)
+	Normalize(ctx context.Context, topic, lang string) (TitleResolution, error)
+	Disambiguation(ctx context.Context, topic, lang string) (title string, options []string, err error)
+	Content(ctx context.Context, topic, lang string) (title, content string, err error)
+	Wikitext(ctx context.Context, topic, lang, section string) (title, wikitext string, err error)
+	HTML(ctx context.Context, topic, lang, section string) (title, html string, err error)
+	Sections(ctx context.Context, topic, lang string) (title string, sections []Section, err error)
+	Section(ctx context.Context, topic, lang, section string) (title, text string, err error)
+	SectionTexts(ctx context.Context, topic, lang string, sections []string) (title string, texts map[string]string, available []string, err error)
+	Links(ctx context.Context, topic, lang string) (title string, links []string, err error)
+	References(ctx context.Context, topic, lang string) (title string, references []string, err error)
+	SeeAlso(ctx context.Context, topic, lang string, headings []string) (title, section string, links []string, err error)
+	Images(ctx context.Context, topic, lang string) (title string, images []string, err error)
+	LeadImage(ctx context.Context, topic, lang string) (title, image string, err error)
+	Thumbnail(ctx context.Context, topic, lang string, width int) (title string, original, thumb Image, err error)
+	Coordinates(ctx context.Context, topic, lang string) (title string, lat, lon float64, err error)
+	Description(ctx context.Context, topic, lang string) (title, description string, err error)
+	Entity(ctx context.Context, topic, lang string) (title string, entity Entity, err error)
+	Sitelinks(ctx context.Context, ids []string, lang string) (titles map[string]string, err error)
+	Categories(ctx context.Context, topic, lang string) (title string, categories []string, err error)
+	CategoryMembers(ctx context.Context, category, lang string, limit int, cont string) (members []CategoryMember, next string, err error)
+	LangLinks(ctx context.Context, topic, lang string) (title string, links map[string]string, err error)
+	History(ctx context.Context, topic, lang string, limit int) (title string, revisions []Revision, err error)
+	RevisionLeads(ctx context.Context, topic, lang string, ids ...int) (title string, leads []RevisionLead, err error)
+	Pageviews(ctx context.Context, topic, lang, granularity string, start, end time.Time) (title string, views []PageviewCount, err error)
+	OnThisDay(ctx context.Context, lang, kind string, month, day int) ([]HistoricalEvent, error)
+	Featured(ctx context.Context, lang string, date time.Time) (article *FeaturedArticle, topRead []string, err error)
+}
+
+// goWikiClient is the production WikipediaClient, backed by go-wiki and,
+// where go-wiki falls short, direct MediaWiki API queries. Its methods are
+// defined in wiki.go, and those using the REST API in rest.go.
+type goWikiClient struct{}
+
+// wikiClient is the backend every handler goes through. Tests can replace it
+// with a fake to avoid network calls.
+var wikiClient WikipediaClient = goWikiClient{}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// parseTrustedProxies parses TRUSTED_PROXIES entries, CIDR ranges such as
+// 10.0.0.0/8 or single addresses, into prefixes.
+func parseTrustedProxies(entries []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(entries))
+	for _, entry := range entries {
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: %q is not an IP address or CIDR range", entry)
+		}
+		if prefix.Addr().Is4In6() {
+			prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// trusted reports whether addr lies in one of the prefixes.
+func trusted(addr netip.Addr, prefixes []netip.Prefix) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP returns the address of the client behind r. Forwarding headers
+// are only believed from a peer in TRUSTED_PROXIES, since anyone else can
+// send them: X-Forwarded-For is then read from the right, skipping the
+// trusted proxies that appended to it, and the first other address is the
+// client; without the header X-Real-IP is used. Anything unparsable ends
+// the search at the last address known to be good, so a spoofed or garbled
+// header never yields more than the nearest proxy vouches for.
+func clientIP(r *http.Request, prefixes []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	peer, err := netip.ParseAddr(host)
+	if err != nil {
+		return host
+	}
+	client := peer.Unmap()
+	if !trusted(client, prefixes) {
+		return client.String()
+	}
+
+	hops := r.Header.Values("X-Forwarded-For")
+	if len(hops) == 0 {
+		if realIP, err := parseHop(r.Header.Get("X-Real-IP")); err == nil {
+			return realIP.String()
+		}
+		return client.String()
+	}
+	// Repeated headers count as one list, in order
+	list := strings.Split(strings.Join(hops, ","), ",")
+	for i := len(list) - 1; i >= 0; i-- {
+		hop, err := parseHop(list[i])
+		if err != nil {
+			break
+		}
+		client = hop
+		if !trusted(client, prefixes) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// parseHop parses an address from a forwarding header, with or without a
+// port, as some proxies add one.
+func parseHop(s string) (netip.Addr, error) {
+	s = strings.TrimSpace(s)
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		addrPort, portErr := netip.ParseAddrPort(s)
+		if portErr != nil {
+			return netip.Addr{}, err
+		}
+		addr = addrPort.Addr()
+	}
+	return addr.Unmap(), nil
+}
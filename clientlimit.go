@@ -0,0 +1,103 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// clientLimiters holds a token bucket per client for CLIENT_RATE_LIMIT,
+// separate from the upstream limiter: one client using up its quota leaves
+// the others theirs, and answers from the cache count too.
+var clientLimiters = clientLimiterSet{clients: make(map[string]*clientLimiter)}
+
+type clientLimiterSet struct {
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	lastSweep time.Time
+}
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// reserve claims a token for the client id without waiting and returns how
+// long it has to wait for one, zero when it got one. Limiters follow cfg, so
+// a reload changes the rate of known clients without refilling them. At
+// most once per idle period the clients idle longer than that are dropped.
+func (s *clientLimiterSet) reserve(id string, cfg *Config, now time.Time) time.Duration {
+	limit := rate.Limit(cfg.ClientRateLimit)
+	// An entry is only dropped once its bucket has refilled, so forgetting a
+	// client never hands it more than a full burst
+	idle := max(cfg.ClientRateIdle, time.Duration(float64(cfg.ClientRateBurst)/float64(limit)*float64(time.Second)))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.lastSweep) >= idle {
+		for key, c := range s.clients {
+			if now.Sub(c.lastSeen) >= idle {
+				delete(s.clients, key)
+			}
+		}
+		s.lastSweep = now
+	}
+	c := s.clients[id]
+	switch {
+	case c == nil:
+		c = &clientLimiter{limiter: rate.NewLimiter(limit, cfg.ClientRateBurst)}
+		s.clients[id] = c
+	case c.limiter.Limit() != limit || c.limiter.Burst() != cfg.ClientRateBurst:
+		c.limiter.SetLimitAt(now, limit)
+		c.limiter.SetBurstAt(now, cfg.ClientRateBurst)
+	}
+	c.lastSeen = now
+	res := c.limiter.ReserveN(now, 1)
+	if delay := res.DelayFrom(now); delay > 0 {
+		res.CancelAt(now)
+		return delay
+	}
+	return 0
+}
+
+// clientID names the client r counts against: its API key when it sent a
+// valid one, so a key shared by several machines has one quota, and its
+// address otherwise. Keys are held as a hash prefix rather than verbatim.
+func clientID(r *http.Request, cfg *Config) string {
+	key := r.Header.Get("X-API-Key")
+	if key == "" {
+		key = r.URL.Query().Get("api_key")
+	}
+	if len(cfg.APIKeys) > 0 && validAPIKey(cfg.APIKeys, key) {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:8])
+	}
+	return "ip:" + requestInfoFrom(r.Context()).ClientIP
+}
+
+// withClientRateLimit rejects requests with 429 and a Retry-After header
+// once their client has used up its CLIENT_RATE_LIMIT quota. Public paths
+// such as probes and /metrics are exempt.
+func withClientRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cfg := currentConfig()
+		if cfg.ClientRateLimit == 0 || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		id := clientID(r, cfg)
+		if wait := clientLimiters.reserve(id, cfg, time.Now()); wait > 0 {
+			logger.Debug("client rate limited", "client", id, "retry_after", wait)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, r, http.StatusTooManyRequests, "CLIENT_RATE_LIMITED", "too many requests from this client, retry later")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
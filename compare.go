@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+)
+
+// CompareResponse is the JSON body returned by /compare. Each side reports
+// its own summary or error, as in a /batch item.
+type CompareResponse struct {
+	Lang string    `json:"lang"`
+	A    BatchItem `json:"a"`
+	B    BatchItem `json:"b"`
+}
+
+// compareHandler fetches the summaries of the topics in the "a" and "b"
+// query parameters concurrently. One side failing doesn't fail the request;
+// its error is reported alongside the other side's summary.
+func compareHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	a, b := r.URL.Query().Get("a"), r.URL.Query().Get("b")
+	if normalizeTopic(a) == "" || normalizeTopic(b) == "" {
+		writeError(w, r, http.StatusBadRequest, "TOPIC_REQUIRED", "both topics a and b are required")
+		return
+	}
+	requestInfoFrom(r.Context()).Topic = a + " vs " + b
+
+	resp := CompareResponse{Lang: lang}
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		resp.A = lookupBatchItem(r, a, lang)
+	}()
+	go func() {
+		defer wg.Done()
+		resp.B = lookupBatchItem(r, b, lang)
+	}()
+	wg.Wait()
+
+	writeJSON(w, r, http.StatusOK, resp)
+}
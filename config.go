@@ -0,0 +1,561 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"net/url"
+	"os"
+	"os/signal"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/time/rate"
+)
+
+// Config holds the settings that can be changed without a restart. Each
+// field is tagged with the environment variable it comes from; "secret"
+// keeps its value out of the reload log. Readers get the current instance
+// from currentConfig and must not modify it.
+type Config struct {
+	LogLevel         slog.Level    `env:"LOG_LEVEL"`
+	WikiTimeout      time.Duration `env:"WIKI_TIMEOUT"`
+	MaxRetries       int           `env:"WIKI_MAX_RETRIES"`
+	RetryBaseDelay   time.Duration `env:"WIKI_RETRY_BASE_DELAY"`
+	RateLimit        int           `env:"WIKI_RATE_LIMIT"`
+	RateBurst        int           `env:"WIKI_RATE_BURST"`
+	ClientRateLimit  int           `env:"CLIENT_RATE_LIMIT"`
+	ClientRateBurst  int           `env:"CLIENT_RATE_BURST"`
+	ClientRateIdle   time.Duration `env:"CLIENT_RATE_IDLE"`
+	BatchConcurrency int           `env:"BATCH_CONCURRENCY"`
+	BatchMaxSize     int           `env:"BATCH_MAX_SIZE"`
+	BatchItemTimeout time.Duration `env:"BATCH_ITEM_TIMEOUT"`
+	CacheTTL         time.Duration `env:"CACHE_TTL"`
+	CacheNegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL"`
+	CacheStaleTTL    time.Duration `env:"CACHE_STALE_TTL"`
+	ReadyTimeout     time.Duration `env:"READY_TIMEOUT"`
+	ReadyCacheTTL    time.Duration `env:"READY_CACHE_TTL"`
+	CORSOrigins      []string      `env:"CORS_ALLOWED_ORIGINS"`
+	APIKeys          []string      `env:"API_KEYS,secret"`
+	MaxBodyBytes     int64         `env:"MAX_BODY_BYTES"`
+	CacheMaxAge      int           `env:"CACHE_MAX_AGE"`
+	GzipMinSize      int           `env:"GZIP_MIN_SIZE"`
+	MaxSummaryBytes  int           `env:"MAX_SUMMARY_BYTES"`
+	MaxContentBytes  int           `env:"MAX_CONTENT_BYTES"`
+	TopicAllowlist   []string      `env:"TOPIC_ALLOWLIST"`
+	TopicBlocklist   []string      `env:"TOPIC_BLOCKLIST"`
+	FallbackLangs    []string      `env:"FALLBACK_LANGS"`
+	BreakerFailures  int           `env:"BREAKER_FAILURES"`
+	BreakerCooldown  time.Duration `env:"BREAKER_COOLDOWN"`
+	SlowThreshold    time.Duration `env:"SLOW_THRESHOLD"`
+	IdempotencyTTL   time.Duration `env:"IDEMPOTENCY_TTL"`
+	ArchiveFallback  bool          `env:"ARCHIVE_FALLBACK"`
+	ArchiveAPIURL    string        `env:"ARCHIVE_API_URL"`
+	WikidataAPIURL   string        `env:"WIKIDATA_API_URL"`
+	TrustedProxies   []string      `env:"TRUSTED_PROXIES"`
+	SeeAlsoSections  []string      `env:"SEE_ALSO_SECTIONS"`
+
+	// trustedProxies is parsed from TrustedProxies
+	trustedProxies []netip.Prefix
+	// seeAlsoSections is parsed from SeeAlsoSections
+	seeAlsoSections map[string][]string
+	// limiter throttles upstream calls at RateLimit; nil when disabled
+	limiter *rate.Limiter
+	// topics is compiled from TopicAllowlist and TopicBlocklist; nil when
+	// both are empty
+	topics *topicFilter
+}
+
+// config is the live configuration. setConfig swaps it atomically, so a
+// request sees either the old or the new settings, never a mix.
+var config atomic.Pointer[Config]
+
+func init() {
+	config.Store(defaultConfig())
+}
+
+// currentConfig returns the live configuration.
+func currentConfig() *Config {
+	return config.Load()
+}
+
+// defaultConfig returns the settings used when no environment variable
+// overrides them.
+func defaultConfig() *Config {
+	return &Config{
+		LogLevel:         slog.LevelInfo,
+		WikiTimeout:      10 * time.Second,
+		MaxRetries:       2,
+		RetryBaseDelay:   200 * time.Millisecond,
+		ClientRateIdle:   5 * time.Minute,
+		BatchConcurrency: 4,
+		BatchMaxSize:     50,
+		BatchItemTimeout: 5 * time.Second,
+		CacheTTL:         time.Hour,
+		CacheNegativeTTL: time.Minute,
+		CacheStaleTTL:    24 * time.Hour,
+		ReadyTimeout:     2 * time.Second,
+		ReadyCacheTTL:    10 * time.Second,
+		MaxBodyBytes:     4096,
+		CacheMaxAge:      3600,
+		GzipMinSize:      1024,
+		MaxSummaryBytes:  64 << 10,
+		MaxContentBytes:  8 << 20,
+		BreakerFailures:  5,
+		BreakerCooldown:  30 * time.Second,
+		IdempotencyTTL:   5 * time.Minute,
+		ArchiveAPIURL:    defaultArchiveAPIURL,
+		WikidataAPIURL:   defaultWikidataAPIURL,
+	}
+}
+
+// loadConfig reads the reloadable settings from the environment. It
+// reports every invalid value instead of exiting, so a bad reload leaves the
+// running configuration in place.
+func loadConfig() (*Config, error) {
+	cfg := defaultConfig()
+	var errs []error
+	intEnv := func(key string, fallback int) int {
+		n, err := lookupInt(key, fallback)
+		errs = append(errs, err)
+		return n
+	}
+	durationEnv := func(key string, fallback time.Duration) time.Duration {
+		d, err := lookupDuration(key, fallback)
+		errs = append(errs, err)
+		return d
+	}
+	boolEnv := func(key string, fallback bool) bool {
+		b, err := lookupBool(key, fallback)
+		errs = append(errs, err)
+		return b
+	}
+
+	level, err := parseLogLevel(envString("LOG_LEVEL", "info"))
+	errs = append(errs, err)
+	cfg.LogLevel = level
+	cfg.WikiTimeout = durationEnv("WIKI_TIMEOUT", cfg.WikiTimeout)
+	cfg.MaxRetries = max(intEnv("WIKI_MAX_RETRIES", cfg.MaxRetries), 0)
+	cfg.RetryBaseDelay = durationEnv("WIKI_RETRY_BASE_DELAY", cfg.RetryBaseDelay)
+	cfg.RateLimit = max(intEnv("WIKI_RATE_LIMIT", 0), 0)
+	cfg.RateBurst = max(intEnv("WIKI_RATE_BURST", cfg.RateLimit), 1)
+	cfg.ClientRateLimit = max(intEnv("CLIENT_RATE_LIMIT", 0), 0)
+	cfg.ClientRateBurst = max(intEnv("CLIENT_RATE_BURST", cfg.ClientRateLimit), 1)
+	if d := durationEnv("CLIENT_RATE_IDLE", cfg.ClientRateIdle); d > 0 {
+		cfg.ClientRateIdle = d
+	}
+	if n := intEnv("BATCH_CONCURRENCY", cfg.BatchConcurrency); n > 0 {
+		cfg.BatchConcurrency = n
+	}
+	if n := intEnv("BATCH_MAX_SIZE", cfg.BatchMaxSize); n > 0 {
+		cfg.BatchMaxSize = n
+	}
+	if d := durationEnv("BATCH_ITEM_TIMEOUT", cfg.BatchItemTimeout); d > 0 {
+		cfg.BatchItemTimeout = d
+	}
+	cfg.CacheTTL = durationEnv("CACHE_TTL", cfg.CacheTTL)
+	cfg.CacheNegativeTTL = durationEnv("CACHE_NEGATIVE_TTL", cfg.CacheNegativeTTL)
+	cfg.CacheStaleTTL = durationEnv("CACHE_STALE_TTL", cfg.CacheStaleTTL)
+	cfg.ReadyTimeout = durationEnv("READY_TIMEOUT", cfg.ReadyTimeout)
+	cfg.ReadyCacheTTL = durationEnv("READY_CACHE_TTL", cfg.ReadyCacheTTL)
+	cfg.CORSOrigins = envList("CORS_ALLOWED_ORIGINS")
+	cfg.APIKeys = envList("API_KEYS")
+	if n := intEnv("MAX_BODY_BYTES", int(cfg.MaxBodyBytes)); n > 0 {
+		cfg.MaxBodyBytes = int64(n)
+	}
+	cfg.CacheMaxAge = max(intEnv("CACHE_MAX_AGE", cfg.CacheMaxAge), 0)
+	cfg.GzipMinSize = intEnv("GZIP_MIN_SIZE", cfg.GzipMinSize)
+	cfg.MaxSummaryBytes = max(intEnv("MAX_SUMMARY_BYTES", cfg.MaxSummaryBytes), 0)
+	cfg.MaxContentBytes = max(intEnv("MAX_CONTENT_BYTES", cfg.MaxContentBytes), 0)
+	cfg.TopicAllowlist, err = topicPatterns("TOPIC_ALLOWLIST")
+	errs = append(errs, err)
+	cfg.TopicBlocklist, err = topicPatterns("TOPIC_BLOCKLIST")
+	errs = append(errs, err)
+	cfg.FallbackLangs = envList("FALLBACK_LANGS")
+	for _, lang := range cfg.FallbackLangs {
+		if !isSupportedLanguage(lang) {
+			errs = append(errs, fmt.Errorf("FALLBACK_LANGS: unsupported language %q", lang))
+		}
+	}
+	cfg.BreakerFailures = max(intEnv("BREAKER_FAILURES", cfg.BreakerFailures), 0)
+	cfg.BreakerCooldown = durationEnv("BREAKER_COOLDOWN", cfg.BreakerCooldown)
+	cfg.SlowThreshold = durationEnv("SLOW_THRESHOLD", cfg.SlowThreshold)
+	cfg.IdempotencyTTL = durationEnv("IDEMPOTENCY_TTL", cfg.IdempotencyTTL)
+	cfg.ArchiveFallback = boolEnv("ARCHIVE_FALLBACK", false)
+	cfg.ArchiveAPIURL = envString("ARCHIVE_API_URL", cfg.ArchiveAPIURL)
+	if u, err := url.Parse(cfg.ArchiveAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+		errs = append(errs, fmt.Errorf("ARCHIVE_API_URL=%q: must be an absolute http(s) URL without a query string", cfg.ArchiveAPIURL))
+	}
+	cfg.WikidataAPIURL = envString("WIKIDATA_API_URL", cfg.WikidataAPIURL)
+	if u, err := url.Parse(cfg.WikidataAPIURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+		errs = append(errs, fmt.Errorf("WIKIDATA_API_URL=%q: must be an absolute http(s) URL without a query string", cfg.WikidataAPIURL))
+	}
+	cfg.TrustedProxies = envList("TRUSTED_PROXIES")
+	cfg.trustedProxies, err = parseTrustedProxies(cfg.TrustedProxies)
+	errs = append(errs, err)
+	cfg.SeeAlsoSections = envList("SEE_ALSO_SECTIONS")
+	cfg.seeAlsoSections, err = parseSeeAlsoSections(cfg.SeeAlsoSections)
+	errs = append(errs, err)
+	cfg.topics, err = newTopicFilter(cfg.TopicAllowlist, cfg.TopicBlocklist)
+	errs = append(errs, err)
+
+	if cfg.WikiTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("WIKI_TIMEOUT=%q: must be positive", os.Getenv("WIKI_TIMEOUT")))
+	}
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"WIKI_RETRY_BASE_DELAY", cfg.RetryBaseDelay},
+		{"CACHE_TTL", cfg.CacheTTL},
+		{"CACHE_NEGATIVE_TTL", cfg.CacheNegativeTTL},
+		{"CACHE_STALE_TTL", cfg.CacheStaleTTL},
+		{"READY_TIMEOUT", cfg.ReadyTimeout},
+		{"READY_CACHE_TTL", cfg.ReadyCacheTTL},
+		{"BREAKER_COOLDOWN", cfg.BreakerCooldown},
+		{"SLOW_THRESHOLD", cfg.SlowThreshold},
+		{"IDEMPOTENCY_TTL", cfg.IdempotencyTTL},
+	} {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("%s=%q: must not be negative", d.key, os.Getenv(d.key)))
+		}
+	}
+	if cfg.GzipMinSize < 0 {
+		errs = append(errs, fmt.Errorf("GZIP_MIN_SIZE=%q: must not be negative", os.Getenv("GZIP_MIN_SIZE")))
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// setConfig makes cfg the live configuration and returns the one it
+// replaces. An upstream limiter whose settings didn't change is carried
+// over, so a reload doesn't hand out a fresh burst.
+func setConfig(cfg *Config) (old *Config) {
+	old = config.Load()
+	switch {
+	case cfg.RateLimit == 0:
+		cfg.limiter = nil
+	case old.limiter != nil && old.RateLimit == cfg.RateLimit && old.RateBurst == cfg.RateBurst:
+		cfg.limiter = old.limiter
+	default:
+		cfg.limiter = rate.NewLimiter(rate.Limit(cfg.RateLimit), cfg.RateBurst)
+	}
+	logLevel.Set(cfg.LogLevel)
+	if summaryCache != nil {
+		summaryCache.SetTTL(cfg.CacheTTL, cfg.CacheNegativeTTL, cfg.CacheStaleTTL)
+	}
+	config.Store(cfg)
+	return old
+}
+
+// StartupConfig holds the settings read once at startup, which only take
+// effect on restart. Like Config, each field is tagged with its environment
+// variable; main copies the values into the packages' settings before
+// serving, and nothing reads the environment for them afterwards.
+type StartupConfig struct {
+	Host                  string        `env:"HOST"`
+	Port                  string        `env:"PORT"`
+	TLSCertFile           string        `env:"TLS_CERT_FILE"`
+	TLSKeyFile            string        `env:"TLS_KEY_FILE"`
+	LogFormat             string        `env:"LOG_FORMAT"`
+	DefaultLang           string        `env:"DEFAULT_LANG"`
+	UserAgent             string        `env:"WIKI_USER_AGENT"`
+	APIURL                string        `env:"WIKI_API_URL"`
+	MaxIdleConns          int           `env:"WIKI_MAX_IDLE_CONNS"`
+	MaxIdleConnsPerHost   int           `env:"WIKI_MAX_IDLE_CONNS_PER_HOST"`
+	IdleConnTimeout       time.Duration `env:"WIKI_IDLE_CONN_TIMEOUT"`
+	MaxRequestsPerHost    int           `env:"WIKI_MAX_REQUESTS_PER_HOST"`
+	MaxInFlight           int           `env:"MAX_IN_FLIGHT"`
+	MaxQueue              int           `env:"MAX_QUEUE"`
+	QueueTimeout          time.Duration `env:"QUEUE_TIMEOUT"`
+	CacheSize             int           `env:"CACHE_SIZE"`
+	CacheBackend          string        `env:"CACHE_BACKEND"`
+	RedisURL              string        `env:"REDIS_URL,secret"`
+	CachePersistPath      string        `env:"CACHE_PERSIST_PATH"`
+	HTTPReadHeaderTimeout time.Duration `env:"HTTP_READ_HEADER_TIMEOUT"`
+	HTTPReadTimeout       time.Duration `env:"HTTP_READ_TIMEOUT"`
+	HTTPWriteTimeout      time.Duration `env:"HTTP_WRITE_TIMEOUT"`
+	HTTPIdleTimeout       time.Duration `env:"HTTP_IDLE_TIMEOUT"`
+	EnableH2C             bool          `env:"ENABLE_H2C"`
+	PreloadTopics         []string      `env:"PRELOAD_TOPICS"`
+	HTMLErrorPages        bool          `env:"HTML_ERROR_PAGES"`
+	StatsTopN             int           `env:"STATS_TOP_N"`
+	EnablePprof           bool          `env:"ENABLE_PPROF"`
+	PprofAddr             string        `env:"PPROF_ADDR"`
+	ShutdownTimeout       time.Duration `env:"SHUTDOWN_TIMEOUT"`
+	DrainGrace            time.Duration `env:"DRAIN_GRACE"`
+
+	// apiURLTemplate is APIURL checked by parseAPIURLTemplate; empty when
+	// APIURL is
+	apiURLTemplate string
+	// redisOptions is RedisURL parsed; nil unless the Redis cache is used
+	redisOptions *redis.Options
+}
+
+// loadStartupConfig reads the startup settings from the environment and
+// validates them all, returning every invalid value at once so a
+// misconfigured deployment can be fixed in one go.
+func loadStartupConfig() (*StartupConfig, error) {
+	var errs []error
+	intEnv := func(key string, fallback int) int {
+		n, err := lookupInt(key, fallback)
+		errs = append(errs, err)
+		return n
+	}
+	durationEnv := func(key string, fallback time.Duration) time.Duration {
+		d, err := lookupDuration(key, fallback)
+		errs = append(errs, err)
+		return d
+	}
+	boolEnv := func(key string, fallback bool) bool {
+		b, err := lookupBool(key, fallback)
+		errs = append(errs, err)
+		return b
+	}
+
+	cfg := &StartupConfig{
+		Host:                  os.Getenv("HOST"),
+		Port:                  envString("PORT", "8080"),
+		TLSCertFile:           os.Getenv("TLS_CERT_FILE"),
+		TLSKeyFile:            os.Getenv("TLS_KEY_FILE"),
+		LogFormat:             envString("LOG_FORMAT", "json"),
+		DefaultLang:           envString("DEFAULT_LANG", defaultLang),
+		UserAgent:             envString("WIKI_USER_AGENT", userAgent),
+		APIURL:                os.Getenv("WIKI_API_URL"),
+		MaxIdleConns:          max(intEnv("WIKI_MAX_IDLE_CONNS", maxIdleConns), 0),
+		MaxIdleConnsPerHost:   max(intEnv("WIKI_MAX_IDLE_CONNS_PER_HOST", maxIdleConnsPerHost), 0),
+		IdleConnTimeout:       durationEnv("WIKI_IDLE_CONN_TIMEOUT", idleConnTimeout),
+		MaxRequestsPerHost:    max(intEnv("WIKI_MAX_REQUESTS_PER_HOST", maxRequestsPerHost), 0),
+		MaxInFlight:           max(intEnv("MAX_IN_FLIGHT", 0), 0),
+		MaxQueue:              max(intEnv("MAX_QUEUE", 0), 0),
+		QueueTimeout:          durationEnv("QUEUE_TIMEOUT", queueTimeout),
+		CacheSize:             max(intEnv("CACHE_SIZE", 1000), 0),
+		CacheBackend:          envString("CACHE_BACKEND", "memory"),
+		RedisURL:              os.Getenv("REDIS_URL"),
+		CachePersistPath:      os.Getenv("CACHE_PERSIST_PATH"),
+		HTTPReadHeaderTimeout: durationEnv("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		HTTPReadTimeout:       durationEnv("HTTP_READ_TIMEOUT", 15*time.Second),
+		HTTPWriteTimeout:      durationEnv("HTTP_WRITE_TIMEOUT", 30*time.Second),
+		HTTPIdleTimeout:       durationEnv("HTTP_IDLE_TIMEOUT", 2*time.Minute),
+		EnableH2C:             boolEnv("ENABLE_H2C", false),
+		PreloadTopics:         envList("PRELOAD_TOPICS"),
+		HTMLErrorPages:        boolEnv("HTML_ERROR_PAGES", htmlErrorPages),
+		StatsTopN:             max(intEnv("STATS_TOP_N", statsTopN), 0),
+		EnablePprof:           boolEnv("ENABLE_PPROF", false),
+		PprofAddr:             os.Getenv("PPROF_ADDR"),
+		ShutdownTimeout:       durationEnv("SHUTDOWN_TIMEOUT", 15*time.Second),
+		DrainGrace:            durationEnv("DRAIN_GRACE", 30*time.Second),
+	}
+
+	if n, err := strconv.Atoi(cfg.Port); err != nil || n < 0 || n > 65535 {
+		errs = append(errs, fmt.Errorf("PORT=%q: must be a port number between 0 and 65535", cfg.Port))
+	}
+	if (cfg.TLSCertFile == "") != (cfg.TLSKeyFile == "") {
+		errs = append(errs, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together"))
+	}
+	if !isSupportedLanguage(cfg.DefaultLang) {
+		errs = append(errs, fmt.Errorf("DEFAULT_LANG=%q: unsupported language code", cfg.DefaultLang))
+	}
+	if cfg.APIURL != "" {
+		var err error
+		if cfg.apiURLTemplate, err = parseAPIURLTemplate(cfg.APIURL); err != nil {
+			errs = append(errs, fmt.Errorf("WIKI_API_URL: %w", err))
+		}
+	}
+	switch cfg.CacheBackend {
+	case "memory":
+	case "redis":
+		if cfg.CacheSize > 0 {
+			var err error
+			if cfg.redisOptions, err = redis.ParseURL(cfg.RedisURL); err != nil {
+				errs = append(errs, fmt.Errorf("REDIS_URL: %w", err))
+			}
+		}
+	default:
+		errs = append(errs, fmt.Errorf("CACHE_BACKEND=%q: must be memory or redis", cfg.CacheBackend))
+	}
+	for _, d := range []struct {
+		key   string
+		value time.Duration
+	}{
+		{"WIKI_IDLE_CONN_TIMEOUT", cfg.IdleConnTimeout},
+		{"HTTP_READ_HEADER_TIMEOUT", cfg.HTTPReadHeaderTimeout},
+		{"HTTP_READ_TIMEOUT", cfg.HTTPReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", cfg.HTTPWriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", cfg.HTTPIdleTimeout},
+		{"SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout},
+		{"DRAIN_GRACE", cfg.DrainGrace},
+	} {
+		if d.value < 0 {
+			errs = append(errs, fmt.Errorf("%s=%q: must not be negative", d.key, os.Getenv(d.key)))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// staticSettings are the environment variables read once at startup, those
+// of StartupConfig. Changing them in CONFIG_FILE has no effect until the
+// server restarts.
+var staticSettings = envKeys(reflect.TypeFor[StartupConfig]())
+
+// envKeys returns the environment variables named by the env tags of the
+// struct type t, in field order.
+func envKeys(t reflect.Type) []string {
+	var keys []string
+	for i := range t.NumField() {
+		if key, _, _ := strings.Cut(t.Field(i).Tag.Get("env"), ","); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// startupSettings records the values of staticSettings the server started
+// with, to spot reloads that try to change them.
+var startupSettings map[string]string
+
+// configFile is the optional file of KEY=VALUE lines named by CONFIG_FILE.
+// Its entries override the process environment and, unlike it, are re-read
+// on SIGHUP.
+var configFile string
+
+// configFileOriginals holds the process environment value of every key
+// configFile has overridden (nil when it was unset), so a key removed from
+// the file falls back to it on the next reload.
+var configFileOriginals = map[string]*string{}
+
+// applyConfigFile loads configFile, if any, into the environment.
+func applyConfigFile() error {
+	if configFile == "" {
+		return nil
+	}
+	values, err := readConfigFile(configFile)
+	if err != nil {
+		return err
+	}
+	for key, orig := range configFileOriginals {
+		if _, ok := values[key]; ok {
+			continue
+		}
+		if orig == nil {
+			os.Unsetenv(key)
+		} else {
+			os.Setenv(key, *orig)
+		}
+		delete(configFileOriginals, key)
+	}
+	for key, v := range values {
+		if _, ok := configFileOriginals[key]; !ok {
+			if orig, set := os.LookupEnv(key); set {
+				configFileOriginals[key] = &orig
+			} else {
+				configFileOriginals[key] = nil
+			}
+		}
+		os.Setenv(key, v)
+	}
+	return nil
+}
+
+// readConfigFile parses a dotenv-style file: KEY=VALUE lines, optionally
+// prefixed with "export" and with the value in quotes. Blank lines and lines
+// starting with # are skipped.
+func readConfigFile(path string) (map[string]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, found := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE", path, n)
+		}
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		values[key] = value
+	}
+	return values, sc.Err()
+}
+
+// watchReload reloads the configuration on every SIGHUP until ctx ends.
+func watchReload(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			reloadConfig()
+		}
+	}
+}
+
+// reloadConfig re-reads CONFIG_FILE and the environment and swaps in the
+// new settings, logging each one that changed. An invalid value keeps the
+// current configuration; attempts to change static settings are logged and
+// ignored.
+func reloadConfig() {
+	if err := applyConfigFile(); err != nil {
+		logger.Error("config reload failed, keeping current settings", "error", err)
+		return
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		logger.Error("config reload failed, keeping current settings", "error", err)
+		return
+	}
+	for _, key := range staticSettings {
+		if os.Getenv(key) != startupSettings[key] {
+			logger.Warn("setting can't change at runtime, ignored until restart", "key", key)
+		}
+	}
+
+	old := setConfig(cfg)
+	changed := 0
+	ov, nv := reflect.ValueOf(old).Elem(), reflect.ValueOf(cfg).Elem()
+	for i := range nv.NumField() {
+		key, opt, _ := strings.Cut(nv.Type().Field(i).Tag.Get("env"), ",")
+		if key == "" {
+			continue
+		}
+		a, b := ov.Field(i).Interface(), nv.Field(i).Interface()
+		if reflect.DeepEqual(a, b) {
+			continue
+		}
+		changed++
+		if opt == "secret" {
+			logger.Info("setting changed", "key", key)
+		} else {
+			logger.Info("setting changed", "key", key, "old", a, "new", b)
+		}
+	}
+	logger.Info("configuration reloaded", "changed", changed)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Chunk sizes, in characters, for paginated /content responses.
+const (
+	defaultContentPageSize = 10000
+	maxContentPageSize     = 100000
+)
+
+// ContentResponse is the JSON body returned by /content. Length is the size
+// of the full article in characters, even when Content was truncated or is
+// a single chunk. Page, Pages and NextPage are set for paginated requests;
+// NextPage is omitted on the last chunk.
+type ContentResponse struct {
+	Topic     string `json:"topic"`
+	Title     string `json:"title"`
+	Lang      string `json:"lang"`
+	Content   string `json:"content"`
+	Length    int    `json:"length"`
+	Truncated bool   `json:"truncated"`
+	Page      int    `json:"page,omitempty"`
+	Pages     int    `json:"pages,omitempty"`
+	NextPage  int    `json:"next_page,omitempty"`
+}
+
+// contentHandler returns the entire plain-text article for a topic, read like
+// /lookup from the "topic" parameter (GET) or the body (POST). "maxchars"
+// truncates the content, marking the cut with an ellipsis. Alternatively
+// "page" and "pagesize" return one chunk of it at a time. Either way no more
+// than MAX_CONTENT_BYTES are sent.
+func contentHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Resolve the language, truncation limit or chunk, size limit
+	// handling and topic
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	maxChars, ok := positiveIntParam(w, r, "maxchars")
+	if !ok {
+		return
+	}
+	page, ok := positiveIntParam(w, r, "page")
+	if !ok {
+		return
+	}
+	pageSize, ok := intRangeParam(w, r, "pagesize", 0, 1, maxContentPageSize)
+	if !ok {
+		return
+	}
+	paginated := page > 0 || pageSize > 0
+	if paginated && maxChars > 0 {
+		writeError(w, r, http.StatusBadRequest, "INVALID_PARAMETER", "maxchars can't be combined with page or pagesize")
+		return
+	}
+	truncate, ok := boolParam(w, r, "truncate", true)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	// 2. Fetch the whole article
+	title, content, err := wikiClient.Content(r.Context(), topic, lang)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "content lookup")
+		return
+	}
+
+	// 3. Truncate or pick the requested chunk
+	resp := ContentResponse{Topic: topic, Title: title, Lang: lang, Content: content, Length: utf8.RuneCountInString(content)}
+	switch {
+	case paginated:
+		chunks := contentChunks(content, cmp.Or(pageSize, defaultContentPageSize))
+		page = max(page, 1)
+		if page > len(chunks) {
+			paramError{name: "page", msg: fmt.Sprintf("page must be between 1 and %d", len(chunks))}.between(1, float64(len(chunks))).write(w, r)
+			return
+		}
+		resp.Content, resp.Page, resp.Pages = chunks[page-1], page, len(chunks)
+		if page < len(chunks) {
+			resp.NextPage = page + 1
+		}
+	case maxChars > 0:
+		resp.Content, resp.Truncated = truncateChars(content, maxChars)
+	}
+	limited, cut, ok := limitContent(w, r, resp.Content, truncate)
+	if !ok {
+		return
+	}
+	resp.Content, resp.Truncated = limited, resp.Truncated || cut
+	writeJSONStreaming(w, r, http.StatusOK, &resp, &resp.Content)
+}
+
+// contentChunks splits s into chunks of at most size characters that
+// concatenate back to s. Each chunk ends after the last line break in its
+// window, or failing that the last space, as long as that keeps it over
+// half full; otherwise it is cut mid-word. The split depends only on s and
+// size, so page numbers stay stable across requests. An empty s is a single
+// empty chunk.
+func contentChunks(s string, size int) []string {
+	var chunks []string
+	for {
+		if utf8.RuneCountInString(s) <= size {
+			return append(chunks, s)
+		}
+		// Byte offset just past the first size characters
+		end := 0
+		for range size {
+			_, n := utf8.DecodeRuneInString(s[end:])
+			end += n
+		}
+		cut := end
+		if i := strings.LastIndexByte(s[:end], '\n'); i >= 0 && utf8.RuneCountInString(s[:i]) >= size/2 {
+			cut = i + 1
+		} else if i := strings.LastIndexByte(s[:end], ' '); i >= 0 && utf8.RuneCountInString(s[:i]) >= size/2 {
+			cut = i + 1
+		}
+		chunks = append(chunks, s[:cut])
+		s = s[cut:]
+	}
+}
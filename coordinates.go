@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// CoordinatesResponse is the JSON body returned by /coordinates.
+type CoordinatesResponse struct {
+	Topic string  `json:"topic"`
+	Title string  `json:"title"`
+	Lang  string  `json:"lang"`
+	Lat   float64 `json:"lat"`
+	Lon   float64 `json:"lon"`
+}
+
+// coordinatesHandler returns the geographic coordinates of a geotagged page.
+// Pages without coordinates, such as abstract concepts, yield a 404.
+func coordinatesHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, lat, lon, err := wikiClient.Coordinates(r.Context(), topic, lang)
+	if errors.Is(err, ErrNoCoordinates) {
+		writeError(w, r, http.StatusNotFound, "NO_COORDINATES", fmt.Sprintf("%q has no geographic coordinates", title))
+		return
+	}
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "coordinates lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, CoordinatesResponse{Topic: topic, Title: title, Lang: lang, Lat: lat, Lon: lon})
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefinitionResponse is the JSON body returned by /definition. Source is
+// "description" for the page's short description and "extract" when it
+// had none and the first sentence of the summary stands in. Project is set
+// when the page came from a Wikimedia project other than Wikipedia.
+type DefinitionResponse struct {
+	Topic       string `json:"topic"`
+	Title       string `json:"title"`
+	Lang        string `json:"lang"`
+	Project     string `json:"project,omitempty"`
+	Description string `json:"description"`
+	Source      string `json:"source"`
+}
+
+// definitionHandler returns the one-line description of a page, for
+// tooltips: its short description, or the first sentence of its summary for
+// pages without one. Pages with neither return 404 NO_SUMMARY. As with
+// /lookup, "project" reads the page from another Wikimedia project, such as
+// Wiktionary.
+func definitionHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	project, ok := requestProject(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := withProject(r.Context(), project)
+	title, description, err := wikiClient.Description(ctx, topic, lang)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "description lookup")
+		return
+	}
+	resp := DefinitionResponse{Topic: topic, Title: title, Lang: lang, Description: description, Source: "description"}
+	if project != defaultProject {
+		resp.Project = project
+	}
+
+	// Fall back to the first sentence of the extract
+	if strings.TrimSpace(description) == "" {
+		summary, err := fetchWikipediaSummary(ctx, title, lang)
+		if err != nil {
+			writeUpstreamError(w, r, err, topic, "summary lookup")
+			return
+		}
+		resp.Description, resp.Source = truncateSentences(strings.TrimSpace(summary.Summary), 1), "extract"
+	}
+	if resp.Description == "" {
+		writeError(w, r, http.StatusNotFound, "NO_SUMMARY", fmt.Sprintf("no description available for %q", title))
+		return
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// scriptLanguages maps the writing systems used by a single supported
+// edition to its language code.
+var scriptLanguages = []struct {
+	script *unicode.RangeTable
+	lang   string
+}{
+	{unicode.Hangul, "ko"},
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Han, "zh"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Arabic, "ar"},
+	{unicode.Cyrillic, "ru"},
+}
+
+// letterHints are letters particular to one supported language within its
+// script. They're checked in order, so the more distinctive ones come first.
+var letterHints = []struct {
+	letters string
+	lang    string
+}{
+	{"іїєґ", "uk"},
+	{"پچژگ", "fa"},
+	{"ơưđạảấầẩẫậắằẳẵặẹẻẽếềểễệỉịọỏốồổỗộớờởỡợụủứừửữựỳỵỷỹ", "vi"},
+	{"őű", "hu"},
+	{"řěůť", "cs"},
+	{"łąęśźżń", "pl"},
+	{"ățș", "ro"},
+	{"ğışİ", "tr"},
+	{"ãõ", "pt"},
+	{"ñ¿¡", "es"},
+	{"ß", "de"},
+}
+
+// detectLanguage guesses the edition a topic belongs to from its script; for
+// Latin-script topics, only letters particular to one language decide. ok
+// is false when the topic gives no clear hint, e.g. plain ASCII.
+func detectLanguage(topic string) (lang string, ok bool) {
+	lower := strings.ToLower(topic)
+	for _, h := range letterHints {
+		if strings.ContainsAny(lower, h.letters) {
+			return h.lang, true
+		}
+	}
+
+	// Japanese mixes kana with Han characters, so any kana wins over Han
+	counts := make(map[string]int)
+	for _, r := range topic {
+		for _, s := range scriptLanguages {
+			if unicode.Is(s.script, r) {
+				counts[s.lang]++
+				break
+			}
+		}
+	}
+	if counts["ja"] > 0 {
+		return "ja", true
+	}
+	best := 0
+	for _, s := range scriptLanguages {
+		if n := counts[s.lang]; n > best {
+			lang, best = s.lang, n
+		}
+	}
+	return lang, best > 0
+}
@@ -0,0 +1,43 @@
+package main
+
+import "net/http"
+
+// DisambiguationCheckResponse is the JSON body returned by
+// /detect-disambiguation. Options lists the candidate pages, and is left
+// out for pages that aren't disambiguation pages.
+type DisambiguationCheckResponse struct {
+	Topic            string   `json:"topic"`
+	Title            string   `json:"title"`
+	Lang             string   `json:"lang"`
+	IsDisambiguation bool     `json:"is_disambiguation"`
+	Options          []string `json:"options,omitempty"`
+}
+
+// detectDisambiguationHandler reports whether a title names a
+// disambiguation page and lists its candidates, without fetching a
+// summary, so clients can offer a choice before looking one up. Unknown
+// titles yield a 404.
+func detectDisambiguationHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, options, err := wikiClient.Disambiguation(r.Context(), topic, lang)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "disambiguation check")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, DisambiguationCheckResponse{
+		Topic:            topic,
+		Title:            title,
+		Lang:             lang,
+		IsDisambiguation: options != nil,
+		Options:          options,
+	})
+}
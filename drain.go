@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// draining is set once the server has been asked to drain: /readyz fails
+// so load balancers stop sending traffic, while requests keep being served
+// until DRAIN_GRACE has passed and shutdown begins.
+var draining atomic.Bool
+
+// drainRequested is closed by the first call to startDrain.
+var (
+	drainRequested = make(chan struct{})
+	drainOnce      sync.Once
+)
+
+// startDrain puts the server into the draining state. Calls after the
+// first do nothing; reason says what asked for it, for the log.
+func startDrain(reason string) {
+	drainOnce.Do(func() {
+		draining.Store(true)
+		logger.Info("draining: /readyz now answers 503, shutdown follows after the grace period", "reason", reason)
+		close(drainRequested)
+	})
+}
+
+// watchDrain returns a context derived from ctx that is also cancelled
+// grace (DRAIN_GRACE) after a drain starts, through drainSignals (SIGUSR1)
+// or POST /admin/drain, so the caller shuts down as it would on SIGTERM.
+func watchDrain(ctx context.Context, grace time.Duration) context.Context {
+	ctx, cancel := context.WithCancel(ctx)
+	sig := make(chan os.Signal, 1)
+	if len(drainSignals) > 0 {
+		signal.Notify(sig, drainSignals...)
+	}
+	go func() {
+		defer signal.Stop(sig)
+		select {
+		case s := <-sig:
+			startDrain(s.String())
+		case <-drainRequested:
+		case <-ctx.Done():
+			return
+		}
+		select {
+		case <-time.After(grace):
+			logger.Info("drain grace period over", "grace", grace)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx
+}
+
+// adminDrainHandler starts a drain and answers 202. It needs API_KEYS to
+// be set, so that only holders of a key can take the server out of
+// rotation.
+func adminDrainHandler(w http.ResponseWriter, r *http.Request) {
+	if len(currentConfig().APIKeys) == 0 {
+		writeError(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "/admin/drain requires API_KEYS to be set")
+		return
+	}
+	startDrain("POST /admin/drain")
+	writeJSON(w, r, http.StatusAccepted, ProbeResponse{Status: "draining"})
+}
@@ -0,0 +1,7 @@
+package main
+
+import "os"
+
+// drainSignals is empty on Windows, which has no SIGUSR1; use POST
+// /admin/drain instead.
+var drainSignals []os.Signal
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// EntityResponse is the JSON body returned by /entity.
+type EntityResponse struct {
+	Topic       string       `json:"topic"`
+	Title       string       `json:"title"`
+	Lang        string       `json:"lang"`
+	ID          string       `json:"id"`
+	Description string       `json:"description"`
+	Types       []EntityType `json:"types"`
+}
+
+// entityHandler returns the Wikidata item linked to a page: its short
+// description and what it is an instance of, such as human, city or film,
+// labelled in the requested language. Pages without an item yield a 404.
+func entityHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, entity, err := wikiClient.Entity(r.Context(), topic, lang)
+	if errors.Is(err, ErrNoEntity) {
+		writeError(w, r, http.StatusNotFound, "NO_ENTITY", fmt.Sprintf("%q is not linked to a Wikidata item", title))
+		return
+	}
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "entity lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, EntityResponse{
+		Topic:       topic,
+		Title:       title,
+		Lang:        lang,
+		ID:          entity.ID,
+		Description: entity.Description,
+		Types:       entity.Types,
+	})
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envString returns the value of the environment variable key, or fallback
+// when it is unset.
+func envString(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// lookupInt returns the integer value of the environment variable key, or
+// fallback when it is unset. An unparsable value is reported as an error,
+// naming the variable, along with fallback.
+func lookupInt(key string, fallback int) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fallback, fmt.Errorf("%s=%q: must be an integer", key, v)
+	}
+	return n, nil
+}
+
+// lookupDuration returns the duration value (e.g. "90s", "1h") of the
+// environment variable key, or fallback when it is unset, reporting an
+// unparsable value like lookupInt.
+func lookupDuration(key string, fallback time.Duration) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fallback, fmt.Errorf("%s=%q: must be a duration such as 30s or 1h", key, v)
+	}
+	return d, nil
+}
+
+// lookupBool returns the boolean value ("true", "false", "1", "0", ...) of
+// the environment variable key, or fallback when it is unset, reporting an
+// unparsable value like lookupInt.
+func lookupBool(key string, fallback bool) (bool, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fallback, fmt.Errorf("%s=%q: must be true or false", key, v)
+	}
+	return b, nil
+}
+
+// envList returns the comma-separated values of the environment variable
+// key with surrounding whitespace and empty entries removed.
+func envList(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
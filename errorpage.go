@@ -0,0 +1,85 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// htmlErrorPages enables the HTML error page for browsers
+// (HTML_ERROR_PAGES); when false every client gets JSON errors.
+var htmlErrorPages = true
+
+// errorPage renders an ErrorResponse for people rather than programs.
+var errorPage = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Status}} {{.StatusText}} · Wikipedia Agent</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #f8f9fa; color: #202122; margin: 0; }
+main { max-width: 36rem; margin: 12vh auto; padding: 2rem; background: #fff; border: 1px solid #c8ccd1; border-radius: 4px; }
+h1 { font-size: 1.5rem; margin-top: 0; }
+.status { color: #72777d; font-weight: normal; }
+.detail { font-family: ui-monospace, monospace; background: #f8f9fa; padding: .5rem .75rem; border-radius: 2px; overflow-wrap: anywhere; }
+.meta { color: #72777d; font-size: .85rem; }
+a { color: #36c; }
+</style>
+</head>
+<body>
+<main>
+<h1><span class="status">{{.Status}}</span> {{.StatusText}}</h1>
+<p>{{.Friendly}}</p>
+<p class="detail">{{.Error}}</p>
+<p class="meta">Error code {{.Code}}{{with .RequestID}} · request {{.}}{{end}}</p>
+<p><a href="{{.Back}}">{{.BackText}}</a></p>
+</main>
+</body>
+</html>
+`))
+
+// friendlyMessages give the reader of errorPage a plain explanation per
+// status class.
+var friendlyMessages = map[int]string{
+	4: "Something about this request isn't right. Check the address and try again.",
+	5: "Something went wrong on our side or at Wikipedia. Please try again in a moment.",
+}
+
+// prefersHTML reports whether the client, typically a browser, would rather
+// read an HTML page than JSON.
+func prefersHTML(r *http.Request) bool {
+	return htmlErrorPages && negotiateContentType(r.Header.Get("Accept"), "application/json", "text/html") == "text/html"
+}
+
+// writeErrorPage writes resp as the HTML error page with the given status.
+// The link back leads to the referring page when it is on this server, and
+// to the API documentation otherwise.
+func writeErrorPage(w http.ResponseWriter, r *http.Request, status int, resp ErrorResponse) {
+	friendly := friendlyMessages[status/100]
+	switch status {
+	case http.StatusNotFound:
+		friendly = "We couldn't find what you were looking for."
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		friendly = "The service is busy right now. Please wait a little and try again."
+	}
+	back, backText := "/docs", "Go to the API documentation"
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host && ref.Path != "" {
+		back, backText = ref.RequestURI(), "Go back"
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	errorPage.Execute(w, map[string]string{
+		"Status":     strconv.Itoa(status),
+		"StatusText": http.StatusText(status),
+		"Friendly":   friendly,
+		"Error":      resp.Error,
+		"Code":       resp.Code,
+		"RequestID":  resp.RequestID,
+		"Back":       back,
+		"BackText":   backText,
+	})
+}
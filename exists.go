@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ExistsResponse is the JSON body returned by /exists. Title is the
+// canonical title the topic resolved to, after redirects.
+type ExistsResponse struct {
+	Topic  string `json:"topic"`
+	Lang   string `json:"lang"`
+	Exists bool   `json:"exists"`
+	Title  string `json:"title,omitempty"`
+}
+
+// existsHandler reports whether a topic names an existing page without
+// fetching its content: 200 when it does, 404 when it doesn't. HEAD
+// requests get the status alone.
+func existsHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, err := wikiClient.Resolve(r.Context(), topic, lang)
+	if errors.Is(err, ErrPageNotFound) {
+		writeJSON(w, r, http.StatusNotFound, ExistsResponse{Topic: topic, Lang: lang, Exists: false})
+		return
+	}
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "existence check")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, ExistsResponse{Topic: topic, Lang: lang, Exists: true, Title: title})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// TraceStep is one decision /lookup made while resolving a topic, listed
+// under "trace" with "explain=true". Step names the stage, such as "cache"
+// or "redirect", and Detail says what happened.
+type TraceStep struct {
+	Step   string `json:"step"`
+	Detail string `json:"detail"`
+}
+
+// lookupTrace collects the steps of one lookup. A nil *lookupTrace records
+// nothing, so the handler notes every step without checking whether
+// explain is on.
+type lookupTrace struct {
+	steps []TraceStep
+}
+
+// add records a step whose detail is formatted like fmt.Sprintf.
+func (t *lookupTrace) add(step, format string, args ...any) {
+	if t != nil {
+		t.steps = append(t.steps, TraceStep{Step: step, Detail: fmt.Sprintf(format, args...)})
+	}
+}
+
+// langSource describes where the edition requestLang picked for r came
+// from.
+func (t *lookupTrace) langSource(r *http.Request, lang string) {
+	switch _, negotiated := negotiateLanguage(r.Header.Get("Accept-Language")); {
+	case r.URL.Query().Get("lang") != "":
+		t.add("lang", "%s from the lang parameter", lang)
+	case negotiated:
+		t.add("lang", "%s from the Accept-Language header", lang)
+	default:
+		t.add("lang", "%s, the default edition, as neither lang nor Accept-Language picked one", lang)
+	}
+}
+
+// fetched records the outcome of fetching the summary cached under key:
+// whether the cache answered, and what Wikipedia said otherwise.
+func (t *lookupTrace) fetched(key cacheKey, result PageSummary, err error) {
+	var notArticle *NotArticleError
+	switch {
+	case errors.Is(err, ErrPageNotFound):
+		t.add("fetch", "no page for %s in %s", key, key.edition())
+	case errors.As(err, &notArticle):
+		t.add("fetch", "%s is a %s page, so no summary was fetched", key, notArticle.Namespace)
+	case err != nil:
+		t.add("fetch", "fetching %s from %s failed: %v", key, key.edition(), err)
+	case result.Cached:
+		t.add("cache", "hit for %s (key %s)", key, key.ID())
+	case summaryCache != nil:
+		t.add("cache", "miss for %s (key %s); fetched %q from %s", key, key.ID(), result.Title, key.edition())
+	default:
+		t.add("fetch", "fetched %q from %s; the cache is disabled", result.Title, key.edition())
+	}
+}
+
+// list returns the recorded steps, or nil for a nil trace.
+func (t *lookupTrace) list() []TraceStep {
+	if t == nil {
+		return nil
+	}
+	return t.steps
+}
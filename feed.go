@@ -0,0 +1,43 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// FeedResponse is the JSON body returned by /feed. Featured and TopRead are
+// omitted for days whose feed leaves them out.
+type FeedResponse struct {
+	Date     string           `json:"date"`
+	Lang     string           `json:"lang"`
+	Featured *FeaturedArticle `json:"featured,omitempty"`
+	TopRead  []string         `json:"top_read,omitempty"`
+}
+
+// feedHandler returns a day's featured article and most-read article
+// titles from Wikipedia's featured content feed, for homepage widgets.
+// "date" (YYYY-MM-DD) picks the day, today (UTC) by default.
+func feedHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	date, ok := dateParam(w, r, "date", time.Now().UTC())
+	if !ok {
+		return
+	}
+	day := date.Format(time.DateOnly)
+
+	article, topRead, err := wikiClient.Featured(r.Context(), lang, date)
+	if errors.Is(err, ErrNoFeed) {
+		writeError(w, r, http.StatusNotFound, "NO_FEED", fmt.Sprintf("the %s edition has no featured content feed for %s", lang, day))
+		return
+	}
+	if err != nil {
+		writeUpstreamError(w, r, err, day, "featured feed lookup")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, FeedResponse{Date: day, Lang: lang, Featured: article, TopRead: topRead})
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// selectFields trims v, a JSON-encodable struct, to the top-level JSON
+// fields named in the comma-separated list, for partial responses. Unknown
+// names are ignored, and an empty list returns v unchanged.
+func selectFields(v any, list string) any {
+	if strings.TrimSpace(list) == "" {
+		return v
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(b, &all); err != nil {
+		return v
+	}
+	selected := make(map[string]json.RawMessage)
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if raw, ok := all[name]; ok {
+			selected[name] = raw
+		}
+	}
+	return selected
+}
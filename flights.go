@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// summaryFlights merges concurrent fetches of the same summary into one
+// upstream call, so a spike of requests for a trending topic costs a single
+// lookup. flightRefs counts the callers waiting on each shared call.
+var (
+	summaryFlights singleflight.Group
+	flightsMu      sync.Mutex
+	flightRefs     = make(map[string]*summaryFlight)
+)
+
+// summaryFlight is the context a shared fetch runs under and the number of
+// callers still waiting for it.
+type summaryFlight struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// flightKey identifies the fetch for k among summaryFlights.
+func (k cacheKey) flightKey() string {
+	return fmt.Sprintf("%q\x00%d\x00%s\x00%s\x00%t", k.topic, k.pageID, k.lang, k.project, k.rest)
+}
+
+// sharedFetch calls fetch for key once for all concurrent callers and hands
+// each of them its result. The call runs under a context of its own, with
+// the values of the first caller's ctx, that is cancelled only when every
+// caller has given up waiting, so one client disconnecting doesn't fail the
+// others. A result, error or not, only reaches the callers waiting at that
+// moment; the next caller starts a new fetch.
+func sharedFetch(ctx context.Context, key cacheKey, fetch func(context.Context) (PageSummary, error)) (PageSummary, error) {
+	k := key.flightKey()
+	flightsMu.Lock()
+	f := flightRefs[k]
+	if f == nil {
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &summaryFlight{ctx: fctx, cancel: cancel}
+		flightRefs[k] = f
+	}
+	f.waiters++
+	ch := summaryFlights.DoChan(k, func() (any, error) {
+		return fetch(f.ctx)
+	})
+	flightsMu.Unlock()
+	defer leaveFlight(k, f)
+
+	select {
+	case res := <-ch:
+		summary, _ := res.Val.(PageSummary)
+		return summary, res.Err
+	case <-ctx.Done():
+		return PageSummary{}, ctx.Err()
+	}
+}
+
+// leaveFlight drops a waiter from f. The last one to leave retires f,
+// cancelling the fetch if it is still running; Forget makes sure no later
+// caller joins that cancelled call.
+func leaveFlight(k string, f *summaryFlight) {
+	flightsMu.Lock()
+	defer flightsMu.Unlock()
+	if f.waiters--; f.waiters > 0 {
+		return
+	}
+	if flightRefs[k] == f {
+		delete(flightRefs, k)
+		summaryFlights.Forget(k)
+	}
+	f.cancel()
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+var gzipWriterPool = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// withGzip compresses responses for clients that accept gzip once the body
+// grows past GZIP_MIN_SIZE. Smaller bodies, like /health, are sent as-is, and
+// protocol upgrades such as WebSocket pass through untouched.
+func withGzip(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipResponseWriter{ResponseWriter: w, status: http.StatusOK, minSize: currentConfig().GzipMinSize}
+		defer gw.Close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.TrimSpace(coding) != "gzip" {
+			continue
+		}
+		return strings.ReplaceAll(params, " ", "") != "q=0"
+	}
+	return false
+}
+
+// gzipResponseWriter buffers the start of the body until it knows whether
+// the response is big enough to compress, then either streams it through a
+// gzip.Writer or writes it unchanged.
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	status  int
+	minSize int
+	buf     bytes.Buffer
+	gz      *gzip.Writer
+	decided bool
+}
+
+func (g *gzipResponseWriter) WriteHeader(code int) {
+	g.status = code
+}
+
+func (g *gzipResponseWriter) Write(p []byte) (int, error) {
+	if g.decided {
+		if g.gz != nil {
+			return g.gz.Write(p)
+		}
+		return g.ResponseWriter.Write(p)
+	}
+	g.buf.Write(p)
+	if g.buf.Len() >= g.minSize {
+		if err := g.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Flush commits to the current decision so streamed responses reach the
+// client promptly.
+func (g *gzipResponseWriter) Flush() {
+	if !g.decided {
+		g.decide(g.buf.Len() >= g.minSize)
+	}
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	http.NewResponseController(g.ResponseWriter).Flush()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (g *gzipResponseWriter) Unwrap() http.ResponseWriter {
+	return g.ResponseWriter
+}
+
+// decide writes the status line and the buffered body, compressing from
+// here on when compress is true and the handler didn't encode the body
+// itself.
+func (g *gzipResponseWriter) decide(compress bool) error {
+	g.decided = true
+	h := g.ResponseWriter.Header()
+	if h.Get("Content-Encoding") != "" || g.status == http.StatusNoContent || g.status == http.StatusNotModified {
+		compress = false
+	}
+	if compress {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		g.gz = gzipWriterPool.Get().(*gzip.Writer)
+		g.gz.Reset(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+
+	if g.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if g.gz != nil {
+		_, err = g.gz.Write(g.buf.Bytes())
+	} else {
+		_, err = g.ResponseWriter.Write(g.buf.Bytes())
+	}
+	g.buf.Reset()
+	return err
+}
+
+// Close sends whatever is still buffered and finishes the gzip stream.
+func (g *gzipResponseWriter) Close() {
+	if !g.decided {
+		g.decide(false)
+	}
+	if g.gz != nil {
+		g.gz.Close()
+		gzipWriterPool.Put(g.gz)
+		g.gz = nil
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+)
+
+// Limits for the number of /history revisions.
+const (
+	defaultHistoryLimit = 10
+	maxHistoryLimit     = 50
+)
+
+// HistoryResponse is the JSON body returned by /history, with Revisions
+// ordered newest first.
+type HistoryResponse struct {
+	Topic     string     `json:"topic"`
+	Title     string     `json:"title"`
+	Lang      string     `json:"lang"`
+	Revisions []Revision `json:"revisions"`
+}
+
+// historyHandler returns the metadata of a page's most recent edits, for
+// "last updated" indicators. "limit" caps the number of revisions (default
+// 10, max 50).
+func historyHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	limit, ok := intRangeParam(w, r, "limit", defaultHistoryLimit, 1, maxHistoryLimit)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, revisions, err := wikiClient.History(r.Context(), topic, lang, limit)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "history lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, HistoryResponse{Topic: topic, Title: title, Lang: lang, Revisions: revisions})
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// maxRequestsPerHost bounds the upstream requests in flight to each
+// Wikipedia host at once (WIKI_MAX_REQUESTS_PER_HOST), across every handler
+// and batch worker; further requests queue until a slot frees up. 0 means
+// no limit. Unlike the rate limiter, which fails fast, the gate only delays.
+var maxRequestsPerHost = 0
+
+// hostLimitTransport is an http.RoundTripper that holds one of its host's
+// maxRequestsPerHost slots for each request, from sending it until the
+// response body is closed.
+type hostLimitTransport struct {
+	next  http.RoundTripper
+	mu    sync.Mutex
+	slots map[string]chan struct{}
+}
+
+// limitHosts wraps next in a hostLimitTransport.
+func limitHosts(next http.RoundTripper) *hostLimitTransport {
+	return &hostLimitTransport{next: next, slots: make(map[string]chan struct{})}
+}
+
+// hostSlots returns the semaphore for host, creating it on first use.
+func (t *hostLimitTransport) hostSlots(host string) chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	slots, ok := t.slots[host]
+	if !ok {
+		slots = make(chan struct{}, maxRequestsPerHost)
+		t.slots[host] = slots
+	}
+	return slots
+}
+
+func (t *hostLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if maxRequestsPerHost <= 0 {
+		return t.next.RoundTrip(req)
+	}
+	slots := t.hostSlots(req.URL.Host)
+	select {
+	case slots <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	release := sync.OnceFunc(func() { <-slots })
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	res.Body = &releasingBody{ReadCloser: res.Body, release: release}
+	return res, nil
+}
+
+// releasingBody frees its request's host slot when closed.
+type releasingBody struct {
+	io.ReadCloser
+	release func()
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// htmlCSP is the Content-Security-Policy sent with /html responses. The
+// markup is MediaWiki's own sanitized output, but it is still third-party
+// content: the policy stops any script from running should a page ever
+// carry one, while letting images and inline styles render.
+const htmlCSP = "default-src 'none'; img-src https: data:; style-src 'unsafe-inline'; sandbox"
+
+// htmlHandler returns the rendered article as an HTML fragment, or just the
+// section named by the "section" query parameter. Links in the markup are
+// relative to the Wikipedia edition (e.g. /wiki/Go), so embedders should
+// set a <base> or rewrite them.
+func htmlHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	section := r.URL.Query().Get("section")
+	truncate, ok := boolParam(w, r, "truncate", true)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	_, html, err := wikiClient.HTML(r.Context(), topic, lang, section)
+	var missing *SectionNotFoundError
+	if errors.As(err, &missing) {
+		writeError(w, r, http.StatusNotFound, "SECTION_NOT_FOUND", fmt.Sprintf("section %q not found; available sections: %s", section, strings.Join(missing.Available, ", ")))
+		return
+	}
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "HTML lookup")
+		return
+	}
+
+	html, truncated, ok := limitContent(w, r, html, truncate)
+	if !ok {
+		return
+	}
+	if truncated {
+		w.Header().Set("X-Content-Truncated", "true")
+	}
+	w.Header().Set("Content-Security-Policy", htmlCSP)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	writeCacheable(w, r, "text/html; charset=utf-8", []byte(html))
+}
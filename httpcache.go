@@ -0,0 +1,77 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// writeCacheable writes body as a cacheable 200 response: it sets an ETag
+// derived from the body and a Cache-Control max-age of CACHE_MAX_AGE
+// seconds (0 makes clients revalidate every time), and answers 304 Not
+// Modified when the request's If-None-Match already has that ETag. A
+// Cache-Control header the handler already set is kept.
+func writeCacheable(w http.ResponseWriter, r *http.Request, contentType string, body []byte) {
+	sum := sha256.Sum256(body)
+	// Weak, because withGzip may change the bytes but not the meaning
+	etag := `W/"` + hex.EncodeToString(sum[:16]) + `"`
+
+	h := w.Header()
+	h.Set("ETag", etag)
+	if h.Get("Cache-Control") == "" {
+		h.Set("Cache-Control", fmt.Sprintf("public, max-age=%d", currentConfig().CacheMaxAge))
+	}
+	h.Add("Vary", "Accept")
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+// writeCacheableJSON is writeCacheable for a JSON-encoded v. With a
+// non-empty callback the JSON is wrapped in a JSONP call to it instead.
+func writeCacheableJSON(w http.ResponseWriter, r *http.Request, v any, callback string) {
+	body, err := marshalJSON(r, v)
+	if err != nil {
+		writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", fmt.Sprintf("encoding response: %v", err))
+		return
+	}
+	if callback != "" {
+		// The leading comment defuses content-sniffing attacks on the callback
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		writeCacheable(w, r, "application/javascript; charset=utf-8", fmt.Appendf(nil, "/**/%s(%s);\n", callback, body))
+		return
+	}
+	writeCacheable(w, r, "application/json", append(body, '\n'))
+}
+
+// etagMatches reports whether an If-None-Match header value lists etag,
+// using the weak comparison RFC 9110 prescribes for If-None-Match.
+func etagMatches(header, etag string) bool {
+	if header == "" {
+		return false
+	}
+	want := strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == want {
+			return true
+		}
+	}
+	return false
+}
+
+// requestsNoCache reports whether the request's Cache-Control header has
+// the no-cache directive, asking for a response fresh from the origin.
+func requestsNoCache(r *http.Request) bool {
+	for _, directive := range strings.Split(r.Header.Get("Cache-Control"), ",") {
+		if strings.EqualFold(strings.TrimSpace(directive), "no-cache") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Bounds for stored idempotent responses: how many keys are remembered at
+// once, the longest key accepted and the largest body kept for replay.
+const (
+	maxIdempotencyKeys    = 10000
+	maxIdempotencyKeyLen  = 255
+	maxIdempotentBodySize = 1 << 20
+)
+
+// idempotentResponse is the outcome of the first request sent with an
+// Idempotency-Key. done is closed once it has been recorded; stored reports
+// whether it may be replayed, which server errors and oversized bodies
+// may not.
+type idempotentResponse struct {
+	fingerprint [sha256.Size]byte
+	done        chan struct{}
+	stored      bool
+	status      int
+	header      http.Header
+	body        []byte
+	expires     time.Time
+}
+
+// idempotencyStore holds the responses to recent requests carrying an
+// Idempotency-Key, keyed by the key's scope and the key itself.
+var idempotencyStore = struct {
+	mu      sync.Mutex
+	entries map[string]*idempotentResponse
+}{entries: make(map[string]*idempotentResponse)}
+
+// withIdempotency makes POST requests with an Idempotency-Key header safe to
+// retry: the first response for a key is stored for IDEMPOTENCY_TTL and
+// replayed, with an Idempotent-Replayed header, to later requests with the
+// same key instead of running the handler again. A retry arriving while the
+// first request is still running waits for its response. Keys are scoped to
+// the API key and path, and reusing one for a different query, body or
+// negotiated variant (Accept, Accept-Language) is rejected with 422.
+// Server errors aren't stored, so their retries run again.
+func withIdempotency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get("Idempotency-Key")
+		ttl := currentConfig().IdempotencyTTL
+		if r.Method != http.MethodPost || key == "" || ttl <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			writeError(w, r, http.StatusBadRequest, "INVALID_PARAMETER", "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		// Fingerprint the request so a key can't be reused for another one,
+		// including the headers that pick the response's format and
+		// edition; the body is put back for the handler to read
+		body, _ := io.ReadAll(io.LimitReader(r.Body, maxIdempotentBodySize))
+		r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+		fingerprint := sha256.Sum256(slices.Concat(
+			[]byte(r.URL.RawQuery), []byte{0},
+			[]byte(r.Header.Get("Accept")), []byte{0},
+			[]byte(r.Header.Get("Accept-Language")), []byte{0},
+			body,
+		))
+
+		scope := idempotencyScope(r) + key
+		entry, first := claimIdempotencyKey(scope, fingerprint)
+		switch {
+		case entry == nil:
+			// The store is full of live keys; serve without a guarantee
+			next.ServeHTTP(w, r)
+			return
+		case entry.fingerprint != fingerprint:
+			writeError(w, r, http.StatusUnprocessableEntity, "IDEMPOTENCY_KEY_REUSED", "Idempotency-Key was already used for a different request")
+			return
+		case !first:
+			select {
+			case <-entry.done:
+			case <-r.Context().Done():
+				return
+			}
+			if !entry.stored {
+				next.ServeHTTP(w, r)
+				return
+			}
+			for k, v := range entry.header {
+				w.Header()[k] = slices.Clone(v)
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		}
+
+		before := w.Header().Clone()
+		rec := &idempotencyRecorder{ResponseWriter: w, status: http.StatusOK}
+		completed := false
+		defer func() { finishIdempotentResponse(scope, entry, rec, before, completed, ttl) }()
+		next.ServeHTTP(rec, r)
+		completed = true
+	})
+}
+
+// idempotencyScope is the namespace of a request's Idempotency-Key: a hash
+// of the API key it was sent with, so clients can't replay each other's
+// responses, and its path.
+func idempotencyScope(r *http.Request) string {
+	apiKey := r.Header.Get("X-API-Key")
+	if apiKey == "" {
+		apiKey = r.URL.Query().Get("api_key")
+	}
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8]) + r.URL.Path + "\x00"
+}
+
+// claimIdempotencyKey returns the entry for scope, creating it, with first
+// set, when the key is new or its stored response has expired. It returns
+// nil when maxIdempotencyKeys live keys are already stored.
+func claimIdempotencyKey(scope string, fingerprint [sha256.Size]byte) (entry *idempotentResponse, first bool) {
+	s := &idempotencyStore
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if e, ok := s.entries[scope]; ok && (e.expires.IsZero() || now.Before(e.expires)) {
+		return e, false
+	}
+	if len(s.entries) >= maxIdempotencyKeys {
+		for k, e := range s.entries {
+			if !e.expires.IsZero() && !now.Before(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		if len(s.entries) >= maxIdempotencyKeys {
+			return nil, false
+		}
+	}
+	entry = &idempotentResponse{fingerprint: fingerprint, done: make(chan struct{})}
+	s.entries[scope] = entry
+	return entry, true
+}
+
+// finishIdempotentResponse records the response rec captured for entry and
+// wakes the requests waiting for it. Responses that can't be replayed, from
+// a handler that panicked, a server error or an oversized body, are
+// dropped so the key can be used again.
+func finishIdempotentResponse(scope string, entry *idempotentResponse, rec *idempotencyRecorder, before http.Header, completed bool, ttl time.Duration) {
+	s := &idempotencyStore
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if completed && rec.status < http.StatusInternalServerError && !rec.overflow {
+		entry.stored = true
+		entry.status = rec.status
+		entry.body = rec.body.Bytes()
+		entry.header = make(http.Header)
+		for k, v := range rec.Header() {
+			if !slices.Equal(v, before[k]) {
+				entry.header[k] = slices.Clone(v)
+			}
+		}
+		entry.expires = time.Now().Add(ttl)
+	} else {
+		delete(s.entries, scope)
+	}
+	close(entry.done)
+}
+
+// idempotencyRecorder passes a response through while keeping a copy of
+// its status and body, up to maxIdempotentBodySize, for replay.
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+	overflow    bool
+}
+
+func (rec *idempotencyRecorder) WriteHeader(code int) {
+	if !rec.wroteHeader {
+		rec.status, rec.wroteHeader = code, true
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *idempotencyRecorder) Write(p []byte) (int, error) {
+	rec.wroteHeader = true
+	if !rec.overflow {
+		if rec.body.Len()+len(p) > maxIdempotentBodySize {
+			rec.overflow = true
+			rec.body = bytes.Buffer{}
+		} else {
+			rec.body.Write(p)
+		}
+	}
+	return rec.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+)
+
+// ImagesResponse is the JSON body returned by /images.
+type ImagesResponse struct {
+	Topic  string   `json:"topic"`
+	Title  string   `json:"title"`
+	Lang   string   `json:"lang"`
+	Images []string `json:"images"`
+}
+
+// imagesHandler returns the URLs of the images used on a page. With
+// "first=true" it returns only the page's lead image. Pages without images
+// yield an empty list.
+func imagesHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	first, ok := boolParam(w, r, "first", false)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	var (
+		title  string
+		images = []string{}
+		err    error
+	)
+	if first {
+		var lead string
+		title, lead, err = wikiClient.LeadImage(r.Context(), topic, lang)
+		if lead != "" {
+			images = append(images, lead)
+		}
+	} else {
+		var all []string
+		title, all, err = wikiClient.Images(r.Context(), topic, lang)
+		images = append(images, all...)
+	}
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "images lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, ImagesResponse{Topic: topic, Title: title, Lang: lang, Images: images})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// inFlight bounds the number of requests served at once (MAX_IN_FLIGHT).
+// It is nil when the limit is disabled.
+var inFlight chan struct{}
+
+// queueSlots bounds the number of requests waiting for an inFlight slot
+// (MAX_QUEUE). It is nil when requests aren't queued.
+var queueSlots chan struct{}
+
+// queueTimeout is how long a queued request waits for an inFlight slot
+// before it is shed (QUEUE_TIMEOUT).
+var queueTimeout = time.Second
+
+// unlimitedPaths bypass the in-flight limit so health probes still answer
+// while the server is saturated.
+var unlimitedPaths = map[string]bool{
+	"/health": true,
+	"/livez":  true,
+	"/readyz": true,
+}
+
+// withConcurrencyLimit serves at most cap(inFlight) requests at once. A
+// request finding them all busy waits in the queue for up to queueTimeout;
+// when the queue is full too, or the wait times out, it is shed with 503
+// and a Retry-After header instead of queueing without bound.
+func withConcurrencyLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if inFlight == nil || unlimitedPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+		select {
+		case inFlight <- struct{}{}:
+			defer func() { <-inFlight }()
+			next.ServeHTTP(w, r)
+			return
+		default:
+		}
+
+		select {
+		case queueSlots <- struct{}{}:
+		default:
+			// A nil queueSlots blocks, so without a queue this sheds at once
+			shed(w, r, "queue_full")
+			return
+		}
+		requestsQueuedTotal.Inc()
+		timer := time.NewTimer(queueTimeout)
+		defer timer.Stop()
+		select {
+		case inFlight <- struct{}{}:
+			<-queueSlots
+			defer func() { <-inFlight }()
+			requestsServedAfterWaitTotal.Inc()
+			next.ServeHTTP(w, r)
+		case <-timer.C:
+			<-queueSlots
+			shed(w, r, "timeout")
+		case <-r.Context().Done():
+			// The client gave up waiting; there's no one to answer
+			<-queueSlots
+		}
+	})
+}
+
+// shed rejects a request the concurrency limit has no room for, counting
+// it under reason.
+func shed(w http.ResponseWriter, r *http.Request, reason string) {
+	requestsShedTotal.WithLabelValues(reason).Inc()
+	w.Header().Set("Retry-After", "1")
+	writeError(w, r, http.StatusServiceUnavailable, "OVERLOADED", "server is busy, retry later")
+}
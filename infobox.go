@@ -0,0 +1,269 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// InfoboxResponse is the JSON body returned by /infobox. Template is the
+// name of the infobox template, e.g. "Infobox settlement"; Fields is empty
+// for pages without one.
+type InfoboxResponse struct {
+	Topic    string            `json:"topic"`
+	Title    string            `json:"title"`
+	Lang     string            `json:"lang"`
+	Template string            `json:"template,omitempty"`
+	Fields   map[string]string `json:"fields"`
+}
+
+// infoboxHandler returns the fields of a page's infobox as plain-text
+// key-value pairs, for quick-facts cards. Pages without an infobox return an
+// empty fields object rather than an error.
+func infoboxHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, wikitext, err := wikiClient.Wikitext(r.Context(), topic, lang, "")
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "infobox lookup")
+		return
+	}
+
+	template, fields := parseInfobox(wikitext)
+	writeJSON(w, r, http.StatusOK, InfoboxResponse{Topic: topic, Title: title, Lang: lang, Template: template, Fields: fields})
+}
+
+// infoboxTemplates are infobox-style templates whose names don't start with
+// "Infobox".
+var infoboxTemplates = map[string]bool{
+	"taxobox":           true,
+	"speciesbox":        true,
+	"automatic taxobox": true,
+	"geobox":            true,
+	"chembox":           true,
+	"drugbox":           true,
+}
+
+// isInfoboxTemplate reports whether a template name denotes an infobox:
+// "Infobox ..." in most editions, "Ficha de ..." in Spanish, or one of
+// infoboxTemplates.
+func isInfoboxTemplate(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasPrefix(name, "infobox") || strings.HasPrefix(name, "ficha de") || infoboxTemplates[name]
+}
+
+// parseInfobox finds the first infobox template in wikitext and returns its
+// name and its named parameters, with values reduced to plain text.
+// Parameters left empty in the article are omitted. fields is empty, never
+// nil, when the page has no infobox.
+func parseInfobox(wikitext string) (template string, fields map[string]string) {
+	fields = make(map[string]string)
+	wikitext = stripComments(wikitext)
+	for i := 0; ; {
+		start := strings.Index(wikitext[i:], "{{")
+		if start < 0 {
+			return "", fields
+		}
+		start += i
+		body, ok := templateBody(wikitext, start)
+		if !ok {
+			return "", fields
+		}
+		args := splitTemplateArgs(body)
+		name := strings.TrimSpace(strings.ReplaceAll(args[0], "_", " "))
+		if !isInfoboxTemplate(name) {
+			i = start + 2
+			continue
+		}
+		for _, arg := range args[1:] {
+			key, value, found := strings.Cut(arg, "=")
+			key = strings.TrimSpace(key)
+			if !found || key == "" {
+				continue
+			}
+			if value = wikitextToPlain(value); value != "" {
+				fields[key] = value
+			}
+		}
+		return name, fields
+	}
+}
+
+// templateBody returns the text between the "{{" at start and its matching
+// "}}", or false when the template is never closed.
+func templateBody(s string, start int) (string, bool) {
+	depth := 0
+	for i := start; i < len(s)-1; i++ {
+		switch s[i : i+2] {
+		case "{{":
+			depth++
+			i++
+		case "}}":
+			depth--
+			if depth == 0 {
+				return s[start+2 : i], true
+			}
+			i++
+		}
+	}
+	return "", false
+}
+
+// splitTemplateArgs splits a template body on the "|" separators that aren't
+// nested inside another template or a wikilink. The first element is the
+// template name.
+func splitTemplateArgs(body string) []string {
+	var (
+		args  []string
+		depth int
+		last  int
+	)
+	for i := 0; i < len(body); i++ {
+		switch {
+		case strings.HasPrefix(body[i:], "{{"), strings.HasPrefix(body[i:], "[["):
+			depth++
+			i++
+		case strings.HasPrefix(body[i:], "}}"), strings.HasPrefix(body[i:], "]]"):
+			depth--
+			i++
+		case body[i] == '|' && depth == 0:
+			args = append(args, body[last:i])
+			last = i + 1
+		}
+	}
+	return append(args, body[last:])
+}
+
+var (
+	commentPattern  = regexp.MustCompile(`(?s)<!--.*?-->`)
+	refPattern      = regexp.MustCompile(`(?is)<ref[^>/]*/>|<ref[^>]*>.*?</ref>`)
+	filePattern     = regexp.MustCompile(`(?i)\[\[(?:file|image):[^\[\]]*\]\]`)
+	linkPattern     = regexp.MustCompile(`\[\[([^\[\]|]*)(?:\|([^\[\]]*))?\]\]`)
+	extLinkPattern  = regexp.MustCompile(`\[(?:https?:)?//[^\s\]]+(?:\s+([^\]]*))?\]`)
+	innerTemplate   = regexp.MustCompile(`\{\{([^{}]*)\}\}`)
+	breakPattern    = regexp.MustCompile(`(?i)<br\s*/?>`)
+	tagPattern      = regexp.MustCompile(`</?[a-zA-Z][^>]*>`)
+	emphasisPattern = regexp.MustCompile(`'{2,}`)
+	spacePattern    = regexp.MustCompile(`[ \t]+`)
+)
+
+// stripComments removes HTML comments, which infobox skeletons use to
+// annotate unfilled parameters.
+func stripComments(s string) string {
+	return commentPattern.ReplaceAllString(s, "")
+}
+
+// wikitextToPlain reduces an infobox value to plain text: references and
+// images are dropped, links keep their label, common formatting templates are
+// expanded and line-broken lists are joined with ", ".
+func wikitextToPlain(s string) string {
+	s = refPattern.ReplaceAllString(s, "")
+	s = filePattern.ReplaceAllString(s, "")
+	s = linkPattern.ReplaceAllStringFunc(s, func(m string) string {
+		sub := linkPattern.FindStringSubmatch(m)
+		if sub[2] != "" {
+			return sub[2]
+		}
+		return sub[1]
+	})
+	s = extLinkPattern.ReplaceAllString(s, "$1")
+
+	// Expand templates innermost first so nested ones end up as plain text
+	for innerTemplate.MatchString(s) {
+		s = innerTemplate.ReplaceAllStringFunc(s, func(m string) string {
+			return expandTemplate(strings.Split(m[2:len(m)-2], "|"))
+		})
+	}
+
+	s = breakPattern.ReplaceAllString(s, "\n")
+	s = tagPattern.ReplaceAllString(s, "")
+	s = emphasisPattern.ReplaceAllString(s, "")
+	s = strings.ReplaceAll(s, "&nbsp;", " ")
+
+	var parts []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(spacePattern.ReplaceAllString(line, " "))
+		line = strings.TrimSpace(strings.TrimLeft(line, "*#"))
+		if line != "" {
+			parts = append(parts, line)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Templates grouped by how expandTemplate renders them.
+var (
+	dateTemplates = map[string]bool{
+		"birth date": true, "birth date and age": true, "death date": true, "death date and age": true,
+		"start date": true, "start date and age": true, "end date": true, "film date": true, "dts": true,
+	}
+	listTemplates = map[string]bool{
+		"ubl": true, "unbulleted list": true, "plainlist": true, "plain list": true,
+		"flatlist": true, "hlist": true, "collapsible list": true, "bulleted list": true,
+	}
+	firstArgTemplates = map[string]bool{
+		"nowrap": true, "nobr": true, "small": true, "big": true, "abbr": true,
+		"avoid wrap": true, "flag": true, "marriage": true, "url": true,
+	}
+	secondArgTemplates = map[string]bool{
+		"lang": true, "native name": true, "transl": true,
+	}
+)
+
+// expandTemplate renders a template without nested templates as plain
+// text. args[0] is the template name. Dates come out as YYYY-MM-DD, lists
+// as one item per line, {{convert}} as the original value and unit, and
+// formatting wrappers and {{formatnum:}} as the text they wrap. Unknown
+// templates, such as citation-needed tags, are dropped.
+func expandTemplate(args []string) string {
+	name := strings.ToLower(strings.TrimSpace(strings.ReplaceAll(args[0], "_", " ")))
+	if num, found := strings.CutPrefix(name, "formatnum:"); found {
+		return num
+	}
+	var positional []string
+	for _, a := range args[1:] {
+		if k, _, found := strings.Cut(a, "="); found && !strings.ContainsAny(k, " \n") {
+			continue // named parameter such as df=yes
+		}
+		positional = append(positional, strings.TrimSpace(a))
+	}
+
+	switch {
+	case dateTemplates[name]:
+		var date []string
+		for _, p := range positional {
+			if p == "" || len(date) == 3 || strings.Trim(p, "0123456789") != "" {
+				break
+			}
+			if len(p) == 1 {
+				p = "0" + p
+			}
+			date = append(date, p)
+		}
+		if len(date) == 0 && len(positional) > 0 {
+			return positional[0] // free-form, e.g. "52 BC"
+		}
+		return strings.Join(date, "-")
+	case name == "convert" || name == "cvt":
+		if len(positional) >= 4 && (positional[1] == "-" || positional[1] == "to" || positional[1] == "and") {
+			return positional[0] + "–" + positional[2] + " " + positional[3]
+		}
+		if len(positional) >= 2 {
+			return positional[0] + " " + positional[1]
+		}
+	case listTemplates[name]:
+		return strings.Join(positional, "\n")
+	case firstArgTemplates[name] && len(positional) >= 1:
+		return positional[0]
+	case secondArgTemplates[name] && len(positional) >= 2:
+		return positional[1]
+	}
+	return ""
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// jsonpCallbackPattern accepts plain JavaScript identifiers and dotted paths
+// such as "widget.onSummary", and nothing that could inject script.
+var jsonpCallbackPattern = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// jsonpCallback returns the JSONP "callback" query parameter, or "" when
+// absent. An unsafe callback name gets a 400 response and false.
+func jsonpCallback(w http.ResponseWriter, r *http.Request) (string, bool) {
+	callback := r.URL.Query().Get("callback")
+	if callback == "" {
+		return "", true
+	}
+	if len(callback) > 128 || !jsonpCallbackPattern.MatchString(callback) {
+		writeError(w, r, http.StatusBadRequest, "INVALID_PARAMETER", "callback must be a JavaScript identifier")
+		return "", false
+	}
+	return callback, true
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+)
+
+// LinksResponse is the JSON body returned by /links. Total counts every link
+// on the page; Links holds only the requested window.
+type LinksResponse struct {
+	Topic  string   `json:"topic"`
+	Title  string   `json:"title"`
+	Lang   string   `json:"lang"`
+	Total  int      `json:"total"`
+	Offset int      `json:"offset"`
+	Limit  int      `json:"limit"`
+	Links  []string `json:"links"`
+}
+
+// linksHandler returns the titles of the articles a page links to, paginated
+// with the "offset" and "limit" query parameters.
+func linksHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, links, err := wikiClient.Links(r.Context(), topic, lang)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "links lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, LinksResponse{
+		Topic:  topic,
+		Title:  title,
+		Lang:   lang,
+		Total:  len(links),
+		Offset: offset,
+		Limit:  limit,
+		Links:  paginate(links, offset, limit),
+	})
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// LinksContextResponse is the JSON body returned by
+// /extract-links-with-context. Total counts every distinct article linked
+// from the page's prose; Links holds only the requested window.
+type LinksContextResponse struct {
+	Topic  string        `json:"topic"`
+	Title  string        `json:"title"`
+	Lang   string        `json:"lang"`
+	Total  int           `json:"total"`
+	Offset int           `json:"offset"`
+	Limit  int           `json:"limit"`
+	Links  []LinkContext `json:"links"`
+}
+
+// LinkContext is one outgoing link: the linked article's title, the text
+// of the link as it reads in the article, and the sentence around it.
+type LinkContext struct {
+	Title   string `json:"title"`
+	Anchor  string `json:"anchor"`
+	Context string `json:"context"`
+}
+
+// maxLinkContextBytes caps a link's context. Longer sentences are cut to a
+// window around the link, at word boundaries, marked with an ellipsis.
+const maxLinkContextBytes = 300
+
+// linksContextHandler returns the articles a page links to from its prose,
+// each with the sentence it is linked from, paginated like /links.
+func linksContextHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, body, err := wikiClient.HTML(r.Context(), topic, lang, "")
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "links lookup")
+		return
+	}
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "links lookup")
+		return
+	}
+
+	links := extractLinkContexts(doc)
+	writeJSON(w, r, http.StatusOK, LinksContextResponse{
+		Topic:  topic,
+		Title:  title,
+		Lang:   lang,
+		Total:  len(links),
+		Offset: offset,
+		Limit:  limit,
+		Links:  paginate(links, offset, limit),
+	})
+}
+
+// extractLinkContexts lists the articles linked from the prose of a parsed
+// page, in the order they first appear, each once with its first context.
+// Prose is the text of paragraphs, list items and definitions; links that
+// only appear in infoboxes, tables, navboxes, hatnotes, captions or
+// references aren't listed.
+func extractLinkContexts(doc *html.Node) []LinkContext {
+	x := linkExtractor{seen: make(map[string]bool)}
+	x.walk(doc)
+	if x.links == nil {
+		return []LinkContext{}
+	}
+	return x.links
+}
+
+type linkExtractor struct {
+	seen  map[string]bool
+	links []LinkContext
+}
+
+// proseBlocks are the elements whose text is split into sentences for
+// link contexts.
+var proseBlocks = map[string]bool{"p": true, "li": true, "dd": true, "dt": true}
+
+// walk finds the prose blocks under n, skipping the parts of a page that
+// aren't prose.
+func (x *linkExtractor) walk(n *html.Node) {
+	if n.Type == html.ElementNode {
+		if notProse(n) {
+			return
+		}
+		if proseBlocks[n.Data] {
+			x.block(n)
+			return
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		x.walk(c)
+	}
+}
+
+// block records the links in the prose block n. Lists and blocks nested in
+// it, such as a sublist of a list item, are walked afterwards as blocks of
+// their own, so their text doesn't run into n's sentences.
+func (x *linkExtractor) block(n *html.Node) {
+	type span struct {
+		title      string
+		start, end int
+	}
+	var (
+		text   proseText
+		spans  []span
+		nested []*html.Node
+	)
+	var collect func(c *html.Node)
+	collect = func(c *html.Node) {
+		switch {
+		case c.Type == html.TextNode:
+			text.write(c.Data)
+			return
+		case c.Type != html.ElementNode, notProse(c):
+			return
+		case proseBlocks[c.Data], c.Data == "ul", c.Data == "ol", c.Data == "dl", c.Data == "div":
+			nested = append(nested, c)
+			return
+		case c.Data == "br":
+			text.write(" ")
+		case c.Data == "a":
+			if title, ok := wikiLinkTitle(c); ok {
+				start := text.b.Len()
+				for gc := c.FirstChild; gc != nil; gc = gc.NextSibling {
+					collect(gc)
+				}
+				spans = append(spans, span{title, start, text.b.Len()})
+				return
+			}
+		}
+		for gc := c.FirstChild; gc != nil; gc = gc.NextSibling {
+			collect(gc)
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		collect(c)
+	}
+
+	s := text.b.String()
+	for _, sp := range spans {
+		// A space collapsed before the link's text is written at its start
+		if sp.start < sp.end && s[sp.start] == ' ' {
+			sp.start++
+		}
+		if sp.start >= sp.end || x.seen[sp.title] {
+			continue
+		}
+		x.seen[sp.title] = true
+		x.links = append(x.links, LinkContext{
+			Title:   sp.title,
+			Anchor:  s[sp.start:sp.end],
+			Context: sentenceAround(s, sp.start, sp.end),
+		})
+	}
+	for _, c := range nested {
+		x.walk(c)
+	}
+}
+
+// notProse reports whether the element n holds something other than the
+// article's running text: a table (infoboxes included), figure, hatnote,
+// navbox, reference marker or list, or a style or script.
+func notProse(n *html.Node) bool {
+	switch n.Data {
+	case "table", "figure", "style", "script", "math":
+		return true
+	}
+	for _, class := range []string{"hatnote", "navbox", "sidebar", "infobox", "thumb", "gallery", "reference", "reflist", "references", "mw-references-wrap", "mw-editsection", "noprint", "metadata", "shortdescription"} {
+		if hasClass(n, class) {
+			return true
+		}
+	}
+	return false
+}
+
+// wikiLinkTitle returns the title of the article the anchor a links to, or
+// ok false for external links, red links to missing pages, and links to
+// files, categories and other non-article pages. Section fragments are
+// dropped, so links to sections count as links to their article.
+func wikiLinkTitle(a *html.Node) (title string, ok bool) {
+	path, ok := strings.CutPrefix(attr(a, "href"), "/wiki/")
+	if !ok {
+		return "", false
+	}
+	path, _, _ = strings.Cut(path, "#")
+	title, err := url.PathUnescape(path)
+	if err != nil {
+		return "", false
+	}
+	title = strings.TrimSpace(strings.ReplaceAll(title, "_", " "))
+	if title == "" || checkArticleTopic(title) != nil {
+		return "", false
+	}
+	return title, true
+}
+
+// proseText accumulates the text of a block with runs of whitespace
+// collapsed to single spaces and leading whitespace dropped. A trailing
+// space is only written once more text follows.
+type proseText struct {
+	b     strings.Builder
+	space bool
+}
+
+func (t *proseText) write(s string) {
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			t.space = t.b.Len() > 0
+			continue
+		}
+		if t.space {
+			t.b.WriteByte(' ')
+			t.space = false
+		}
+		t.b.WriteRune(r)
+	}
+}
+
+// sentenceAround returns the sentence of text that contains text[start:end],
+// cut to maxLinkContextBytes around it when longer.
+func sentenceAround(text string, start, end int) string {
+	from, to := 0, len(text)
+	for i := start - 1; i >= 0; i-- {
+		if e, ok := sentenceEnd(text, i); ok && e <= start {
+			from = e
+			break
+		}
+	}
+	// The link's text may end the sentence itself, as in "[[Acme Inc.]]"
+	for i := max(end-1, start); i < len(text); i++ {
+		if e, ok := sentenceEnd(text, i); ok {
+			to = e
+			break
+		}
+	}
+	for from < start && text[from] == ' ' {
+		from++
+	}
+	sentence := text[from:to]
+	start, end = start-from, end-from
+	if len(sentence) <= maxLinkContextBytes {
+		return sentence
+	}
+
+	budget := max(maxLinkContextBytes-(end-start), 0) / 2
+	lo, hi := start-budget, end+budget
+	if lo < 0 {
+		hi, lo = hi-lo, 0
+	}
+	if hi > len(sentence) {
+		lo, hi = max(lo-(hi-len(sentence)), 0), len(sentence)
+	}
+	if lo > 0 {
+		if i := strings.IndexByte(sentence[lo:start], ' '); i >= 0 {
+			lo += i + 1
+		} else {
+			lo = start
+		}
+	}
+	if hi < len(sentence) {
+		if i := strings.LastIndexByte(sentence[end:hi], ' '); i >= 0 {
+			hi = end + i
+		} else {
+			hi = end
+		}
+	}
+	window := sentence[lo:hi]
+	if lo > 0 {
+		window = "…" + window
+	}
+	if hi < len(sentence) {
+		window += "…"
+	}
+	return window
+}
+
+// sentenceAbbreviations are words commonly written with a full stop that
+// doesn't end a sentence, lower-cased without it.
+var sentenceAbbreviations = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "st": true, "jr": true, "sr": true,
+	"vs": true, "c": true, "ca": true, "no": true, "vol": true, "pp": true, "approx": true, "est": true,
+}
+
+// sentenceEnd reports whether the punctuation at text[i] ends a sentence:
+// a '.', '!' or '?', optionally followed by closing quotes or brackets,
+// then a space and a capital letter, digit or opening quote. A full stop
+// after an initial ("J. R. R. Tolkien"), a dotted abbreviation ("e.g.",
+// "U.S.") or a word in sentenceAbbreviations doesn't count. end is the
+// offset just past the sentence's closing characters.
+func sentenceEnd(text string, i int) (end int, ok bool) {
+	switch text[i] {
+	case '.', '!', '?':
+	default:
+		return 0, false
+	}
+	end = i + 1
+	for end < len(text) {
+		r, size := utf8.DecodeRuneInString(text[end:])
+		if !strings.ContainsRune(`"')]’”»`, r) {
+			break
+		}
+		end += size
+	}
+	if end < len(text) {
+		if text[end] != ' ' {
+			return 0, false
+		}
+		next, _ := utf8.DecodeRuneInString(text[end+1:])
+		if !unicode.IsUpper(next) && !unicode.IsDigit(next) && !strings.ContainsRune(`"'(“‘«`, next) {
+			return 0, false
+		}
+	}
+	if text[i] == '.' {
+		word := text[strings.LastIndexByte(text[:i], ' ')+1 : i]
+		word = strings.TrimLeft(word, `"'(“‘«`)
+		if utf8.RuneCountInString(word) == 1 || strings.Contains(word, ".") || sentenceAbbreviations[strings.ToLower(word)] {
+			return 0, false
+		}
+	}
+	return end, true
+}
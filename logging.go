@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+// logger is the application logger. main replaces it with one configured
+// from LOG_LEVEL and LOG_FORMAT before anything else is logged.
+var logger = slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+// logLevel is the minimum level of loggers built by newLogger. A
+// configuration reload can change it while the server runs.
+var logLevel slog.LevelVar
+
+// newLogger builds a logger writing to stdout at the given level
+// (debug, info, warn or error) in the given format (text or json).
+func newLogger(level, format string) (*slog.Logger, error) {
+	lvl, err := parseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	logLevel.Set(lvl)
+	opts := &slog.HandlerOptions{Level: &logLevel}
+	switch strings.ToLower(format) {
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stdout, opts)), nil
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stdout, opts)), nil
+	}
+	return nil, fmt.Errorf("invalid LOG_FORMAT=%q: must be text or json", format)
+}
+
+// parseLogLevel parses a LOG_LEVEL value.
+func parseLogLevel(level string) (slog.Level, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return 0, fmt.Errorf("invalid LOG_LEVEL=%q: must be debug, info, warn or error", level)
+	}
+	return lvl, nil
+}
+
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
+}
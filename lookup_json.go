@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// LookupRequest is the JSON body a POST to /lookup may send instead of a
+// plain-text topic, with Content-Type application/json. Each option has the
+// name and meaning of the query parameter it stands for, and overrides it.
+type LookupRequest struct {
+	Topic       string `json:"topic"`
+	PageID      int    `json:"pageid"`
+	Lang        string `json:"lang"`
+	Project     string `json:"project"`
+	Sentences   int    `json:"sentences"`
+	Chars       int    `json:"chars"`
+	Format      string `json:"format"`
+	Source      string `json:"source"`
+	Mode        string `json:"mode"`
+	Fallback    string `json:"fallback"`
+	Deadline    int    `json:"deadline"`
+	Fields      string `json:"fields"`
+	DetectLang  *bool  `json:"detectlang"`
+	Autocorrect *bool  `json:"autocorrect"`
+	Fuzzy       *bool  `json:"fuzzy"`
+	Redirects   *bool  `json:"redirects"`
+	Debug       *bool  `json:"debug"`
+	NoCache     *bool  `json:"nocache"`
+	Readability *bool  `json:"readability"`
+	Explain     *bool  `json:"explain"`
+}
+
+// lookupRequestFields are the JSON field names of LookupRequest.
+var lookupRequestFields = jsonFieldNames(reflect.TypeFor[LookupRequest]())
+
+// jsonLookupRequest turns a JSON /lookup body into the equivalent request
+// with its options as query parameters and the topic as a plain-text body,
+// so the handler validates and serves both forms alike. Other requests are
+// returned as they are. A malformed body, an unknown or mistyped field or a
+// missing topic get a 400 response naming the field, and ok false.
+func jsonLookupRequest(w http.ResponseWriter, r *http.Request) (_ *http.Request, ok bool) {
+	if r.Method != http.MethodPost {
+		return r, true
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		return r, true
+	}
+
+	var req LookupRequest
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, currentConfig().MaxBodyBytes))
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&req)
+	if err == nil && dec.More() {
+		err = errors.New("unexpected data after the JSON object")
+	}
+	if err != nil {
+		writeJSONBodyError(w, r, err)
+		return nil, false
+	}
+	if normalizeTopic(req.Topic) == "" && req.PageID == 0 {
+		writeErrorResponse(w, r, http.StatusBadRequest, ErrorResponse{Error: "topic is required", Code: "TOPIC_REQUIRED", Param: "topic"})
+		return nil, false
+	}
+
+	q := r.URL.Query()
+	v := reflect.ValueOf(req)
+	for i, name := range lookupRequestFields {
+		switch f := v.Field(i); {
+		case name == "topic":
+		case f.Kind() == reflect.String && f.String() != "":
+			q.Set(name, f.String())
+		case f.Kind() == reflect.Int && f.Int() != 0:
+			q.Set(name, strconv.FormatInt(f.Int(), 10))
+		case f.Kind() == reflect.Pointer && !f.IsNil():
+			q.Set(name, strconv.FormatBool(f.Elem().Bool()))
+		}
+	}
+	r = r.WithContext(r.Context())
+	u := *r.URL
+	u.RawQuery = q.Encode()
+	r.URL = &u
+	r.Body = io.NopCloser(strings.NewReader(req.Topic))
+	r.ContentLength = int64(len(req.Topic))
+	return r, true
+}
+
+// writeJSONBodyError reports a JSON request body that couldn't be decoded:
+// 413 when it exceeded MAX_BODY_BYTES, otherwise 400 INVALID_BODY naming the
+// offending field where there is one.
+func writeJSONBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var (
+		tooLarge   *http.MaxBytesError
+		syntaxErr  *json.SyntaxError
+		typeErr    *json.UnmarshalTypeError
+		resp       = ErrorResponse{Code: "INVALID_BODY"}
+		unknown, _ = strings.CutPrefix(err.Error(), "json: unknown field ")
+	)
+	switch {
+	case errors.As(err, &tooLarge):
+		writeBodyError(w, r, err)
+		return
+	case errors.Is(err, io.EOF):
+		resp.Error = "request body is empty; expected a JSON object"
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		resp.Error = "malformed JSON: the body ends inside the object"
+	case errors.As(err, &syntaxErr):
+		resp.Error = fmt.Sprintf("malformed JSON at byte %d: %v", syntaxErr.Offset, syntaxErr)
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		resp.Error = fmt.Sprintf("%s must be %s, not %s", typeErr.Field, jsonKindName(typeErr.Type), typeErr.Value)
+		resp.Param = typeErr.Field
+	case errors.As(err, &typeErr):
+		resp.Error = fmt.Sprintf("request body must be a JSON object, not %s", typeErr.Value)
+	case unknown != err.Error():
+		name, _ := strconv.Unquote(unknown)
+		resp.Error = fmt.Sprintf("unknown field %s", unknown)
+		resp.Param, resp.Allowed = name, lookupRequestFields
+	default:
+		resp.Error = "invalid JSON body: " + err.Error()
+	}
+	writeErrorResponse(w, r, http.StatusBadRequest, resp)
+}
+
+// jsonKindName describes the JSON value a Go type decodes from, for error
+// messages.
+func jsonKindName(t reflect.Type) string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	}
+	return "a " + t.Kind().String()
+}
+
+// jsonFieldNames returns the JSON names of the fields of the struct type t,
+// in field order.
+func jsonFieldNames(t reflect.Type) []string {
+	names := make([]string, t.NumField())
+	for i := range names {
+		names[i], _, _ = strings.Cut(t.Field(i).Tag.Get("json"), ",")
+	}
+	return names
+}
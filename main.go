@@ -1,86 +1,1115 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
-	wiki "github.com/trietmn/go-wiki"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+	"golang.org/x/text/unicode/norm"
 )
 
 // appVersion is the application version, injected at build time by the Makefile.
 var appVersion = "dev" // Default value if not built with Makefile
 
-// fetchWikipediaSummary returns the first paragraph (the “extract”) for a topic.
-func fetchWikipediaSummary(topic string) (string, error) {
-	// 1. Get the page (lang -1 = default to EN)
-	page, err := wiki.GetPage(topic, -1, false, false)
+// commit and buildTime identify the build, injected by the Makefile
+// alongside appVersion.
+var (
+	commit    = "unknown"
+	buildTime = "unknown"
+)
+
+// LookupResponse is the JSON body returned by /lookup.
+// CorrectedTo is set when autocorrect replaced the topic with Wikipedia's
+// spelling suggestion; MatchedTitle when fuzzy mode resolved the topic
+// through a search; RedirectedFrom when the topic resolved to a page with a
+// different title (ResolvedTitle); PageID when the page was requested by ID;
+// Truncated when the summary exceeded MAX_SUMMARY_BYTES and was cut;
+// Partial when the lead section missed the deadline and the extract stands in;
+// Project when the lookup went to a Wikimedia project other than Wikipedia;
+// ServedLang, the edition the summary came from, when FALLBACK_LANGS is set;
+// LangMismatch and ContentLang when the article URL names another edition
+// than Lang;
+// Description, Thumbnail and ContentURLs only for "source=rest" requests;
+// Source ("archive"), ArchivedAt and ArchiveURL when Wikipedia was down and
+// the summary comes from an archived snapshot, which may be stale;
+// Trace only for "explain=true" requests, and Meta only for "debug=true"
+// requests.
+type LookupResponse struct {
+	Topic          string       `json:"topic"`
+	Summary        string       `json:"summary"`
+	Lang           string       `json:"lang"`
+	Project        string       `json:"project,omitempty"`
+	ServedLang     string       `json:"served_lang,omitempty"`
+	LangMismatch   bool         `json:"lang_mismatch,omitempty"`
+	ContentLang    string       `json:"content_lang,omitempty"`
+	URL            string       `json:"url"`
+	CorrectedTo    string       `json:"corrected_to,omitempty"`
+	MatchedTitle   string       `json:"matched_title,omitempty"`
+	ResolvedTitle  string       `json:"resolved_title"`
+	RedirectedFrom string       `json:"redirected_from,omitempty"`
+	ExactMatch     bool         `json:"exact_match"`
+	PageID         int          `json:"page_id,omitempty"`
+	Truncated      bool         `json:"truncated,omitempty"`
+	Partial        bool         `json:"partial,omitempty"`
+	Description    string       `json:"description,omitempty"`
+	Thumbnail      *Image       `json:"thumbnail,omitempty"`
+	ContentURLs    *ContentURLs `json:"content_urls,omitempty"`
+	Source         string       `json:"source,omitempty"`
+	ArchivedAt     string       `json:"archived_at,omitempty"`
+	ArchiveURL     string       `json:"archive_url,omitempty"`
+	Readability    *Readability `json:"readability,omitempty"`
+	Trace          []TraceStep  `json:"trace,omitempty"`
+	Meta           *LookupMeta  `json:"meta,omitempty"`
+}
+
+// ContentURLs are the desktop and mobile URLs of an article.
+type ContentURLs struct {
+	Desktop string `json:"desktop"`
+	Mobile  string `json:"mobile"`
+}
+
+// LookupMeta is the diagnostic metadata /lookup adds with "debug=true".
+// FetchMS covers the entire summary fetch, including autocorrect and fuzzy
+// fallback lookups.
+type LookupMeta struct {
+	FetchMS  float64 `json:"fetch_ms"`
+	CacheHit bool    `json:"cache_hit"`
+	Lang     string  `json:"lang"`
+}
+
+// DisambiguationResponse is the JSON body returned by /lookup with a 300
+// status when the topic is ambiguous.
+type DisambiguationResponse struct {
+	Topic   string   `json:"topic"`
+	Title   string   `json:"title"`
+	Lang    string   `json:"lang"`
+	Options []string `json:"options"`
+}
+
+// HealthResponse is the JSON body returned by /health. Status is "ok" when
+// every check in Checks passed and "degraded" otherwise. Cache is omitted
+// when caching is disabled, CircuitBreaker when the breaker is, Upstream
+// until an upstream probe has succeeded.
+type HealthResponse struct {
+	Status         string                 `json:"status"`
+	Checks         map[string]CheckResult `json:"checks"`
+	Cache          *CacheStats            `json:"cache,omitempty"`
+	CircuitBreaker *BreakerStats          `json:"circuit_breaker,omitempty"`
+	Upstream       *UpstreamLatency       `json:"upstream,omitempty"`
+}
+
+// VersionResponse is the JSON body returned by /version.
+type VersionResponse struct {
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+}
+
+// lookupHandler reads a topic from the request, fetches the Wikipedia summary,
+// and writes the summary back as the response. GET requests pass the topic in
+// the "topic" query parameter; POST requests send it as the request body.
+// Alternatively "pageid" names the page by its numeric ID. The optional
+// "lang" query parameter selects the Wikipedia edition (default "en");
+// "autocorrect=true" first applies Wikipedia's spelling suggestion to the
+// topic and "fuzzy=true" falls back to the top search result when no page
+// matches exactly; "redirects=false" refuses topics that resolve to a page
+// with another title; "sentences" and "chars" shorten the summary and
+// "fallback" decides what pages without one return, and "mode=lead" returns
+// the lead section instead of the extract; "source=rest" fetches the
+// summary from the REST API, adding the page's description and thumbnail.
+// Topics missing from the requested edition are looked up in the
+// FALLBACK_LANGS editions in turn. "nocache=true", or a Cache-Control:
+// no-cache request header, bypasses the cached summary and refreshes it. The
+// response is JSON, plain text or Markdown, chosen by the "format" parameter
+// or the Accept header; "debug=true" adds timing metadata to JSON responses,
+// "readability=true" scores the summary's reading level, "fields" trims
+// them to the listed fields and "callback" wraps them for JSONP. A POST may
+// instead send the topic and options as a JSON LookupRequest.
+func lookupHandler(w http.ResponseWriter, r *http.Request) {
+	// A JSON body carries the topic and options together
+	r, ok := jsonLookupRequest(w, r)
+	if !ok {
+		return
+	}
+
+	// 1. Resolve the requested language edition
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+
+	// 2. Read the summary source, mode and limits, the response format,
+	// the page ID, the JSONP callback, the empty-summary fallback, the
+	// lookup switches, the cache bypass, the readability score, the
+	// Wikimedia project and the decision trace
+	source, ok := enumParam(w, r, "source", "api", "api", "rest")
+	if !ok {
+		return
+	}
+	fetch := fetchWikipediaSummary
+	if source == "rest" {
+		fetch = fetchRESTSummary
+	}
+	mode, ok := enumParam(w, r, "mode", "extract", "extract", "lead")
+	if !ok {
+		return
+	}
+	sentences, ok := positiveIntParam(w, r, "sentences")
+	if !ok {
+		return
+	}
+	chars, ok := positiveIntParam(w, r, "chars")
+	if !ok {
+		return
+	}
+	format, ok := lookupFormat(w, r)
+	if !ok {
+		return
+	}
+	pageID, ok := positiveIntParam(w, r, "pageid")
+	if !ok {
+		return
+	}
+	if pageID > 0 && source == "rest" {
+		writeError(w, r, http.StatusBadRequest, "INVALID_PARAMETER", "source=rest needs a topic, not a pageid")
+		return
+	}
+	callback, ok := jsonpCallback(w, r)
+	if !ok {
+		return
+	}
+	fallback, ok := enumParam(w, r, "fallback", "error", "error", "empty", "section")
+	if !ok {
+		return
+	}
+	detectLang, ok := boolParam(w, r, "detectlang", false)
+	if !ok {
+		return
+	}
+	autocorrect, ok := boolParam(w, r, "autocorrect", false)
+	if !ok {
+		return
+	}
+	fuzzy, ok := boolParam(w, r, "fuzzy", false)
+	if !ok {
+		return
+	}
+	redirects, ok := boolParam(w, r, "redirects", true)
+	if !ok {
+		return
+	}
+	debug, ok := boolParam(w, r, "debug", false)
+	if !ok {
+		return
+	}
+	deadline, ok := positiveIntParam(w, r, "deadline")
+	if !ok {
+		return
+	}
+	noCache, ok := boolParam(w, r, "nocache", requestsNoCache(r))
+	if !ok {
+		return
+	}
+	readability, ok := boolParam(w, r, "readability", false)
+	if !ok {
+		return
+	}
+	project, ok := requestProject(w, r)
+	if !ok {
+		return
+	}
+	explain, ok := boolParam(w, r, "explain", false)
+	if !ok {
+		return
+	}
+
+	// With a deadline (in milliseconds), every upstream call below shares
+	// it on top of WIKI_TIMEOUT; once it passes, answer with what is done
+	ctx := r.Context()
+	if deadline > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, time.Duration(deadline)*time.Millisecond, ErrDeadline)
+		defer cancel()
+	}
+	// A forced refresh skips the cached summary but still stores the
+	// fresh one
+	if noCache {
+		ctx = withNoCache(ctx)
+	}
+	// Every upstream call below, suggestions and sections included, goes
+	// to the chosen project
+	ctx = withProject(ctx, project)
+	deadlinePassed := func(err error) bool {
+		return err != nil && errors.Is(context.Cause(ctx), ErrDeadline)
+	}
+	writeDeadlineError := func(topic string) {
+		writeError(w, r, http.StatusServiceUnavailable, "DEADLINE_EXCEEDED", fmt.Sprintf("no summary for %q within the %dms deadline", topic, deadline))
+	}
+
+	// 3. Call the brains to fetch the summary, either by page ID or by topic
+	var (
+		topic, correctedTo, matchedTitle string
+		result                           PageSummary
+		snapshot                         *ArchiveSnapshot
+		err                              error
+	)
+	// With explain, every decision below is noted for the response
+	var trace *lookupTrace
+	if explain {
+		trace = &lookupTrace{}
+	}
+	trace.langSource(r, lang)
+	if noCache {
+		trace.add("cache", "nocache: any cached copy is skipped and replaced")
+	}
+	keyFor := func(query, lang string) cacheKey {
+		return cacheKey{topic: query, lang: lang, project: project, rest: source == "rest"}
+	}
+	servedLang := lang
+	start := time.Now()
+	if pageID > 0 {
+		topic = fmt.Sprintf("page id %d", pageID)
+		requestInfoFrom(ctx).Topic = topic
+		result, err = fetchSummaryByID(ctx, pageID, lang)
+		trace.fetched(cacheKey{pageID: pageID, lang: lang, project: project}, result, err)
+	} else {
+		// Read the topic from the query string (GET) or the body (POST)
+		if topic, ok = requireTopic(w, r); !ok {
+			return
+		}
+
+		// Without an explicit lang, optionally pick the edition the
+		// topic's script points to
+		if detectLang && r.URL.Query().Get("lang") == "" {
+			if detected, found := detectLanguage(topic); found {
+				lang = detected
+				trace.add("detectlang", "the topic's script points to %s", detected)
+			} else {
+				trace.add("detectlang", "no edition detected from the topic's script; %s kept", lang)
+			}
+		}
+
+		// Optionally swap the topic for Wikipedia's spelling suggestion
+		query := topic
+		if autocorrect {
+			suggestion, err := wikiClient.Suggest(ctx, topic, lang)
+			if deadlinePassed(err) {
+				writeDeadlineError(topic)
+				return
+			}
+			if err != nil {
+				writeUpstreamError(w, r, err, topic, "suggestion lookup")
+				return
+			}
+			if suggestion != "" && suggestion != topic {
+				query, correctedTo = suggestion, suggestion
+				trace.add("autocorrect", "%q replaced by Wikipedia's spelling suggestion %q", topic, suggestion)
+			} else {
+				trace.add("autocorrect", "no spelling suggestion for %q", topic)
+			}
+		}
+
+		// Fall back to the best search match in fuzzy mode
+		result, err = fetch(ctx, query, lang)
+		trace.fetched(keyFor(query, lang), result, err)
+		if errors.Is(err, ErrPageNotFound) && fuzzy {
+			result, err = fetchBestMatchSummary(ctx, query, lang, fetch)
+			matchedTitle = result.Title
+			if err == nil {
+				trace.add("fuzzy", "best search match for %q is %q", query, result.Title)
+			} else {
+				trace.add("fuzzy", "no usable search match for %q: %v", query, err)
+			}
+		}
+
+		// Try the FALLBACK_LANGS editions in order when the requested one
+		// has no such page
+		for _, fallbackLang := range currentConfig().FallbackLangs {
+			if !errors.Is(err, ErrPageNotFound) {
+				break
+			}
+			if fallbackLang != lang {
+				trace.add("fallback_lang", "trying %s from FALLBACK_LANGS", fallbackLang)
+				result, err = fetch(ctx, query, fallbackLang)
+				trace.fetched(keyFor(query, fallbackLang), result, err)
+				servedLang = fallbackLang
+			}
+		}
+
+		// With ARCHIVE_FALLBACK, answer from the latest archived copy of
+		// the article when Wikipedia itself can't be reached
+		if currentConfig().ArchiveFallback && upstreamDown(err) && ctx.Err() == nil {
+			archived, taken, archiveErr := fetchArchivedSummary(ctx, query, lang)
+			if archiveErr == nil {
+				logger.Warn("serving archived summary", "topic", query, "archived_at", taken.Time, "cause", err)
+				trace.add("archive", "Wikipedia is down; the snapshot from %s stands in", taken.Time.Format(time.RFC3339))
+				result, snapshot, err, servedLang = archived, &taken, nil, lang
+			} else {
+				logger.Debug("archive fallback failed", "topic", query, "error", archiveErr)
+				trace.add("archive", "Wikipedia is down and the archive has no copy: %v", archiveErr)
+			}
+		}
+	}
+	// In lead mode the page's lead section replaces the extract; the
+	// extract lookup above still resolved the page, redirects and all. A
+	// lead that misses the deadline leaves the extract as a partial answer
+	partial := false
+	if err == nil && mode == "lead" && snapshot == nil {
+		lead, leadErr := leadText(ctx, result.Title, servedLang)
+		switch {
+		case deadlinePassed(leadErr):
+			partial = true
+			trace.add("mode", "the lead section missed the deadline; the extract stands in")
+		case leadErr != nil:
+			err = leadErr
+		default:
+			result.Summary, result.Cached = lead, false
+			trace.add("mode", "the lead section replaces the extract")
+		}
+	}
+	fetchTime := time.Since(start)
+	var disambig *DisambiguationError
+	if errors.As(err, &disambig) {
+		// Let the caller pick one of the candidate pages
+		writeJSON(w, r, http.StatusMultipleChoices, DisambiguationResponse{Topic: topic, Title: disambig.Title, Lang: lang, Options: disambig.Options})
+		return
+	}
+	if deadlinePassed(err) {
+		writeDeadlineError(topic)
+		return
+	}
 	if err != nil {
-		return "", err // network / page not found error
+		writeUpstreamError(w, r, err, topic, "lookup")
+		return
+	}
+	if result.RedirectedFrom != "" && !redirects {
+		writeError(w, r, http.StatusNotFound, "REDIRECTED", fmt.Sprintf("%q redirects to %q", topic, result.Title))
+		return
+	}
+	if result.RedirectedFrom != "" {
+		trace.add("redirect", "%q redirects to %q", result.RedirectedFrom, result.Title)
+	}
+
+	// Some pages have no lead extract; never answer with an empty summary
+	if strings.TrimSpace(result.Summary) == "" {
+		switch fallback {
+		case "empty":
+			w.WriteHeader(http.StatusNoContent)
+			return
+		case "section":
+			trace.add("fallback", "the extract is empty; the first section stands in")
+			if result.Summary, err = firstSectionText(ctx, result.Title, servedLang); deadlinePassed(err) {
+				writeDeadlineError(topic)
+				return
+			} else if err != nil {
+				writeUpstreamError(w, r, err, topic, "section lookup")
+				return
+			}
+		}
+		if strings.TrimSpace(result.Summary) == "" {
+			writeError(w, r, http.StatusNotFound, "NO_SUMMARY", fmt.Sprintf("no summary available for %q", result.Title))
+			return
+		}
+	}
+
+	// 4. Shorten the summary. The full extract is what gets cached, so the
+	// limits are applied here rather than through go-wiki's Summary. With
+	// both limits set, applying one after the other yields the shorter cut.
+	if sentences > 0 {
+		result.Summary = truncateSentences(result.Summary, sentences)
+	}
+	if chars > 0 {
+		result.Summary, _ = truncateChars(result.Summary, chars)
+	}
+	// Whatever was asked for, never send more than MAX_SUMMARY_BYTES
+	truncated := false
+	if limit := currentConfig().MaxSummaryBytes; limit > 0 {
+		result.Summary, truncated = truncateBytes(result.Summary, limit)
+	}
+
+	// 5. Happy path: write the summary in the requested format, with
+	// caching headers so clients and CDNs can skip unchanged summaries
+	// A partial or archived answer must not be cached in place of the
+	// full, live one
+	if partial || snapshot != nil {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	if snapshot != nil {
+		w.Header().Set("X-Content-Source", "archive")
 	}
+	switch format {
+	case "text/plain":
+		writeCacheable(w, r, "text/plain; charset=utf-8", []byte(result.Summary))
+	case "text/markdown":
+		writeCacheable(w, r, "text/markdown; charset=utf-8", []byte(renderMarkdown(result)))
+	default:
+		resp := LookupResponse{
+			Topic:          topic,
+			Summary:        result.Summary,
+			Lang:           lang,
+			URL:            result.URL,
+			CorrectedTo:    correctedTo,
+			MatchedTitle:   matchedTitle,
+			ResolvedTitle:  result.Title,
+			RedirectedFrom: result.RedirectedFrom,
+			Truncated:      truncated,
+			Partial:        partial,
+			Description:    result.Description,
+			Thumbnail:      result.Thumbnail,
+		}
+		if len(currentConfig().FallbackLangs) > 0 {
+			resp.ServedLang = servedLang
+		}
+		if project != defaultProject {
+			resp.Project = project
+		}
+		// Flag articles from another edition than the one asked for, as
+		// served by a fallback or by a mirror that ignores the language
+		if contentLang, _, err := parseArticleURL(result.URL); err == nil && contentLang != lang {
+			resp.LangMismatch, resp.ContentLang = true, contentLang
+		}
+		if result.MobileURL != "" {
+			resp.ContentURLs = &ContentURLs{Desktop: result.URL, Mobile: result.MobileURL}
+		}
+		// Only a page found under the very title asked for is an exact
+		// match, whichever resolution features were on; a page ID names
+		// its page exactly
+		resp.ExactMatch = correctedTo == "" && matchedTitle == "" && result.RedirectedFrom == "" && sameTitle(topic, result.Title)
+		if pageID > 0 {
+			resp.Topic, resp.PageID, resp.ExactMatch = result.Title, pageID, true
+		}
+		if snapshot != nil {
+			resp.Source, resp.ArchivedAt, resp.ArchiveURL = "archive", snapshot.Time.Format(time.RFC3339), snapshot.URL
+		}
+		if readability {
+			resp.Readability = measureReadability(result.Summary)
+		}
+		trace.add("result", "%q from %s", result.Title, cacheKey{lang: servedLang, project: project}.edition())
+		resp.Trace = trace.list()
+		if debug {
+			resp.Meta = &LookupMeta{FetchMS: durationMS(fetchTime), CacheHit: result.Cached, Lang: lang}
+		}
+		writeCacheableJSON(w, r, selectFields(resp, r.URL.Query().Get("fields")), callback)
+	}
+}
+
+// firstSectionText returns the text of the first section of the page
+// titled title, or "" when it has no sections.
+func firstSectionText(ctx context.Context, title, lang string) (string, error) {
+	_, sections, err := wikiClient.Sections(ctx, title, lang)
+	if err != nil || len(sections) == 0 {
+		return "", err
+	}
+	_, text, err := wikiClient.Section(ctx, title, lang, sections[0].Title)
+	return text, err
+}
 
-	// 2. Retrieve & return the summary
-	summary, err := page.GetSummary()
+// leadText returns the lead section of the page titled title: the
+// paragraphs of its plain-text content before the first section heading.
+func leadText(ctx context.Context, title, lang string) (string, error) {
+	_, content, err := wikiClient.Content(ctx, title, lang)
 	if err != nil {
 		return "", err
 	}
-	return summary, nil
+	return leadSection(content), nil
 }
 
-// lookupHandler reads a topic from a POST request, fetches the Wikipedia summary,
-// and writes the summary back as the response.
-func lookupHandler(w http.ResponseWriter, r *http.Request) {
-	// Only allow POST for simplicity
-	if r.Method != http.MethodPost {
-		http.Error(w, "POST required", http.StatusMethodNotAllowed)
-		return
+// headingPattern matches the "== Heading ==" lines that start each section
+// in plain-text page content.
+var headingPattern = regexp.MustCompile(`(?m)^={2,6}[^=\n].*={2,6}[ \t]*$`)
+
+// leadSection cuts content at its first section heading.
+func leadSection(content string) string {
+	if loc := headingPattern.FindStringIndex(content); loc != nil {
+		content = content[:loc[0]]
+	}
+	return strings.TrimSpace(content)
+}
+
+// lookupFormats maps the values of the /lookup "format" parameter to the
+// media types offered through the Accept header.
+var lookupFormats = map[string]string{
+	"json":     "application/json",
+	"text":     "text/plain",
+	"markdown": "text/markdown",
+}
+
+// lookupFormat picks the /lookup response media type: the "format" query
+// parameter when present, otherwise the best match for the Accept header.
+// On an unknown format it writes a 400 response and returns false.
+func lookupFormat(w http.ResponseWriter, r *http.Request) (string, bool) {
+	f, ok := enumParam(w, r, "format", "", "json", "text", "markdown")
+	if !ok {
+		return "", false
+	}
+	if f != "" {
+		return lookupFormats[f], true
+	}
+	return negotiateContentType(r.Header.Get("Accept"), "application/json", "text/plain", "text/markdown"), true
+}
+
+// renderMarkdown formats a summary as a Markdown document: the page title as
+// a heading, the summary paragraph, and a link back to the article.
+func renderMarkdown(s PageSummary) string {
+	return fmt.Sprintf("# %s\n\n%s\n\n[Read more on Wikipedia](%s)\n", s.Title, s.Summary, s.URL)
+}
+
+// requestLang returns the Wikipedia edition selected by the "lang" query
+// parameter or, without one, the Accept-Language header, defaulting to
+// DEFAULT_LANG. On an unsupported lang code it writes a 400 response and
+// returns false; unsupported Accept-Language entries are skipped.
+func requestLang(w http.ResponseWriter, r *http.Request) (string, bool) {
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		// Without lang, the Accept-Language header picks the edition
+		w.Header().Add("Vary", "Accept-Language")
+		lang = defaultLang
+		if preferred, ok := negotiateLanguage(r.Header.Get("Accept-Language")); ok {
+			lang = preferred
+		}
+	}
+	if !isSupportedLanguage(lang) {
+		paramError{code: "UNSUPPORTED_LANGUAGE", name: "lang", msg: fmt.Sprintf("unsupported language code %q", lang)}.write(w, r)
+		return "", false
 	}
+	setSpanAttributes(r, attribute.String("wikipedia.lang", lang))
+	requestInfoFrom(r.Context()).Lang = lang
+	return lang, true
+}
 
-	// 1. Read the whole request body (the topic string)
-	topicBytes, err := io.ReadAll(r.Body)
+// readTopic extracts the topic from a lookup request: the "topic" query
+// parameter for GET and HEAD, the whole request body for POST. Either way
+// the topic is passed through normalizeTopic.
+func readTopic(w http.ResponseWriter, r *http.Request) (string, error) {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		return normalizeTopic(r.URL.Query().Get("topic")), nil
+	}
+	body, err := readBodyText(w, r)
 	if err != nil {
-		http.Error(w, "cannot read body", http.StatusBadRequest)
-		return
+		return "", err
 	}
-	topic := string(topicBytes)
+	return normalizeTopic(body), nil
+}
 
-	// 2. Call the brains to fetch the summary
-	summary, err := fetchWikipediaSummary(topic)
+// normalizeTopic turns a user-supplied topic into the form Wikipedia titles
+// use: the text is composed to Unicode NFC, as MediaWiki stores titles, so
+// decomposed input such as "e" + U+0301 matches "é"; underscores become
+// spaces (as in article URLs); and runs of whitespace, including newlines,
+// collapse to single spaces with none leading or trailing.
+func normalizeTopic(topic string) string {
+	return strings.Join(strings.Fields(strings.ReplaceAll(norm.NFC.String(topic), "_", " ")), " ")
+}
+
+// readBodyText reads a plain-text request body of at most MAX_BODY_BYTES and
+// trims surrounding whitespace, such as the newline curl appends.
+func readBodyText(w http.ResponseWriter, r *http.Request) (string, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, currentConfig().MaxBodyBytes))
 	if err != nil {
-		http.Error(w, fmt.Sprintf("lookup error: %v", err), http.StatusInternalServerError)
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// writeBodyError reports a failure to read the request body: 413 when it
+// exceeded MAX_BODY_BYTES, 400 otherwise.
+func writeBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "BODY_TOO_LARGE", fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit))
 		return
 	}
+	writeError(w, r, http.StatusBadRequest, "INVALID_BODY", "cannot read body")
+}
+
+// listenAddr resolves the address to bind: the -addr flag when given,
+// otherwise HOST and PORT (default 8080).
+func listenAddr(flagAddr string, startup *StartupConfig) string {
+	if flagAddr != "" {
+		return flagAddr
+	}
+	return net.JoinHostPort(startup.Host, startup.Port)
+}
+
+// requireTopic reads the topic via readTopic and records it for the request
+// log. When the topic is unreadable it writes a 400 response, when it is
+// empty a 400 JSON ErrorResponse and when the topic filter blocks it a 403,
+// returning false in each case.
+func requireTopic(w http.ResponseWriter, r *http.Request) (string, bool) {
+	topic, err := readTopic(w, r)
+	if err != nil {
+		writeBodyError(w, r, err)
+		return "", false
+	}
+	if topic == "" {
+		writeError(w, r, http.StatusBadRequest, "TOPIC_REQUIRED", ErrTopicRequired.Error())
+		return "", false
+	}
+	requestInfoFrom(r.Context()).Topic = topic
+	setSpanAttributes(r, attribute.String("wikipedia.topic", topic))
+	if !checkTopicAllowed(w, r, topic) {
+		return "", false
+	}
+	recordTopic(topic)
+	return topic, true
+}
 
-	// 3. Happy path: write the summary to the response
-	fmt.Fprint(w, summary)
+// handle registers h on mux for pattern, instrumented with the request
+// metrics exposed at /metrics and traced. Only the listed methods reach h;
+// others get 405 with an Allow header naming them.
+func handle(mux *http.ServeMux, pattern string, h http.HandlerFunc, methods ...string) {
+	if len(methods) == 0 {
+		panic("handle: no methods allowed for " + pattern)
+	}
+	mux.Handle(pattern, traceHandler(pattern, instrumentHandler(pattern, allowMethods(h, methods))))
+}
+
+// allowMethods wraps h to answer requests with a method other than methods
+// with 405 METHOD_NOT_ALLOWED, such as "GET or POST required".
+func allowMethods(h http.HandlerFunc, methods []string) http.HandlerFunc {
+	allow := strings.Join(methods, ", ")
+	msg := methods[len(methods)-1] + " required"
+	if n := len(methods); n > 1 {
+		msg = strings.Join(methods[:n-1], ", ") + " or " + msg
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !slices.Contains(methods, r.Method) {
+			w.Header().Set("Allow", allow)
+			writeError(w, r, http.StatusMethodNotAllowed, "METHOD_NOT_ALLOWED", msg)
+			return
+		}
+		h(w, r)
+	}
 }
 
 func main() {
-	// Route for health checks
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-	})
+	addrFlag := flag.String("addr", "", "address to listen on, e.g. 127.0.0.1:9090 (overrides HOST and PORT)")
+	flag.Parse()
+
+	// Overlay the optional CONFIG_FILE onto the environment; SIGHUP re-reads it
+	configFile = os.Getenv("CONFIG_FILE")
+	if err := applyConfigFile(); err != nil {
+		fatal("invalid CONFIG_FILE", "error", err)
+	}
+
+	// Configure logging first so configuration errors are reported through it
+	l, err := newLogger(envString("LOG_LEVEL", "info"), envString("LOG_FORMAT", "json"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	logger = l
+	slog.SetDefault(logger)
+
+	// Load and validate all settings up front: those SIGHUP can change
+	// (timeouts, retries, rate limit, cache TTLs, CORS, API keys and
+	// response tuning) and those fixed until restart. Every invalid value
+	// is reported, not just the first
+	cfg, err := loadConfig()
+	startup, startupErr := loadStartupConfig()
+	if err := errors.Join(err, startupErr); err != nil {
+		fatal("invalid configuration", "errors", strings.Split(err.Error(), "\n"))
+	}
+
+	// Pick the edition used when requests don't pass lang, whether to serve
+	// browsers HTML error pages, and how to identify ourselves to Wikipedia
+	defaultLang = startup.DefaultLang
+	htmlErrorPages = startup.HTMLErrorPages
+	userAgent = startup.UserAgent
+
+	// Optionally talk to a mirror or mock server instead of Wikipedia
+	if startup.apiURLTemplate != "" {
+		apiURLTemplate = startup.apiURLTemplate
+	}
+
+	// Size the upstream connection pool and cap parallel requests per host
+	maxIdleConns = startup.MaxIdleConns
+	maxIdleConnsPerHost = startup.MaxIdleConnsPerHost
+	idleConnTimeout = startup.IdleConnTimeout
+	maxRequestsPerHost = startup.MaxRequestsPerHost
+	configureTransport()
+
+	// Cap concurrent requests (MAX_IN_FLIGHT=0 disables the limit), letting
+	// up to MAX_QUEUE more wait for a slot
+	if startup.MaxInFlight > 0 {
+		inFlight = make(chan struct{}, startup.MaxInFlight)
+		if startup.MaxQueue > 0 {
+			queueSlots = make(chan struct{}, startup.MaxQueue)
+		}
+		queueTimeout = startup.QueueTimeout
+	}
+
+	// Configure the summary cache (CACHE_SIZE=0 disables it): in memory, or
+	// in Redis with CACHE_BACKEND=redis, falling back to memory when Redis
+	// is unreachable so a Redis outage doesn't keep the server down
+	if size := startup.CacheSize; size > 0 {
+		if opts := startup.redisOptions; opts != nil {
+			if rc, err := newRedisCache(opts, 2*time.Second, cfg.CacheTTL, cfg.CacheNegativeTTL, cfg.CacheStaleTTL); err != nil {
+				logger.Warn("redis cache unavailable, falling back to the in-memory cache", "error", err)
+			} else {
+				summaryCache = rc
+				logger.Info("using redis cache", "addr", opts.Addr)
+			}
+		}
+		if summaryCache == nil {
+			lru := newLRUCache(size, cfg.CacheTTL, cfg.CacheNegativeTTL, cfg.CacheStaleTTL)
+			if path := startup.CachePersistPath; path != "" {
+				if n, err := lru.Load(path); err != nil {
+					logger.Warn("saved cache unreadable, starting with an empty cache", "path", path, "error", err)
+				} else {
+					logger.Info("restored saved cache", "path", path, "entries", n)
+				}
+			}
+			summaryCache = lru
+		} else if startup.CachePersistPath != "" {
+			logger.Warn("CACHE_PERSIST_PATH ignored: redis keeps cached summaries across restarts")
+		}
+	}
+
+	// Count the most-requested topics for /stats (STATS_TOP_N=0 disables it)
+	if statsTopN = startup.StatsTopN; statsTopN > 0 {
+		topicStats = newTopicCounter(max(10*statsTopN, 1000))
+	}
+
+	// Publish the configuration and remember the settings a reload can't change
+	setConfig(cfg)
+	startupSettings = make(map[string]string, len(staticSettings))
+	for _, key := range staticSettings {
+		startupSettings[key] = os.Getenv(key)
+	}
+
+	// "wikipedia-agent lookup <topic>" runs once from the command line
+	if flag.Arg(0) == "lookup" {
+		os.Exit(runLookupCommand(flag.Args()[1:], os.Stdout, os.Stderr))
+	}
+
+	registerMetrics()
+
+	// Export traces when an OTLP endpoint is configured
+	shutdownTracing, err := setupTracing(context.Background())
+	if err != nil {
+		fatal("invalid tracing configuration", "error", err)
+	}
+
+	mux := http.NewServeMux()
+
+	// Route for health checks. As plain text the body is just the status,
+	// with 503 unless it is "ok", for checkers that only look at those
+	handle(mux, "/health", func(w http.ResponseWriter, r *http.Request) {
+		text, ok := probeWantsText(w, r)
+		if !ok {
+			return
+		}
+		health := HealthResponse{Status: "ok", Checks: map[string]CheckResult{
+			"cache":           checkCache(),
+			"upstream":        checkResult(checkUpstream(r.Context())),
+			"circuit_breaker": checkBreaker(),
+		}}
+		for _, check := range health.Checks {
+			if !check.OK() {
+				health.Status = "degraded"
+			}
+		}
+		if summaryCache != nil {
+			stats := summaryCache.Stats()
+			health.Cache = &stats
+		}
+		if currentConfig().BreakerFailures > 0 {
+			stats := upstreamBreaker.Stats()
+			health.CircuitBreaker = &stats
+		}
+		health.Upstream = upstreamLatency()
+		if text {
+			status := http.StatusOK
+			if health.Status != "ok" {
+				status = http.StatusServiceUnavailable
+			}
+			writeText(w, status, health.Status)
+			return
+		}
+		writeJSON(w, r, http.StatusOK, health)
+	}, http.MethodGet, http.MethodHead)
+
+	// Routes for Kubernetes liveness and readiness probes
+	handle(mux, "/livez", livezHandler, http.MethodGet, http.MethodHead)
+	handle(mux, "/readyz", readyzHandler, http.MethodGet, http.MethodHead)
+
+	// Route for taking the server out of load balancer rotation
+	handle(mux, "/admin/drain", adminDrainHandler, http.MethodPost)
+
+	// Route for inspecting and evicting cached summaries
+	handle(mux, "/admin/cache", adminCacheHandler, http.MethodGet, http.MethodDelete)
 
 	// Route for version info
-	http.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
-		// Set the content type to application/json
-		w.Header().Set("Content-Type", "application/json")
-		// Create a map or struct for the response
-		versionInfo := map[string]string{
-			"name":    "wikipedia-agent",
-			"version": appVersion,
-		}
-		// Encode the map to JSON and write it to the response
-		json.NewEncoder(w).Encode(versionInfo)
-	})
+	handle(mux, "/version", func(w http.ResponseWriter, r *http.Request) {
+		text, ok := probeWantsText(w, r)
+		if !ok {
+			return
+		}
+		v := VersionResponse{
+			Name:      "wikipedia-agent",
+			Version:   appVersion,
+			Commit:    commit,
+			BuildTime: buildTime,
+			GoVersion: runtime.Version(),
+		}
+		if text {
+			writeText(w, http.StatusOK, fmt.Sprintf("%s %s (commit %s, built %s, %s)\n", v.Name, v.Version, v.Commit, v.BuildTime, v.GoVersion))
+			return
+		}
+		writeJSON(w, r, http.StatusOK, v)
+	}, http.MethodGet, http.MethodHead)
 
 	// Route for the Wikipedia lookup functionality
-	http.HandleFunc("/lookup", lookupHandler)
+	handle(mux, "/lookup", lookupHandler, http.MethodGet, http.MethodPost)
+
+	// Route for the summary of a Wikipedia article URL
+	handle(mux, "/summary-by-url", summaryByURLHandler, http.MethodGet)
+
+	// Route for streaming a summary as Server-Sent Events
+	handle(mux, "/stream", streamHandler, http.MethodGet, http.MethodPost)
+
+	// Route for interactive lookups over a WebSocket
+	handle(mux, "/ws", wsHandler, http.MethodGet)
+
+	// Route for checking whether a page exists
+	handle(mux, "/exists", existsHandler, http.MethodGet, http.MethodHead)
+
+	// Route for checking whether a title is a disambiguation page
+	handle(mux, "/detect-disambiguation", detectDisambiguationHandler, http.MethodGet, http.MethodPost)
+
+	// Route for canonical title normalization
+	handle(mux, "/normalize", normalizeHandler, http.MethodGet, http.MethodPost)
+
+	// Route for title search
+	handle(mux, "/search", searchHandler, http.MethodGet, http.MethodPost)
+
+	// Route for spelling suggestions
+	handle(mux, "/suggest", suggestHandler, http.MethodGet, http.MethodPost)
+
+	// Route for a random article summary
+	handle(mux, "/random", randomHandler, http.MethodGet)
+
+	// Route for a page's summary, image, categories and sections at once
+	handle(mux, "/page", pageHandler, http.MethodGet, http.MethodPost)
+
+	// Route for full article text
+	handle(mux, "/content", contentHandler, http.MethodGet, http.MethodPost)
+
+	// Route for an article's rendered HTML
+	handle(mux, "/html", htmlHandler, http.MethodGet, http.MethodPost)
+
+	// Route for the opening sentences of an article's body
+	handle(mux, "/abstract", abstractHandler, http.MethodGet, http.MethodPost)
+
+	// Route for an article's raw wikitext source
+	handle(mux, "/wikitext", wikitextHandler, http.MethodGet, http.MethodPost)
+
+	// Route for an article's table of contents
+	handle(mux, "/sections", sectionsHandler, http.MethodGet, http.MethodPost)
+
+	// Route for a single section's text
+	handle(mux, "/section", sectionHandler, http.MethodGet, http.MethodPost)
+
+	// Route for several sections' text from one page fetch
+	handle(mux, "/section-texts", sectionTextsHandler, http.MethodGet, http.MethodPost)
+
+	// Route for outgoing wikilinks
+	handle(mux, "/links", linksHandler, http.MethodGet, http.MethodPost)
+
+	// Route for the articles listed in a page's "See also" section
+	handle(mux, "/see-also", seeAlsoHandler, http.MethodGet, http.MethodPost)
+
+	// Route for outgoing wikilinks with the sentences they appear in
+	handle(mux, "/extract-links-with-context", linksContextHandler, http.MethodGet, http.MethodPost)
+
+	// Route for external links and cited sources
+	handle(mux, "/references", referencesHandler, http.MethodGet, http.MethodPost)
+
+	// Route for page images
+	handle(mux, "/images", imagesHandler, http.MethodGet, http.MethodPost)
+
+	// Route for a scaled lead image
+	handle(mux, "/thumbnail", thumbnailHandler, http.MethodGet, http.MethodPost)
+
+	// Route for page categories
+	handle(mux, "/categories", categoriesHandler, http.MethodGet, http.MethodPost)
+
+	// Route for a page's one-line description
+	handle(mux, "/definition", definitionHandler, http.MethodGet, http.MethodPost)
+
+	// Route for an article's infobox facts
+	handle(mux, "/infobox", infoboxHandler, http.MethodGet, http.MethodPost)
+
+	// Route for the data tables of an article
+	handle(mux, "/tables", tablesHandler, http.MethodGet, http.MethodPost)
+
+	// Route for the pages in a category
+	handle(mux, "/categories-members", categoryMembersHandler, http.MethodGet, http.MethodPost)
+
+	// Route for the geographic coordinates of a page
+	handle(mux, "/coordinates", coordinatesHandler, http.MethodGet, http.MethodPost)
+
+	// Route for the Wikidata item of a page and what it is an instance of
+	handle(mux, "/entity", entityHandler, http.MethodGet, http.MethodPost)
+
+	// Route for articles near a point
+	handle(mux, "/nearby", nearbyHandler, http.MethodGet)
+
+	// Route for a page's recent edits
+	handle(mux, "/history", historyHandler, http.MethodGet, http.MethodPost)
 
-	// Start the server
-	fmt.Printf("Wikipedia Agent v%s listening on :8080\n", appVersion)
-	log.Fatal(http.ListenAndServe(":8080", nil))
+	// Route for how a page's lead changed between two revisions
+	handle(mux, "/summary-diff", summaryDiffHandler, http.MethodGet)
+
+	// Route for a page's view counts
+	handle(mux, "/pageviews", pageviewsHandler, http.MethodGet, http.MethodPost)
+
+	// Route for articles related to a page
+	handle(mux, "/related", relatedHandler, http.MethodGet, http.MethodPost)
+
+	// Route for one topic's summary in several language editions
+	handle(mux, "/multilang", multilangHandler, http.MethodGet, http.MethodPost)
+
+	// Route for a page's title in another edition
+	handle(mux, "/translate-title", translateTitleHandler, http.MethodGet, http.MethodPost)
+
+	// Route for comparing two topics side by side
+	handle(mux, "/compare", compareHandler, http.MethodGet)
+
+	// Route for the "On this day" feed
+	handle(mux, "/onthisday", onThisDayHandler, http.MethodGet)
+
+	// Route for the day's featured article and most-read articles
+	handle(mux, "/feed", feedHandler, http.MethodGet)
+
+	// Route for looking up many topics at once
+	handle(mux, "/batch", batchHandler, http.MethodPost)
+
+	// Route for summaries of Wikidata items
+	handle(mux, "/by-qid", byQIDHandler, http.MethodGet, http.MethodPost)
+
+	// Routes for the most-requested topics
+	handle(mux, "/stats", statsHandler, http.MethodGet)
+	handle(mux, "/stats/reset", statsResetHandler, http.MethodPost)
+
+	// Route for Prometheus metrics
+	handle(mux, "/metrics", promhttp.Handler().ServeHTTP, http.MethodGet)
+
+	// Routes for the OpenAPI document and its Swagger UI
+	handle(mux, "/openapi.json", openAPIHandler, http.MethodGet)
+	handle(mux, "/docs", docsHandler, http.MethodGet)
+
+	// Expose runtime profiles only with ENABLE_PPROF=true: on their own
+	// listener when PPROF_ADDR is set, otherwise on the main port behind the
+	// same API key check as the other routes
+	var pprofSrv *http.Server
+	if startup.EnablePprof {
+		if addr := startup.PprofAddr; addr != "" {
+			pprofSrv = startPprofServer(addr)
+		} else {
+			registerPprof(mux)
+			logger.Warn("pprof enabled on the main listener; set PPROF_ADDR to serve it on an internal port")
+		}
+	}
+
+	// Serve HTTPS when both TLS files are configured
+	certFile, keyFile := startup.TLSCertFile, startup.TLSKeyFile
+	useTLS := certFile != ""
+
+	// TLS clients negotiate HTTP/2 on their own; ENABLE_H2C=true also
+	// accepts HTTP/2 over cleartext for internal clients with prior knowledge
+	handler := withRequestLogging(withRecovery(withAPIVersion(withConcurrencyLimit(withCORS(withAPIKey(withClientRateLimit(withGzip(withIdempotency(mux)))))))))
+	useH2C := startup.EnableH2C && !useTLS
+	if useH2C {
+		handler = h2c.NewHandler(handler, &http2.Server{})
+	}
+
+	// Start the server. The timeouts bound how long a client may take to
+	// send its request and read the response, so slow or idle connections
+	// can't pile up; WIKI_TIMEOUT should stay below HTTP_WRITE_TIMEOUT.
+	srv := &http.Server{
+		Addr:              listenAddr(*addrFlag, startup),
+		Handler:           handler,
+		ReadHeaderTimeout: startup.HTTPReadHeaderTimeout,
+		ReadTimeout:       startup.HTTPReadTimeout,
+		WriteTimeout:      startup.HTTPWriteTimeout,
+		IdleTimeout:       startup.HTTPIdleTimeout,
+	}
+	srv.RegisterOnShutdown(closeWebSockets)
+	go func() {
+		var err error
+		if useTLS {
+			logger.Info("Wikipedia Agent listening", "version", appVersion, "addr", srv.Addr, "scheme", "https")
+			err = srv.ListenAndServeTLS(certFile, keyFile)
+		} else {
+			logger.Info("Wikipedia Agent listening", "version", appVersion, "addr", srv.Addr, "scheme", "http", "h2c", useH2C)
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("server failed", "error", err)
+		}
+	}()
+
+	// Reload the configuration on SIGHUP; on SIGINT/SIGTERM, or at the end
+	// of a drain started by SIGUSR1 or /admin/drain, let in-flight requests
+	// finish, then exit
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	ctx = watchDrain(ctx, startup.DrainGrace)
+	go watchReload(ctx)
+
+	// Warm the cache with PRELOAD_TOPICS while already serving
+	if topics := startup.PreloadTopics; len(topics) > 0 {
+		if summaryCache == nil {
+			logger.Warn("PRELOAD_TOPICS ignored: caching is disabled")
+		} else {
+			go preloadTopics(ctx, topics)
+		}
+	}
+	<-ctx.Done()
+
+	timeout := startup.ShutdownTimeout
+	logger.Info("shutting down, waiting for in-flight requests", "timeout", timeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	shutdownErr := srv.Shutdown(shutdownCtx)
+
+	// Save the in-memory cache for the next start (CACHE_PERSIST_PATH),
+	// after the last requests have added to it
+	if lru, ok := summaryCache.(*lruCache); ok && startup.CachePersistPath != "" {
+		if n, err := lru.Save(startup.CachePersistPath); err != nil {
+			logger.Warn("saving the cache failed", "path", startup.CachePersistPath, "error", err)
+		} else {
+			logger.Info("saved cache", "path", startup.CachePersistPath, "entries", n)
+		}
+	}
+	if shutdownErr != nil {
+		fatal("shutdown failed", "error", shutdownErr)
+	}
+	if pprofSrv != nil {
+		pprofSrv.Close()
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Warn("flushing traces failed", "error", err)
+	}
+	logger.Info("shutdown complete")
 }
@@ -0,0 +1,25 @@
+package main
+
+import "testing"
+
+func TestNormalizeTopic(t *testing.T) {
+	tests := []struct {
+		name, in, want string
+	}{
+		{"composed", "Café", "Café"},
+		{"decomposed", "Cafe\u0301", "Café"},
+		{"decomposed with underscore", "Ame\u0301lie_Poulain", "Amélie Poulain"},
+		{"underscores", "Alan_Turing", "Alan Turing"},
+		{"surrounding whitespace", "  Go \n", "Go"},
+		{"inner whitespace runs", "General\t\n  relativity", "General relativity"},
+		{"underscores and spaces", "_New__York _", "New York"},
+		{"empty", " \t_ ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeTopic(tt.in); got != tt.want {
+				t.Errorf("normalizeTopic(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
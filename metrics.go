@@ -0,0 +1,104 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	httpRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wikipedia_agent_http_requests_total",
+		Help: "HTTP requests handled, by endpoint and status code.",
+	}, []string{"endpoint", "code"})
+
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "wikipedia_agent_http_request_duration_seconds",
+		Help:    "HTTP request latency, by endpoint.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"endpoint"})
+
+	upstreamRequestDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "wikipedia_agent_upstream_request_duration_seconds",
+		Help:    "Duration of individual Wikipedia API calls.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	requestsQueuedTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "wikipedia_agent_requests_queued_total",
+		Help: "Requests that found MAX_IN_FLIGHT reached and waited in the queue.",
+	})
+
+	requestsServedAfterWaitTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "wikipedia_agent_requests_served_after_wait_total",
+		Help: "Queued requests that got a slot and were served.",
+	})
+
+	requestsShedTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wikipedia_agent_requests_shed_total",
+		Help: "Requests rejected with 503 by the concurrency limit, by reason: queue_full or timeout.",
+	}, []string{"reason"})
+
+	cacheRevalidationsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "wikipedia_agent_cache_revalidations_total",
+		Help: "Expired summaries checked against their page's latest revision, by result: unchanged, changed or failed.",
+	}, []string{"result"})
+)
+
+// registerMetrics registers the collectors exposed at /metrics. Cache
+// counters are only registered when the cache is enabled, and the request
+// queue's when MAX_IN_FLIGHT is set.
+func registerMetrics() {
+	prometheus.MustRegister(httpRequestsTotal, httpRequestDuration, upstreamRequestDuration)
+
+	if inFlight != nil {
+		prometheus.MustRegister(
+			requestsQueuedTotal,
+			requestsServedAfterWaitTotal,
+			requestsShedTotal,
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "wikipedia_agent_requests_in_flight",
+				Help: "Requests being served under MAX_IN_FLIGHT.",
+			}, func() float64 { return float64(len(inFlight)) }),
+			prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+				Name: "wikipedia_agent_request_queue_length",
+				Help: "Requests waiting for a MAX_IN_FLIGHT slot.",
+			}, func() float64 { return float64(len(queueSlots)) }),
+		)
+		// Report both reasons from the start, so rates work before any shed
+		requestsShedTotal.WithLabelValues("queue_full")
+		requestsShedTotal.WithLabelValues("timeout")
+	}
+
+	if summaryCache != nil {
+		prometheus.MustRegister(
+			prometheus.NewCounterFunc(prometheus.CounterOpts{
+				Name: "wikipedia_agent_cache_hits_total",
+				Help: "Summary cache hits.",
+			}, func() float64 { return float64(summaryCache.Stats().Hits) }),
+			prometheus.NewCounterFunc(prometheus.CounterOpts{
+				Name: "wikipedia_agent_cache_misses_total",
+				Help: "Summary cache misses.",
+			}, func() float64 { return float64(summaryCache.Stats().Misses) }),
+			cacheRevalidationsTotal,
+		)
+		for _, result := range []string{"unchanged", "changed", "failed"} {
+			cacheRevalidationsTotal.WithLabelValues(result)
+		}
+	}
+}
+
+// instrumentHandler records request count and latency for h under the given
+// endpoint label.
+func instrumentHandler(endpoint string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		h.ServeHTTP(rec, r)
+
+		httpRequestDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+		httpRequestsTotal.WithLabelValues(endpoint, strconv.Itoa(rec.status)).Inc()
+	})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// requestInfo carries per-request data between the logging middleware and
+// the handlers. ClientIP is the client's address, read through trusted
+// proxies. Handlers fill in Topic and Lang once they have resolved them;
+// withAPIVersion sets APIVersion.
+type requestInfo struct {
+	ID         string
+	ClientIP   string
+	Topic      string
+	Lang       string
+	APIVersion string
+}
+
+type requestInfoKey struct{}
+
+// requestInfoFrom returns the requestInfo attached by withRequestLogging, or
+// an empty one for requests that bypassed the middleware.
+func requestInfoFrom(ctx context.Context) *requestInfo {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		return info
+	}
+	return &requestInfo{}
+}
+
+// statusRecorder captures the status code written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(code int) {
+	rec.status = code
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *statusRecorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
+
+// withRequestLogging assigns every request an ID, echoes it in the
+// X-Request-ID response header, and logs one line per request with its
+// method, path, topic, lang, status and latency. With SLOW_THRESHOLD set,
+// requests slower than it are logged at warn level and the rest at debug.
+func withRequestLogging(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		info := &requestInfo{ID: newRequestID(), ClientIP: clientIP(r, currentConfig().trustedProxies)}
+		w.Header().Set("X-Request-ID", info.ID)
+
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, info)))
+
+		latency := time.Since(start)
+		attrs := []any{
+			slog.String("request_id", info.ID),
+			slog.String("method", r.Method),
+			slog.String("path", r.URL.Path),
+			slog.String("client_ip", info.ClientIP),
+			slog.Int("status", rec.status),
+			slog.Duration("latency", latency),
+		}
+		if info.Topic != "" {
+			attrs = append(attrs, slog.String("topic", info.Topic))
+		}
+		if info.Lang != "" {
+			attrs = append(attrs, slog.String("lang", info.Lang))
+		}
+		switch threshold := currentConfig().SlowThreshold; {
+		case threshold <= 0:
+			logger.Info("request", attrs...)
+		case latency > threshold:
+			logger.Warn("slow request", attrs...)
+		default:
+			logger.Debug("request", attrs...)
+		}
+	})
+}
+
+// withRecovery turns a panic in a handler into a logged stack trace and a
+// 500 JSON response instead of a dropped connection. It must run inside
+// withRequestLogging so the log line carries the request ID.
+func withRecovery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				// The handler asked net/http to abort the response
+				panic(v)
+			}
+			logger.Error("panic serving request",
+				slog.String("request_id", requestInfoFrom(r.Context()).ID),
+				slog.String("path", r.URL.Path),
+				slog.Any("panic", v),
+				slog.String("stack", string(debug.Stack())),
+			)
+			writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", "internal server error")
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newRequestID returns 16 random hex characters.
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// withCORS adds CORS headers for the origins in CORS_ALLOWED_ORIGINS and
+// answers preflight requests itself. "*" allows any origin and an empty list
+// disables CORS. Requests from origins outside the allowlist get no CORS
+// headers, so browsers block them.
+func withCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		corsOrigins := currentConfig().CORSOrigins
+		if origin == "" || len(corsOrigins) == 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Origin")
+		allowed := ""
+		for _, o := range corsOrigins {
+			if o == "*" || o == origin {
+				allowed = o
+				break
+			}
+		}
+		if allowed != "" {
+			w.Header().Set("Access-Control-Allow-Origin", allowed)
+			w.Header().Set("Access-Control-Expose-Headers", "X-Request-ID, X-Content-Truncated, X-Content-Source, Idempotent-Replayed, API-Version")
+		}
+
+		// Preflight: answer directly instead of routing to the handler
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed != "" {
+				w.Header().Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+				w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Accept, Cache-Control, X-API-Key, Idempotency-Key")
+				w.Header().Set("Access-Control-Max-Age", "600")
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// MultilangItem is one language's entry in a /multilang response. Exactly
+// one of Summary and Error is set.
+type MultilangItem struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// MultilangResponse is the JSON body returned by /multilang. Lang is the
+// edition the topic was resolved in; Summaries is keyed by language code.
+type MultilangResponse struct {
+	Topic     string                   `json:"topic"`
+	Lang      string                   `json:"lang"`
+	Summaries map[string]MultilangItem `json:"summaries"`
+}
+
+// multilangHandler fetches the summary of the same page in each edition
+// listed in "langs" (comma-separated). The topic is resolved in the "lang"
+// edition and its interlanguage links give the title elsewhere; editions it
+// doesn't link to are tried with the same title. Failures are reported per
+// language.
+func multilangHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Resolve the source language, target languages and topic
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	langs, ok := requestLangs(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	// 2. Find the page's title in the other editions
+	title, links, err := wikiClient.LangLinks(r.Context(), topic, lang)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "language links lookup")
+		return
+	}
+	links[lang] = title
+
+	// 3. Fetch every edition's summary concurrently
+	resp := MultilangResponse{Topic: topic, Lang: lang, Summaries: make(map[string]MultilangItem, len(langs))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, l := range langs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			t, ok := links[l]
+			if !ok {
+				t = title
+			}
+			item := MultilangItem{Title: t}
+			if result, err := fetchWikipediaSummary(r.Context(), t, l); err != nil {
+				item.Error = err.Error()
+			} else {
+				item.Title, item.Summary, item.URL = result.Title, result.Summary, result.URL
+			}
+			mu.Lock()
+			resp.Summaries[l] = item
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// requestLangs returns the distinct language codes in the comma-separated
+// "langs" query parameter. When it is empty or names an unsupported edition
+// it writes a 400 response and returns false.
+func requestLangs(w http.ResponseWriter, r *http.Request) ([]string, bool) {
+	var langs []string
+	seen := make(map[string]bool)
+	for _, l := range strings.Split(r.URL.Query().Get("langs"), ",") {
+		l = strings.TrimSpace(l)
+		if l == "" || seen[l] {
+			continue
+		}
+		if !isSupportedLanguage(l) {
+			paramError{code: "UNSUPPORTED_LANGUAGE", name: "langs", msg: fmt.Sprintf("unsupported language code %q", l)}.write(w, r)
+			return nil, false
+		}
+		seen[l] = true
+		langs = append(langs, l)
+	}
+	if len(langs) == 0 {
+		paramError{name: "langs", msg: "langs is required, e.g. langs=de,fr"}.write(w, r)
+		return nil, false
+	}
+	return langs, true
+}
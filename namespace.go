@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// nonArticleNamespaces maps the lower-cased canonical names of MediaWiki's
+// namespaces other than the article one, and their common aliases, to the
+// namespace they denote. The canonical English names work in every
+// edition; localized ones such as "Kategorie" aren't recognized and reach
+// Wikipedia like any other title.
+var nonArticleNamespaces = map[string]string{
+	"user":           "User",
+	"wikipedia":      "Wikipedia",
+	"project":        "Wikipedia",
+	"wp":             "Wikipedia",
+	"file":           "File",
+	"image":          "File",
+	"media":          "Media",
+	"mediawiki":      "MediaWiki",
+	"template":       "Template",
+	"help":           "Help",
+	"category":       "Category",
+	"portal":         "Portal",
+	"draft":          "Draft",
+	"timedtext":      "TimedText",
+	"module":         "Module",
+	"special":        "Special",
+	"talk":           "Talk",
+	"user talk":      "User talk",
+	"wikipedia talk": "Wikipedia talk",
+	"project talk":   "Wikipedia talk",
+	"wt":             "Wikipedia talk",
+	"file talk":      "File talk",
+	"image talk":     "File talk",
+	"mediawiki talk": "MediaWiki talk",
+	"template talk":  "Template talk",
+	"help talk":      "Help talk",
+	"category talk":  "Category talk",
+	"portal talk":    "Portal talk",
+	"draft talk":     "Draft talk",
+	"timedtext talk": "TimedText talk",
+	"module talk":    "Module talk",
+}
+
+// NotArticleError is returned for topics naming a page outside the article
+// namespace, such as "Category:Physics", which have no summary. Hint says
+// which endpoint serves such pages instead.
+type NotArticleError struct {
+	Topic     string
+	Namespace string
+	Hint      string
+}
+
+func (e *NotArticleError) Error() string {
+	return fmt.Sprintf("%q is a %s page, not an article, so it has no summary; %s", e.Topic, e.Namespace, e.Hint)
+}
+
+// checkArticleTopic returns a *NotArticleError when topic starts with the
+// prefix of a non-article namespace, and nil otherwise.
+func checkArticleTopic(topic string) error {
+	prefix, name, found := strings.Cut(topic, ":")
+	name = strings.TrimSpace(name)
+	if !found || name == "" {
+		return nil
+	}
+	ns, ok := nonArticleNamespaces[strings.ToLower(strings.Join(strings.Fields(strings.ReplaceAll(prefix, "_", " ")), " "))]
+	if !ok {
+		return nil
+	}
+	var hint string
+	switch ns {
+	case "Category":
+		hint = "use /categories-members?category=" + url.QueryEscape(name) + " to list its pages"
+	case "Special", "Media":
+		hint = "such pages have no content to fetch"
+	default:
+		hint = "use /wikitext?topic=" + url.QueryEscape(topic) + " for its source"
+	}
+	return &NotArticleError{Topic: topic, Namespace: ns, Hint: hint}
+}
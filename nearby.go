@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Geosearch limits imposed by the MediaWiki API.
+const (
+	defaultNearbyRadius = 1000
+	minNearbyRadius     = 10
+	maxNearbyRadius     = 10000
+	defaultNearbyLimit  = 10
+	maxNearbyLimit      = 500
+)
+
+// NearbyResponse is the JSON body returned by /nearby, with Pages ordered by
+// distance from the given point.
+type NearbyResponse struct {
+	Lat    float64      `json:"lat"`
+	Lon    float64      `json:"lon"`
+	Radius int          `json:"radius"`
+	Lang   string       `json:"lang"`
+	Pages  []NearbyPage `json:"pages"`
+}
+
+// nearbyHandler lists the geotagged articles within "radius" metres
+// (default 1000, 10 to 10000) of the "lat"/"lon" point, closest first.
+// "limit" caps the number of results (default 10, max 500).
+func nearbyHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	lat, ok := floatParam(w, r, "lat", -90, 90)
+	if !ok {
+		return
+	}
+	lon, ok := floatParam(w, r, "lon", -180, 180)
+	if !ok {
+		return
+	}
+	radius, ok := intRangeParam(w, r, "radius", defaultNearbyRadius, minNearbyRadius, maxNearbyRadius)
+	if !ok {
+		return
+	}
+	limit, ok := intRangeParam(w, r, "limit", defaultNearbyLimit, 1, maxNearbyLimit)
+	if !ok {
+		return
+	}
+
+	pages, err := wikiClient.Nearby(r.Context(), lang, lat, lon, radius, limit)
+	if err != nil {
+		writeUpstreamError(w, r, err, fmt.Sprintf("%g,%g", lat, lon), "nearby lookup")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, NearbyResponse{Lat: lat, Lon: lon, Radius: radius, Lang: lang, Pages: pages})
+}
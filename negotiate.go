@@ -0,0 +1,81 @@
+package main
+
+import (
+	"math"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// negotiateContentType picks the media type from offers that best matches the
+// request's Accept header. An empty or wildcard-only header selects the first
+// offer, so callers should list their preferred representation first.
+func negotiateContentType(accept string, offers ...string) string {
+	if accept == "" {
+		return offers[0]
+	}
+
+	best, bestQ := offers[0], -1.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+		for _, offer := range offers {
+			if !mediaTypeMatches(mediaType, offer) {
+				continue
+			}
+			// Prefer a higher q; on a tie prefer an exact match over a wildcard.
+			if q > bestQ || (q == bestQ && mediaType == offer && best != offer) {
+				best, bestQ = offer, q
+			}
+			break
+		}
+	}
+	return best
+}
+
+// mediaTypeMatches reports whether an Accept media range covers offer.
+func mediaTypeMatches(mediaRange, offer string) bool {
+	if mediaRange == "*/*" || mediaRange == offer {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(mediaRange, "/*"); ok {
+		return strings.HasPrefix(offer, prefix+"/")
+	}
+	return false
+}
+
+// negotiateLanguage picks the supported edition that best matches an
+// Accept-Language header such as "de-CH, de;q=0.9, en;q=0.8". Region and
+// script subtags are ignored, so "pt-BR" selects pt; among equal q values
+// the first listed wins. ok is false when no listed language has an
+// edition.
+func negotiateLanguage(header string) (lang string, ok bool) {
+	bestQ := 0.0
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, found := strings.CutPrefix(strings.TrimSpace(param), "q="); found {
+				if q, _ = strconv.ParseFloat(v, 64); math.IsNaN(q) {
+					q = 0
+				}
+			}
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(strings.TrimSpace(tag)), "-")
+		if q > bestQ && isSupportedLanguage(primary) {
+			lang, bestQ, ok = primary, q, true
+		}
+	}
+	return lang, ok
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// NormalizeResponse is the JSON body returned by /normalize. Title is the
+// canonical title and Key the same title with underscores, as used in
+// article URLs. RedirectedFrom and Fragment are set when the topic is a
+// redirect, the latter when it points to a section.
+type NormalizeResponse struct {
+	Topic          string `json:"topic"`
+	Lang           string `json:"lang"`
+	Title          string `json:"title"`
+	Key            string `json:"key"`
+	URL            string `json:"url"`
+	Normalized     bool   `json:"normalized"`
+	Redirected     bool   `json:"redirected"`
+	RedirectedFrom string `json:"redirected_from,omitempty"`
+	Fragment       string `json:"fragment,omitempty"`
+}
+
+// normalizeHandler returns the canonical title and URL a loose topic
+// resolves to, after capitalization, underscores and redirects, without
+// fetching the page. Normalized reports whether the title differs from the
+// topic as given. Unlike /lookup it never falls back to a search, so
+// unknown titles get 404.
+func normalizeHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	res, err := wikiClient.Normalize(r.Context(), topic, lang)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "title normalization")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, NormalizeResponse{
+		Topic:          topic,
+		Lang:           lang,
+		Title:          res.Title,
+		Key:            strings.ReplaceAll(res.Title, " ", "_"),
+		URL:            articleURL(res.Title, lang),
+		Normalized:     res.Title != topic,
+		Redirected:     res.RedirectedFrom != "",
+		RedirectedFrom: res.RedirectedFrom,
+		Fragment:       res.Fragment,
+	})
+}
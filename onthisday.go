@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// onThisDayKinds are the entry lists of the "On this day" feed.
+var onThisDayKinds = []string{"selected", "events", "births", "deaths", "holidays"}
+
+// OnThisDayResponse is the JSON body returned by /onthisday.
+type OnThisDayResponse struct {
+	Month  int               `json:"month"`
+	Day    int               `json:"day"`
+	Lang   string            `json:"lang"`
+	Type   string            `json:"type"`
+	Events []HistoricalEvent `json:"events"`
+}
+
+// onThisDayHandler returns what happened on a day of the year in past
+// years, from Wikipedia's "On this day" feed, for daily-facts widgets.
+// "month" and "day" pick the date (default today, UTC) and "type" the list:
+// the editors' "selected" events by default, or all "events", "births",
+// "deaths" or "holidays".
+func onThisDayHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	today := time.Now().UTC()
+	month, ok := intRangeParam(w, r, "month", int(today.Month()), 1, 12)
+	if !ok {
+		return
+	}
+	// Any year will do as long as it is a leap year, so February 29 counts
+	lastDay := time.Date(2000, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	day, ok := intRangeParam(w, r, "day", min(today.Day(), lastDay), 1, lastDay)
+	if !ok {
+		return
+	}
+	kind, ok := enumParam(w, r, "type", "selected", onThisDayKinds...)
+	if !ok {
+		return
+	}
+
+	events, err := wikiClient.OnThisDay(r.Context(), lang, kind, month, day)
+	if errors.Is(err, ErrNoFeed) {
+		writeError(w, r, http.StatusNotFound, "NO_FEED", fmt.Sprintf("the %s edition has no \"On this day\" feed", lang))
+		return
+	}
+	if err != nil {
+		writeUpstreamError(w, r, err, fmt.Sprintf("%02d-%02d", month, day), "on this day lookup")
+		return
+	}
+	writeJSON(w, r, http.StatusOK, OnThisDayResponse{Month: month, Day: day, Lang: lang, Type: kind, Events: events})
+}
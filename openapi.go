@@ -0,0 +1,49 @@
+package main
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// openAPISpec is the hand-written OpenAPI 3 description of the HTTP API.
+// Keep it in step with the handlers when adding or changing endpoints.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// openAPIHandler serves openAPISpec with info.version set to appVersion.
+func openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	var spec map[string]any
+	if err := json.Unmarshal(openAPISpec, &spec); err != nil {
+		writeError(w, r, http.StatusInternalServerError, "INTERNAL_ERROR", fmt.Sprintf("invalid OpenAPI document: %v", err))
+		return
+	}
+	if info, ok := spec["info"].(map[string]any); ok {
+		info["version"] = appVersion
+	}
+	writeJSON(w, r, http.StatusOK, spec)
+}
+
+// docsPage renders /openapi.json with Swagger UI, loaded from a CDN.
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Wikipedia Agent API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>SwaggerUIBundle({url: "openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// docsHandler serves the interactive API documentation.
+func docsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, docsPage)
+}
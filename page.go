@@ -0,0 +1,156 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// pageParts are the optional parts of a /page response, in the order the
+// "include" parameter documents them.
+var pageParts = []string{"summary", "image", "categories", "sections"}
+
+// PageResponse is the JSON body returned by /page. Parts left out by
+// "include", or empty for the page, are omitted.
+type PageResponse struct {
+	Topic      string   `json:"topic"`
+	Title      string   `json:"title"`
+	Lang       string   `json:"lang"`
+	URL        string   `json:"url"`
+	Summary    string   `json:"summary,omitempty"`
+	Truncated  bool     `json:"truncated,omitempty"`
+	Image      string   `json:"image,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	Sections   []string `json:"sections,omitempty"`
+}
+
+// pageHandler returns the summary, lead image, categories and section
+// titles of a page in one response, fetching the parts concurrently. The
+// "include" query parameter, a comma-separated subset of pageParts, limits
+// the parts fetched; by default all of them are.
+func pageHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	include, ok := pageInclude(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	// Fetch each part in its own goroutine; every one resolves the title
+	// itself, so whichever finishes sets it
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		resp     = PageResponse{Topic: topic, Lang: lang}
+	)
+	fetch := func(part func() (title string, err error)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			title, err := part()
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			resp.Title = title
+		}()
+	}
+	if include["summary"] {
+		fetch(func() (string, error) {
+			result, err := fetchWikipediaSummary(r.Context(), topic, lang)
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Summary, resp.URL = result.Summary, result.URL
+			if limit := currentConfig().MaxSummaryBytes; limit > 0 {
+				resp.Summary, resp.Truncated = truncateBytes(resp.Summary, limit)
+			}
+			return result.Title, err
+		})
+	}
+	if include["image"] {
+		fetch(func() (string, error) {
+			title, image, err := wikiClient.LeadImage(r.Context(), topic, lang)
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Image = image
+			return title, err
+		})
+	}
+	if include["categories"] {
+		fetch(func() (string, error) {
+			title, categories, err := wikiClient.Categories(r.Context(), topic, lang)
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Categories = categories
+			return title, err
+		})
+	}
+	if include["sections"] {
+		fetch(func() (string, error) {
+			title, sections, err := wikiClient.Sections(r.Context(), topic, lang)
+			mu.Lock()
+			defer mu.Unlock()
+			for _, s := range sections {
+				resp.Sections = append(resp.Sections, s.Title)
+			}
+			return title, err
+		})
+	}
+	wg.Wait()
+
+	var disambig *DisambiguationError
+	if errors.As(firstErr, &disambig) {
+		writeJSON(w, r, http.StatusMultipleChoices, DisambiguationResponse{Topic: topic, Title: disambig.Title, Lang: lang, Options: disambig.Options})
+		return
+	}
+	if firstErr != nil {
+		writeUpstreamError(w, r, firstErr, topic, "page lookup")
+		return
+	}
+	if !include["summary"] {
+		// Only the summary checks the resolved title against the topic filter
+		if !checkTopicAllowed(w, r, resp.Title) {
+			return
+		}
+		resp.URL = articleURL(resp.Title, lang)
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// pageInclude parses the "include" query parameter into the set of parts
+// to fetch, all of them when it lists none, writing a 400 response for
+// unknown parts.
+func pageInclude(w http.ResponseWriter, r *http.Request) (map[string]bool, bool) {
+	include := make(map[string]bool, len(pageParts))
+	for _, p := range strings.Split(r.URL.Query().Get("include"), ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if !slices.Contains(pageParts, p) {
+			paramError{name: "include", msg: fmt.Sprintf("unknown include part %q; valid parts: %s", p, strings.Join(pageParts, ", ")), allowed: pageParts}.write(w, r)
+			return nil, false
+		}
+		include[p] = true
+	}
+	if len(include) == 0 {
+		for _, p := range pageParts {
+			include[p] = true
+		}
+	}
+	return include, true
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Bounds for the /pageviews date range, in days: the default span, ending
+// yesterday, and the longest one accepted per granularity.
+const (
+	defaultPageviewsDays    = 30
+	maxPageviewsDailyDays   = 366
+	maxPageviewsMonthlyDays = 10 * 366
+)
+
+// PageviewsResponse is the JSON body returned by /pageviews. Total is the
+// sum of Views.
+type PageviewsResponse struct {
+	Topic       string          `json:"topic"`
+	Title       string          `json:"title"`
+	Lang        string          `json:"lang"`
+	Granularity string          `json:"granularity"`
+	Start       string          `json:"start"`
+	End         string          `json:"end"`
+	Total       int             `json:"total"`
+	Views       []PageviewCount `json:"views"`
+}
+
+// pageviewsHandler returns how often a page was viewed per day or month
+// over a date range, for ranking topics by popularity. "start" and "end"
+// (YYYY-MM-DD, inclusive) default to the 30 days up to yesterday (UTC);
+// "granularity" is "daily" (default, ranges up to 366 days) or "monthly"
+// (up to ten years).
+func pageviewsHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	granularity, ok := enumParam(w, r, "granularity", "daily", "daily", "monthly")
+	if !ok {
+		return
+	}
+	yesterday := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -1)
+	end, ok := dateParam(w, r, "end", yesterday)
+	if !ok {
+		return
+	}
+	start, ok := dateParam(w, r, "start", end.AddDate(0, 0, 1-defaultPageviewsDays))
+	if !ok {
+		return
+	}
+	maxDays := maxPageviewsDailyDays
+	if granularity == "monthly" {
+		maxDays = maxPageviewsMonthlyDays
+	}
+	switch days := int(end.Sub(start).Hours()/24) + 1; {
+	case days < 1:
+		paramError{name: "start", msg: "start must not be after end"}.write(w, r)
+		return
+	case days > maxDays:
+		paramError{name: "start", msg: fmt.Sprintf("the range from start to end must span at most %d days for %s granularity", maxDays, granularity)}.write(w, r)
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, views, err := wikiClient.Pageviews(r.Context(), topic, lang, granularity, start, end)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "pageviews lookup")
+		return
+	}
+
+	resp := PageviewsResponse{
+		Topic:       topic,
+		Title:       title,
+		Lang:        lang,
+		Granularity: granularity,
+		Start:       start.Format(time.DateOnly),
+		End:         end.Format(time.DateOnly),
+		Views:       views,
+	}
+	for _, v := range views {
+		resp.Total += v.Views
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"math"
+	"net/http"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 500
+)
+
+// parsePagination reads the "offset" and "limit" query parameters (defaults
+// 0 and 100, limit at most 500). On invalid values it writes a 400 response
+// and returns false.
+func parsePagination(w http.ResponseWriter, r *http.Request) (offset, limit int, ok bool) {
+	if offset, ok = intRangeParam(w, r, "offset", 0, 0, math.MaxInt); !ok {
+		return 0, 0, false
+	}
+	if limit, ok = intRangeParam(w, r, "limit", defaultPageLimit, 1, maxPageLimit); !ok {
+		return 0, 0, false
+	}
+	return offset, limit, true
+}
+
+// paginate returns the window of items selected by offset and limit. The
+// result is never nil, so it encodes as a JSON array.
+func paginate[T any](items []T, offset, limit int) []T {
+	if offset >= len(items) {
+		return []T{}
+	}
+	end := min(offset+limit, len(items))
+	return items[offset:end]
+}
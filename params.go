@@ -0,0 +1,147 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// paramError is a 400 response rejecting a query parameter. Besides the
+// message it names the parameter and, where they can be listed, the values
+// it accepts: a set of allowed values or an inclusive numeric range, so
+// clients can correct the request without parsing the message.
+type paramError struct {
+	code     string // INVALID_PARAMETER when empty
+	name     string
+	msg      string
+	allowed  []string
+	min, max *float64
+}
+
+// write sends e as the response.
+func (e paramError) write(w http.ResponseWriter, r *http.Request) {
+	writeErrorResponse(w, r, http.StatusBadRequest, ErrorResponse{
+		Error:   e.msg,
+		Code:    cmp.Or(e.code, "INVALID_PARAMETER"),
+		Param:   e.name,
+		Allowed: e.allowed,
+		Min:     e.min,
+		Max:     e.max,
+	})
+}
+
+// between returns e with the range [lo, hi] as its accepted values; an
+// infinite bound is left out.
+func (e paramError) between(lo, hi float64) paramError {
+	if !math.IsInf(lo, -1) {
+		e.min = &lo
+	}
+	if !math.IsInf(hi, 1) {
+		e.max = &hi
+	}
+	return e
+}
+
+// intRangeParam reads an optional integer query parameter that must lie
+// within [lo, hi], returning fallback when it is absent. hi may be
+// math.MaxInt for no upper bound. On an invalid value it writes a 400
+// response and returns false.
+func intRangeParam(w http.ResponseWriter, r *http.Request, name string, fallback, lo, hi int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return fallback, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < lo || n > hi {
+		var msg string
+		switch {
+		case hi < math.MaxInt:
+			msg = fmt.Sprintf("%s must be an integer between %d and %d", name, lo, hi)
+		case lo == 0:
+			msg = fmt.Sprintf("%s must be a non-negative integer", name)
+		case lo == 1:
+			msg = fmt.Sprintf("%s must be a positive integer", name)
+		default:
+			msg = fmt.Sprintf("%s must be an integer of at least %d", name, lo)
+		}
+		upper := math.Inf(1)
+		if hi < math.MaxInt {
+			upper = float64(hi)
+		}
+		paramError{name: name, msg: msg}.between(float64(lo), upper).write(w, r)
+		return 0, false
+	}
+	return n, true
+}
+
+// positiveIntParam reads an optional positive integer query parameter,
+// returning 0 when it is absent. On an invalid value it writes a 400
+// response and returns false.
+func positiveIntParam(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	return intRangeParam(w, r, name, 0, 1, math.MaxInt)
+}
+
+// floatParam reads a required number query parameter that must lie within
+// [lo, hi]. On a missing or invalid value it writes a 400 response and
+// returns false.
+func floatParam(w http.ResponseWriter, r *http.Request, name string, lo, hi float64) (float64, bool) {
+	f, err := strconv.ParseFloat(r.URL.Query().Get(name), 64)
+	if err != nil || f < lo || f > hi {
+		paramError{name: name, msg: fmt.Sprintf("%s must be a number between %g and %g", name, lo, hi)}.between(lo, hi).write(w, r)
+		return 0, false
+	}
+	return f, true
+}
+
+// dateParam reads an optional YYYY-MM-DD query parameter, returning
+// fallback when it is absent. On an invalid date it writes a 400 response
+// and returns false.
+func dateParam(w http.ResponseWriter, r *http.Request, name string, fallback time.Time) (time.Time, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return fallback, true
+	}
+	t, err := time.Parse(time.DateOnly, v)
+	if err != nil {
+		paramError{name: name, msg: fmt.Sprintf("%s must be a date in YYYY-MM-DD format", name)}.write(w, r)
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+// enumParam reads an optional query parameter that must be one of allowed,
+// returning fallback when it is absent. On any other value it writes a 400
+// response listing the allowed ones and returns false.
+func enumParam(w http.ResponseWriter, r *http.Request, name, fallback string, allowed ...string) (string, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return fallback, true
+	}
+	for _, a := range allowed {
+		if v == a {
+			return v, true
+		}
+	}
+	paramError{name: name, msg: fmt.Sprintf("%s must be %s", name, orList(allowed)), allowed: allowed}.write(w, r)
+	return "", false
+}
+
+// boolParam reads an optional "true" or "false" query parameter,
+// returning fallback when it is absent. On any other value it writes a 400
+// response and returns false as ok.
+func boolParam(w http.ResponseWriter, r *http.Request, name string, fallback bool) (value, ok bool) {
+	v, ok := enumParam(w, r, name, strconv.FormatBool(fallback), "true", "false")
+	return v == "true", ok
+}
+
+// orList joins values for a message: "a", "a or b", "a, b or c".
+func orList(values []string) string {
+	if len(values) < 2 {
+		return strings.Join(values, "")
+	}
+	return strings.Join(values[:len(values)-1], ", ") + " or " + values[len(values)-1]
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// registerPprof adds the net/http/pprof handlers under /debug/pprof/. They
+// are left uninstrumented so profiling traffic doesn't skew the metrics.
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
+
+// startPprofServer serves the pprof handlers alone on addr, typically a
+// loopback or cluster-internal address, so profiles stay off the public
+// port. It has no write timeout because CPU profiles and traces stream for
+// as long as their seconds parameter asks.
+func startPprofServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	registerPprof(mux)
+	srv := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		logger.Info("pprof listening", "addr", addr)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("pprof server failed", "error", err)
+		}
+	}()
+	return srv
+}
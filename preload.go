@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// preloadTopics fetches the summaries of topics on the default edition into
+// the cache, BATCH_CONCURRENCY at a time, so the first requests for them
+// don't pay the upstream latency. Failures are logged and skipped. It is
+// meant to run in the background while the server starts.
+func preloadTopics(ctx context.Context, topics []string) {
+	start := time.Now()
+	var failed atomic.Int64
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for range min(currentConfig().BatchConcurrency, len(topics)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for topic := range jobs {
+				if _, err := fetchWikipediaSummary(ctx, topic, defaultLang); err != nil {
+					failed.Add(1)
+					logger.Warn("preload failed", "topic", topic, "error", err)
+				}
+			}
+		}()
+	}
+	for _, topic := range topics {
+		if topic = normalizeTopic(topic); topic != "" {
+			jobs <- topic
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	logger.Info("preload finished", "topics", len(topics), "failed", failed.Load(), "duration", time.Since(start))
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"net/http"
+)
+
+// defaultProject is the Wikimedia project lookups go to without a
+// "project" parameter.
+const defaultProject = "wikipedia"
+
+// wikiProjects are the Wikimedia projects the "project" parameter can route
+// /lookup and /definition to. Each runs MediaWiki on per-language
+// subdomains ({lang}.{project}.org) with the same action and REST APIs, so
+// every lookup path works against them unchanged.
+var wikiProjects = []string{"wikipedia", "wiktionary", "wikiquote", "wikibooks", "wikisource", "wikinews", "wikivoyage", "wikiversity"}
+
+type projectKey struct{}
+
+// withProject returns a copy of ctx under which upstream calls go to the
+// given Wikimedia project instead of Wikipedia.
+func withProject(ctx context.Context, project string) context.Context {
+	return context.WithValue(ctx, projectKey{}, project)
+}
+
+// projectFrom returns the project set on ctx by withProject, or
+// defaultProject.
+func projectFrom(ctx context.Context) string {
+	if project, ok := ctx.Value(projectKey{}).(string); ok {
+		return project
+	}
+	return defaultProject
+}
+
+// requestProject reads the optional "project" query parameter. An unknown
+// project gets a 400 response listing the supported ones, and ok false.
+func requestProject(w http.ResponseWriter, r *http.Request) (project string, ok bool) {
+	return enumParam(w, r, "project", defaultProject, wikiProjects...)
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+)
+
+// randomCandidates is how many random titles we ask for per /random request;
+// the first one that yields a summary wins.
+const randomCandidates = 5
+
+// RandomResponse is the JSON body returned by /random.
+type RandomResponse struct {
+	Title   string `json:"title"`
+	Summary string `json:"summary"`
+	Lang    string `json:"lang"`
+	URL     string `json:"url"`
+}
+
+// randomHandler returns the summary of a random article. The optional "lang"
+// query parameter selects the Wikipedia edition.
+func randomHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+
+	// 1. Pick a handful of random titles
+	titles, err := wikiClient.Random(r.Context(), lang, randomCandidates)
+	if err != nil {
+		writeUpstreamError(w, r, err, "", "random lookup")
+		return
+	}
+
+	// 2. Return the first one that produces a summary
+	for _, title := range titles {
+		result, err := fetchWikipediaSummary(r.Context(), title, lang)
+		if err != nil || result.Summary == "" {
+			continue
+		}
+		requestInfoFrom(r.Context()).Topic = title
+		writeJSON(w, r, http.StatusOK, RandomResponse{Title: result.Title, Summary: result.Summary, Lang: lang, URL: result.URL})
+		return
+	}
+	writeError(w, r, http.StatusServiceUnavailable, "NO_RANDOM_ARTICLE", "no random article with a summary found, try again")
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Readability is the reading level of a text, added to /lookup responses
+// with "readability=true". Score is the Flesch reading ease, from about 0
+// (very hard) to 100 (very easy); Grade is the Flesch–Kincaid grade level,
+// the years of US schooling needed to follow the text. Both formulas were
+// calibrated on English, so for other editions they are only a rough
+// guide.
+type Readability struct {
+	Score     float64 `json:"score"`
+	Grade     float64 `json:"grade"`
+	Sentences int     `json:"sentences"`
+	Words     int     `json:"words"`
+	Syllables int     `json:"syllables"`
+}
+
+// measureReadability scores text with the Flesch reading ease and
+// Flesch–Kincaid grade formulas. Sentences are split as for "sentences",
+// and syllables estimated by counting vowel groups. Text without words
+// scores nil.
+func measureReadability(text string) *Readability {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '\'' && r != '’'
+	})
+	ends := sentenceEnds(text)
+	rd := &Readability{Sentences: len(ends)}
+	for _, word := range words {
+		if n := countSyllables(word); n > 0 {
+			rd.Words++
+			rd.Syllables += n
+		}
+	}
+	if rd.Words == 0 {
+		return nil
+	}
+	// Text after the last full stop, or without one, is a sentence too
+	if len(ends) == 0 || strings.TrimSpace(text[ends[len(ends)-1]:]) != "" {
+		rd.Sentences++
+	}
+
+	wordsPerSentence := float64(rd.Words) / float64(rd.Sentences)
+	syllablesPerWord := float64(rd.Syllables) / float64(rd.Words)
+	rd.Score = roundTenth(206.835 - 1.015*wordsPerSentence - 84.6*syllablesPerWord)
+	rd.Grade = roundTenth(0.39*wordsPerSentence + 11.8*syllablesPerWord - 15.59)
+	return rd
+}
+
+// vowels are the letters countSyllables treats as syllable nuclei,
+// including the accented ones of the Latin-script editions.
+const vowels = "aeiouyàáâãäåæèéêëìíîïòóôõöøùúûüýÿœ"
+
+// countSyllables estimates the syllables of word as its groups of
+// consecutive vowels, less a silent final "e" as in "make". A word with
+// letters has at least one syllable; a number counts as one, and anything
+// else, such as a stray apostrophe, as none.
+func countSyllables(word string) int {
+	word = strings.ToLower(strings.Trim(word, "'’"))
+	n, inVowel := 0, false
+	for _, r := range word {
+		isVowel := strings.ContainsRune(vowels, r)
+		if isVowel && !inVowel {
+			n++
+		}
+		inVowel = isVowel
+	}
+	if n > 1 && strings.HasSuffix(word, "e") && !strings.HasSuffix(word, "le") && !strings.HasSuffix(word, "ee") {
+		n--
+	}
+	if n == 0 && word != "" {
+		n = 1
+	}
+	return n
+}
+
+// roundTenth rounds x to one decimal place.
+func roundTenth(x float64) float64 {
+	return math.Round(x*10) / 10
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// upstreamCheck caches the outcome of the last connectivity check and the
+// latencies of the latest successful ones.
+var upstreamCheck struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+	latencies []time.Duration // oldest first, at most probeSamples
+}
+
+// probeSamples is the number of recent probes the rolling average latency
+// in /health covers.
+const probeSamples = 10
+
+// checkUpstream reports whether the Wikipedia API is reachable, reusing a
+// result younger than READY_CACHE_TTL so probes don't add load on Wikipedia.
+func checkUpstream(ctx context.Context) error {
+	upstreamCheck.mu.Lock()
+	defer upstreamCheck.mu.Unlock()
+
+	if !upstreamCheck.checkedAt.IsZero() && time.Since(upstreamCheck.checkedAt) < currentConfig().ReadyCacheTTL {
+		return upstreamCheck.err
+	}
+	start := time.Now()
+	upstreamCheck.err = pingWikipedia(ctx)
+	upstreamCheck.checkedAt = time.Now()
+	if upstreamCheck.err == nil {
+		latencies := append(upstreamCheck.latencies, upstreamCheck.checkedAt.Sub(start))
+		upstreamCheck.latencies = latencies[max(len(latencies)-probeSamples, 0):]
+	}
+	return upstreamCheck.err
+}
+
+// UpstreamLatency is the latency of the connectivity probes, reported by
+// /health: that of the latest successful one and the average over the last
+// Samples of them, so dashboards can spot a slowing Wikipedia before it
+// fails outright.
+type UpstreamLatency struct {
+	LastMS    float64   `json:"last_ms"`
+	AverageMS float64   `json:"avg_ms"`
+	Samples   int       `json:"samples"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// upstreamLatency summarizes the recorded probe latencies, or returns nil
+// before the first successful probe.
+func upstreamLatency() *UpstreamLatency {
+	upstreamCheck.mu.Lock()
+	defer upstreamCheck.mu.Unlock()
+
+	latencies := upstreamCheck.latencies
+	if len(latencies) == 0 {
+		return nil
+	}
+	var total time.Duration
+	for _, d := range latencies {
+		total += d
+	}
+	return &UpstreamLatency{
+		LastMS:    durationMS(latencies[len(latencies)-1]),
+		AverageMS: durationMS(total / time.Duration(len(latencies))),
+		Samples:   len(latencies),
+		CheckedAt: upstreamCheck.checkedAt,
+	}
+}
+
+// durationMS converts d to fractional milliseconds.
+func durationMS(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// pingWikipedia makes the cheapest API call there is, a siteinfo query,
+// against the default edition, within READY_TIMEOUT. It bypasses go-wiki
+// (and its lock) so a busy server still answers probes promptly.
+func pingWikipedia(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, currentConfig().ReadyTimeout)
+	defer cancel()
+
+	url := wikiAPIURL(defaultLang, defaultProject) + "?action=query&meta=siteinfo&format=json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	res, err := upstreamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return &UpstreamStatusError{StatusCode: res.StatusCode, Status: res.Status}
+	}
+	return nil
+}
+
+// CheckResult is the outcome of one subsystem check: "ok", "failed" with
+// the Error that caused it, "disabled" for a subsystem turned off by
+// configuration, or a circuit breaker state other than closed.
+type CheckResult struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// OK reports whether the check passed or its subsystem is disabled.
+func (c CheckResult) OK() bool {
+	return c.Status == "ok" || c.Status == "disabled"
+}
+
+// checkResult turns a check's error into its result.
+func checkResult(err error) CheckResult {
+	if err != nil {
+		return CheckResult{Status: "failed", Error: err.Error()}
+	}
+	return CheckResult{Status: "ok"}
+}
+
+// checkCache exercises the summary cache with a put/get round-trip.
+func checkCache() CheckResult {
+	if summaryCache == nil {
+		return CheckResult{Status: "disabled"}
+	}
+	return checkResult(summaryCache.Check())
+}
+
+// checkBreaker reports the upstream circuit breaker as ok while it is
+// closed and by its state otherwise.
+func checkBreaker() CheckResult {
+	if currentConfig().BreakerFailures <= 0 {
+		return CheckResult{Status: "disabled"}
+	}
+	if state := upstreamBreaker.Stats().State; state != breakerClosed {
+		return CheckResult{Status: state, Error: "upstream calls are suspended after repeated Wikipedia failures"}
+	}
+	return CheckResult{Status: "ok"}
+}
+
+// ProbeResponse is the JSON body returned by /livez and /readyz. Checks
+// holds the result of each /readyz check and Error explains why it didn't
+// answer "ready".
+type ProbeResponse struct {
+	Status string                 `json:"status"`
+	Error  string                 `json:"error,omitempty"`
+	Checks map[string]CheckResult `json:"checks,omitempty"`
+}
+
+// probeWantsText reports whether a /health or /version request asks for a
+// plain-text answer, for checkers that can't parse JSON: with format=text,
+// or without a format when the Accept header prefers text/plain. On an
+// unknown format it writes a 400 response and returns ok false.
+func probeWantsText(w http.ResponseWriter, r *http.Request) (text, ok bool) {
+	format, ok := enumParam(w, r, "format", "", "json", "text")
+	if !ok {
+		return false, false
+	}
+	if format != "" {
+		return format == "text", true
+	}
+	w.Header().Add("Vary", "Accept")
+	return negotiateContentType(r.Header.Get("Accept"), "application/json", "text/plain") == "text/plain", true
+}
+
+// writeText writes body as a plain-text response with the given status.
+func writeText(w http.ResponseWriter, status int, body string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(status)
+	io.WriteString(w, body)
+}
+
+// livezHandler reports that the process is up. It never touches upstream.
+func livezHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, r, http.StatusOK, ProbeResponse{Status: "ok"})
+}
+
+// readyzHandler reports whether the server can currently reach Wikipedia
+// and its cache works, answering 503 when either fails so load balancers
+// stop routing to it: "unavailable" when Wikipedia is unreachable,
+// "degraded" when only the cache failed. While draining it answers 503
+// "draining" without running the checks.
+func readyzHandler(w http.ResponseWriter, r *http.Request) {
+	if draining.Load() {
+		writeJSON(w, r, http.StatusServiceUnavailable, ProbeResponse{Status: "draining"})
+		return
+	}
+	resp := ProbeResponse{Status: "ready", Checks: map[string]CheckResult{
+		"upstream": checkResult(checkUpstream(r.Context())),
+		"cache":    checkCache(),
+	}}
+	switch upstream, cache := resp.Checks["upstream"], resp.Checks["cache"]; {
+	case !upstream.OK():
+		resp.Status, resp.Error = "unavailable", upstream.Error
+	case !cache.OK():
+		resp.Status, resp.Error = "degraded", cache.Error
+	default:
+		writeJSON(w, r, http.StatusOK, resp)
+		return
+	}
+	writeJSON(w, r, http.StatusServiceUnavailable, resp)
+}
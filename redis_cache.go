@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisKeyPrefix namespaces our keys in a Redis database that may be
+// shared with other applications. The version is bumped whenever the
+// stored format changes, so replicas of different versions don't misread
+// each other's entries.
+const redisKeyPrefix = "wikipedia-agent:summary:v2:"
+
+// redisOpTimeout bounds every Redis command, so a slow Redis delays a
+// lookup by at most this much before it goes to Wikipedia instead.
+const redisOpTimeout = 500 * time.Millisecond
+
+// redisScanTimeout bounds the key scans behind /admin/cache, which walk
+// every key of ours and so take longer than a single command.
+const redisScanTimeout = 10 * time.Second
+
+// redisCache is a summaryStore kept in Redis (CACHE_BACKEND=redis), which
+// survives restarts and is shared by every replica pointed at it. Entries
+// expire through Redis TTLs, kept the stale TTL longer for summaries that
+// can be revalidated. Redis errors are logged and treated as misses, so a
+// Redis outage slows lookups down but doesn't fail them.
+type redisCache struct {
+	client   *redis.Client
+	counters cacheCounters
+
+	mu          sync.Mutex
+	ttl         time.Duration
+	negativeTTL time.Duration // 0 disables negative caching
+	staleTTL    time.Duration // 0 disables revalidation
+}
+
+// redisEntry is the JSON stored under each key. Stored is zero in entries
+// written before it was added. Expires is when the entry stops being
+// served without revalidation; Redis keeps the key until the stale TTL
+// after that.
+type redisEntry struct {
+	Summary  PageSummary `json:"summary,omitzero"`
+	Negative bool        `json:"negative,omitempty"`
+	Stored   time.Time   `json:"stored,omitzero"`
+	Expires  time.Time   `json:"expires,omitzero"`
+}
+
+// expired reports whether entry is past its TTL at now.
+func (e redisEntry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && now.After(e.Expires)
+}
+
+// newRedisCache connects to the Redis server described by opts and checks
+// that it answers within timeout.
+func newRedisCache(opts *redis.Options, timeout, ttl, negativeTTL, staleTTL time.Duration) (*redisCache, error) {
+	client := redis.NewClient(opts)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis at %s unreachable: %w", opts.Addr, err)
+	}
+	return &redisCache{client: client, ttl: ttl, negativeTTL: negativeTTL, staleTTL: staleTTL}, nil
+}
+
+// redisKey returns the Redis key for key.
+func redisKey(key cacheKey) string {
+	return redisKeyPrefix + key.ID()
+}
+
+func (c *redisCache) Get(key cacheKey) (summary PageSummary, negative, ok bool) {
+	entry, ok := c.read(key)
+	if !ok || entry.expired(time.Now()) {
+		c.counters.record(key, false)
+		return PageSummary{}, false, false
+	}
+	c.counters.record(key, true)
+	return entry.Summary, entry.Negative, true
+}
+
+func (c *redisCache) Stale(key cacheKey) (summary PageSummary, ok bool) {
+	entry, ok := c.read(key)
+	now := time.Now()
+	if !ok || entry.Negative || !entry.expired(now) {
+		return PageSummary{}, false
+	}
+	c.mu.Lock()
+	staleTTL := c.staleTTL
+	c.mu.Unlock()
+	// Entries outlive a later, shorter stale TTL in Redis
+	if !now.Before(entry.Expires.Add(staleTTL)) {
+		return PageSummary{}, false
+	}
+	return entry.Summary, true
+}
+
+// read fetches the entry under key, expired or not. ok is false when
+// there is none or it can't be read.
+func (c *redisCache) read(key cacheKey) (entry redisEntry, ok bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisOpTimeout)
+	defer cancel()
+
+	data, err := c.client.Get(ctx, redisKey(key)).Bytes()
+	if err == nil {
+		err = json.Unmarshal(data, &entry)
+	}
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			logger.Warn("redis cache read failed", "key", key.String(), "error", err)
+		}
+		return redisEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *redisCache) Put(key cacheKey, summary PageSummary) {
+	summary.Cached = false
+	c.store(key, redisEntry{Summary: summary})
+}
+
+func (c *redisCache) PutNegative(key cacheKey) {
+	c.store(key, redisEntry{Negative: true})
+}
+
+func (c *redisCache) SetTTL(ttl, negativeTTL, staleTTL time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl, c.negativeTTL, c.staleTTL = ttl, negativeTTL, staleTTL
+}
+
+// store writes entry under key with the TTL for its kind. Summaries whose
+// revision is known are kept the stale TTL longer.
+func (c *redisCache) store(key cacheKey, entry redisEntry) {
+	c.mu.Lock()
+	ttl, keep := c.ttl, time.Duration(0)
+	if entry.Negative {
+		ttl = c.negativeTTL
+	} else if entry.Summary.Revision > 0 {
+		keep = c.staleTTL
+	}
+	c.mu.Unlock()
+	if ttl <= 0 {
+		return
+	}
+
+	entry.Stored = time.Now()
+	entry.Expires = entry.Stored.Add(ttl)
+	data, err := json.Marshal(entry)
+	if err != nil {
+		logger.Warn("redis cache write failed", "key", key.String(), "error", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), redisOpTimeout)
+	defer cancel()
+	if err := c.client.Set(ctx, redisKey(key), data, ttl+keep).Err(); err != nil {
+		logger.Warn("redis cache write failed", "key", key.String(), "error", err)
+	}
+}
+
+// Stats reports the hit/miss counters of this replica, overall and per
+// edition. Size is the number of keys in the Redis database, ours and any
+// others', or -1 when Redis can't be asked.
+func (c *redisCache) Stats() CacheStats {
+	ctx, cancel := context.WithTimeout(context.Background(), redisOpTimeout)
+	defer cancel()
+	size, err := c.client.DBSize(ctx).Result()
+	if err != nil {
+		size = -1
+	}
+	stats := CacheStats{Backend: "redis", Size: int(size)}
+	c.counters.fill(&stats)
+	return stats
+}
+
+// Check stores, reads back and deletes a probe key.
+func (c *redisCache) Check() error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisOpTimeout)
+	defer cancel()
+
+	key := redisKeyPrefix + "probe:" + newRequestID()
+	want := time.Now().Format(time.RFC3339Nano)
+	if err := c.client.Set(ctx, key, want, 10*time.Second).Err(); err != nil {
+		return fmt.Errorf("redis write: %w", err)
+	}
+	defer c.client.Del(ctx, key)
+	got, err := c.client.Get(ctx, key).Result()
+	if err != nil {
+		return fmt.Errorf("redis read: %w", err)
+	}
+	if got != want {
+		return fmt.Errorf("redis probe key read back as %q, want %q", got, want)
+	}
+	return nil
+}
+
+// keys returns every summary key of ours, skipping Check's probe keys.
+func (c *redisCache) keys(ctx context.Context) ([]string, error) {
+	var keys []string
+	iter := c.client.Scan(ctx, 0, redisKeyPrefix+"*", 1000).Iterator()
+	for iter.Next(ctx) {
+		if _, err := parseCacheKeyID(strings.TrimPrefix(iter.Val(), redisKeyPrefix)); err == nil {
+			keys = append(keys, iter.Val())
+		}
+	}
+	return keys, iter.Err()
+}
+
+// Entries lists the summary keys of ours, sorted, with their remaining
+// TTLs. Keys that expire during the scan, or are only kept for
+// revalidation, are left out.
+func (c *redisCache) Entries() ([]CacheEntryInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisScanTimeout)
+	defer cancel()
+	keys, err := c.keys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("redis scan: %w", err)
+	}
+	slices.Sort(keys)
+
+	pipe := c.client.Pipeline()
+	ttls := make([]*redis.DurationCmd, len(keys))
+	values := make([]*redis.StringCmd, len(keys))
+	for i, key := range keys {
+		ttls[i] = pipe.PTTL(ctx, key)
+		values[i] = pipe.Get(ctx, key)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("redis read: %w", err)
+	}
+
+	now := time.Now()
+	entries := make([]CacheEntryInfo, 0, len(keys))
+	for i, key := range keys {
+		var entry redisEntry
+		ttl := ttls[i].Val()
+		if values[i].Err() != nil || ttl <= 0 || json.Unmarshal([]byte(values[i].Val()), &entry) != nil {
+			continue
+		}
+		if !entry.Expires.IsZero() {
+			ttl = entry.Expires.Sub(now)
+		}
+		if ttl <= 0 {
+			continue
+		}
+		info := CacheEntryInfo{Key: strings.TrimPrefix(key, redisKeyPrefix), Negative: entry.Negative, TTLMS: durationMS(ttl)}
+		if !entry.Stored.IsZero() {
+			age := durationMS(now.Sub(entry.Stored))
+			info.AgeMS = &age
+		}
+		entries = append(entries, info)
+	}
+	return entries, nil
+}
+
+// Delete removes the entries for keys and returns how many there were.
+func (c *redisCache) Delete(keys ...cacheKey) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisOpTimeout)
+	defer cancel()
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = redisKey(key)
+	}
+	n, err := c.client.Del(ctx, names...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("redis delete: %w", err)
+	}
+	return int(n), nil
+}
+
+// Purge removes every summary key of ours, leaving other applications'
+// keys in a shared database alone, and returns how many there were.
+func (c *redisCache) Purge() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisScanTimeout)
+	defer cancel()
+	keys, err := c.keys(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("redis scan: %w", err)
+	}
+	deleted := 0
+	for batch := range slices.Chunk(keys, 1000) {
+		n, err := c.client.Unlink(ctx, batch...).Result()
+		deleted += int(n)
+		if err != nil {
+			return deleted, fmt.Errorf("redis delete: %w", err)
+		}
+	}
+	return deleted, nil
+}
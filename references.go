@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+)
+
+// ReferencesResponse is the JSON body returned by /references. Total counts
+// every external link on the page; References holds only the requested
+// window.
+type ReferencesResponse struct {
+	Topic      string   `json:"topic"`
+	Title      string   `json:"title"`
+	Lang       string   `json:"lang"`
+	Total      int      `json:"total"`
+	Offset     int      `json:"offset"`
+	Limit      int      `json:"limit"`
+	References []string `json:"references"`
+}
+
+// referencesHandler returns the external URLs a page links to, paginated
+// like /links. Pages without references yield an empty list.
+func referencesHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	offset, limit, ok := parsePagination(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, references, err := wikiClient.References(r.Context(), topic, lang)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "references lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, ReferencesResponse{
+		Topic:      topic,
+		Title:      title,
+		Lang:       lang,
+		Total:      len(references),
+		Offset:     offset,
+		Limit:      limit,
+		References: paginate(references, offset, limit),
+	})
+}
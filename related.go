@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+)
+
+// Limits for the number of /related results.
+const (
+	defaultRelatedLimit = 10
+	maxRelatedLimit     = 50
+)
+
+// RelatedResponse is the JSON body returned by /related, with Pages ordered
+// from most to least related.
+type RelatedResponse struct {
+	Topic string        `json:"topic"`
+	Title string        `json:"title"`
+	Lang  string        `json:"lang"`
+	Pages []RelatedPage `json:"pages"`
+}
+
+// relatedHandler returns articles related to a page, with their short
+// descriptions, for "you might also like" suggestions. "limit" caps the
+// number of results (default 10, max 50). Pages Wikipedia finds nothing
+// related to yield an empty list.
+func relatedHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	limit, ok := intRangeParam(w, r, "limit", defaultRelatedLimit, 1, maxRelatedLimit)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, pages, err := wikiClient.Related(r.Context(), topic, lang, limit)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "related lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, RelatedResponse{Topic: topic, Title: title, Lang: lang, Pages: pages})
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"unicode/utf8"
+)
+
+// writeJSON encodes v as the JSON response body with the given status,
+// indented when the request asks for ?pretty=true.
+func writeJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	if wantsPretty(r) {
+		enc.SetIndent("", "  ")
+	}
+	enc.Encode(v)
+}
+
+// wantsPretty reports whether the request asks for indented JSON with
+// ?pretty=true, for reading responses in a terminal or browser.
+func wantsPretty(r *http.Request) bool {
+	return r.URL.Query().Get("pretty") == "true"
+}
+
+// marshalJSON encodes v like writeJSON would for r, without the trailing
+// newline.
+func marshalJSON(r *http.Request, v any) ([]byte, error) {
+	if wantsPretty(r) {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	return json.Marshal(v)
+}
+
+// streamSentinel stands in for the field writeJSONStreaming streams while
+// the rest of the response is encoded, marking where the field's value
+// goes.
+const streamSentinel = "\x00stream\x00"
+
+// streamChunkSize is how much escaped text writeJSONStreaming buffers
+// between writes.
+const streamChunkSize = 32 << 10
+
+// writeJSONStreaming is writeJSON for a v holding one large string, such as
+// a whole article, in the field that field points to, which must be v's
+// last string field. json.Encoder builds the entire encoding in memory
+// before writing it, a second copy of the text; this instead encodes the
+// rest of v around the field and escapes the field's text into w in
+// chunks. The escaped length is counted first, so the response also
+// carries its exact Content-Length.
+func writeJSONStreaming(w http.ResponseWriter, r *http.Request, status int, v any, field *string) {
+	text := *field
+	*field = streamSentinel
+	envelope, err := marshalJSON(r, v)
+	*field = text
+	marker, _ := json.Marshal(streamSentinel)
+	i := bytes.LastIndex(envelope, marker)
+	if err != nil || i < 0 {
+		writeJSON(w, r, status, v)
+		return
+	}
+	head, tail := envelope[:i], envelope[i+len(marker):]
+
+	size := len(head) + len(tail) + len(`""`) + len("\n")
+	for j := 0; j < len(text); {
+		c, n := utf8.DecodeRuneInString(text[j:])
+		if esc := jsonEscape(c, n); esc != "" {
+			size += len(esc)
+		} else {
+			size += n
+		}
+		j += n
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Length", strconv.Itoa(size))
+	w.WriteHeader(status)
+
+	buf := append(make([]byte, 0, streamChunkSize+16), head...)
+	buf = append(buf, '"')
+	for j := 0; j < len(text); {
+		c, n := utf8.DecodeRuneInString(text[j:])
+		if esc := jsonEscape(c, n); esc != "" {
+			buf = append(buf, esc...)
+		} else {
+			buf = append(buf, text[j:j+n]...)
+		}
+		j += n
+		if len(buf) >= streamChunkSize {
+			if _, err := w.Write(buf); err != nil {
+				return // the client went away
+			}
+			buf = buf[:0]
+		}
+	}
+	buf = append(buf, '"')
+	buf = append(buf, tail...)
+	w.Write(append(buf, '\n'))
+}
+
+// jsonEscape returns the escape sequence encoding/json writes for the rune
+// c, decoded from n bytes, inside a string, or "" when c is written as is.
+// Like json.Marshal it escapes HTML-sensitive characters and replaces
+// invalid UTF-8 with U+FFFD.
+func jsonEscape(c rune, n int) string {
+	switch c {
+	case '"':
+		return `\"`
+	case '\\':
+		return `\\`
+	case '\b':
+		return `\b`
+	case '\f':
+		return `\f`
+	case '\n':
+		return `\n`
+	case '\r':
+		return `\r`
+	case '\t':
+		return `\t`
+	case '<', '>', '&', '\u2028', '\u2029':
+		return fmt.Sprintf(`\u%04x`, c)
+	case utf8.RuneError:
+		if n == 1 {
+			return `\ufffd`
+		}
+	}
+	if c < 0x20 {
+		return fmt.Sprintf(`\u%04x`, c)
+	}
+	return ""
+}
+
+// ErrorResponse is the JSON body of error responses. Code is a stable,
+// machine-readable identifier such as PAGE_NOT_FOUND; RequestID matches the
+// X-Request-ID header so clients can quote it when reporting problems.
+type ErrorResponse struct {
+	Error     string `json:"error"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+
+	// Set for rejected query parameters and JSON body fields: the parameter
+	// and the values it accepts, as a list or an inclusive range
+	Param   string   `json:"param,omitempty"`
+	Allowed []string `json:"allowed,omitempty"`
+	Min     *float64 `json:"min,omitempty"`
+	Max     *float64 `json:"max,omitempty"`
+}
+
+// writeError writes an ErrorResponse with the given status, code and
+// message, tagged with the request's ID: as JSON, or as an HTML page for
+// browsers that ask for text/html.
+func writeError(w http.ResponseWriter, r *http.Request, status int, code, msg string) {
+	writeErrorResponse(w, r, status, ErrorResponse{Error: msg, Code: code})
+}
+
+// writeErrorResponse writes resp like writeError, for responses carrying
+// more than a code and message.
+func writeErrorResponse(w http.ResponseWriter, r *http.Request, status int, resp ErrorResponse) {
+	resp.RequestID = requestInfoFrom(r.Context()).ID
+	if prefersHTML(r) {
+		writeErrorPage(w, r, status, resp)
+		return
+	}
+	writeJSON(w, r, status, resp)
+}
+
+// writeUpstreamError maps an error from the Wikipedia layer to a JSON error
+// response: 400 for an empty topic, 404 for unknown pages, 429 when the
+// upstream rate limit is exhausted, 503 while the circuit breaker is open,
+// 504 on timeouts, 502 when Wikipedia itself failed, and 500 for anything
+// else. When the client has gone away nothing is written beyond a 499
+// status for the request log. what names the failed operation, e.g. "links
+// lookup". Failures other than bad topics are logged at warn.
+func writeUpstreamError(w http.ResponseWriter, r *http.Request, err error, topic, what string) {
+	if clientGone(r.Context(), err) {
+		logger.Info(what+" cancelled by client", "topic", topic)
+		w.WriteHeader(statusClientClosedRequest)
+		return
+	}
+	logUpstreamError(err, topic, what)
+	var (
+		limited *RateLimitError
+		open    *CircuitOpenError
+	)
+	if errors.As(err, &limited) {
+		w.Header().Set("Retry-After", strconv.Itoa(limited.RetryAfterSeconds()))
+	}
+	if errors.As(err, &open) {
+		w.Header().Set("Retry-After", strconv.Itoa(open.RetryAfterSeconds()))
+	}
+	status, code, msg := classifyUpstreamError(err, topic, what)
+	writeError(w, r, status, code, msg)
+}
+
+// statusClientClosedRequest is the non-standard status, borrowed from nginx,
+// recorded for requests whose client disconnected before the answer was
+// ready. Nobody receives it; it keeps abandoned requests apart from real
+// failures in the request log and metrics.
+const statusClientClosedRequest = 499
+
+// clientGone reports whether err stems from ctx, a request context, being
+// cancelled because the client went away.
+func clientGone(ctx context.Context, err error) bool {
+	return errors.Is(err, context.Canceled) && errors.Is(ctx.Err(), context.Canceled)
+}
+
+// logUpstreamError logs a failed operation: at debug for bad, blocked,
+// unknown or non-article topics, which are the client's doing, and at warn otherwise.
+func logUpstreamError(err error, topic, what string) {
+	var notArticle *NotArticleError
+	if errors.Is(err, ErrTopicRequired) || errors.Is(err, ErrPageNotFound) || errors.Is(err, ErrTopicBlocked) || errors.As(err, &notArticle) {
+		logger.Debug(what+" failed", "topic", topic, "error", err)
+	} else {
+		logger.Warn(what+" failed", "topic", topic, "error", err)
+	}
+}
+
+// classifyUpstreamError returns the status, code and message
+// writeUpstreamError answers err with.
+func classifyUpstreamError(err error, topic, what string) (status int, code, msg string) {
+	var (
+		limited    *RateLimitError
+		open       *CircuitOpenError
+		upstream   *UpstreamError
+		decode     *DecodeError
+		notArticle *NotArticleError
+	)
+	switch {
+	case errors.Is(err, ErrTopicRequired):
+		return http.StatusBadRequest, "TOPIC_REQUIRED", err.Error()
+	case errors.Is(err, ErrTopicBlocked):
+		return http.StatusForbidden, "TOPIC_BLOCKED", fmt.Sprintf("topic %q is not allowed", topic)
+	case errors.Is(err, ErrPageNotFound):
+		return http.StatusNotFound, "PAGE_NOT_FOUND", fmt.Sprintf("no Wikipedia page found for %q", topic)
+	case errors.As(err, &notArticle):
+		return http.StatusUnprocessableEntity, "NOT_AN_ARTICLE", err.Error()
+	case errors.As(err, &limited) && limited.Upstream:
+		return http.StatusTooManyRequests, "RATE_LIMITED", "Wikipedia is rate limiting requests, retry later"
+	case errors.As(err, &limited):
+		return http.StatusTooManyRequests, "RATE_LIMITED", "upstream rate limit exceeded, retry later"
+	case errors.As(err, &open):
+		return http.StatusServiceUnavailable, "UPSTREAM_UNAVAILABLE", fmt.Sprintf("%s skipped: %v", what, err)
+	case isTimeout(err):
+		return http.StatusGatewayTimeout, "UPSTREAM_TIMEOUT", what + " timed out"
+	case errors.As(err, &decode):
+		return http.StatusBadGateway, "UPSTREAM_DECODE_ERROR", fmt.Sprintf("%s failed upstream: Wikipedia returned a response that couldn't be read", what)
+	case errors.As(err, &upstream):
+		return http.StatusBadGateway, "UPSTREAM_ERROR", fmt.Sprintf("%s failed upstream: %v", what, err)
+	default:
+		return http.StatusInternalServerError, "INTERNAL_ERROR", fmt.Sprintf("%s error: %v", what, err)
+	}
+}
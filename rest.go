@@ -0,0 +1,310 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// wikiRESTURL returns the base of the REST API (/api/rest_v1) for the lang
+// edition of the project ctx is for. It sits next to the action API on the
+// same host, so WIKI_API_URL redirects both to a mirror.
+func wikiRESTURL(ctx context.Context, lang string) string {
+	return strings.TrimSuffix(strings.TrimSuffix(wikiAPIURL(lang, projectFrom(ctx)), "/api.php"), "/w") + "/api/rest_v1"
+}
+
+// restSummary is the part of the REST API's /page/summary response we use.
+type restSummary struct {
+	Type   string `json:"type"`
+	Title  string `json:"title"`
+	Titles struct {
+		Normalized string `json:"normalized"`
+	} `json:"titles"`
+	Extract     string `json:"extract"`
+	Description string `json:"description"`
+	Revision    string `json:"revision"` // a number, as a string
+	Thumbnail   *struct {
+		Source string `json:"source"`
+		Width  int    `json:"width"`
+		Height int    `json:"height"`
+	} `json:"thumbnail"`
+	ContentURLs struct {
+		Desktop struct {
+			Page string `json:"page"`
+		} `json:"desktop"`
+		Mobile struct {
+			Page string `json:"page"`
+		} `json:"mobile"`
+	} `json:"content_urls"`
+}
+
+// RESTSummary loads the summary for topic from the REST API's
+// /page/summary endpoint, which returns the page's short description,
+// thumbnail and desktop and mobile URLs along with the extract in one call.
+// It doesn't go through go-wiki, so it doesn't wait for access to it.
+func (goWikiClient) RESTSummary(ctx context.Context, topic, lang string) (result PageSummary, err error) {
+	ctx, cancel := context.WithTimeout(ctx, currentConfig().WikiTimeout)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "wikipedia rest summary", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("wikipedia.lang", lang),
+	))
+	defer func() { endSpan(span, err) }()
+
+	var res restSummary
+	path := "/page/summary/" + url.PathEscape(strings.ReplaceAll(topic, " ", "_"))
+	err = upstreamCall(ctx, func() error {
+		return doRESTRequest(ctx, wikiRESTURL(ctx, lang)+path, &res)
+	})
+	if err != nil {
+		return PageSummary{}, err
+	}
+
+	title := res.Titles.Normalized
+	if title == "" {
+		title = strings.ReplaceAll(res.Title, "_", " ")
+	}
+	// The REST API doesn't list a disambiguation page's candidates
+	if res.Type == "disambiguation" {
+		return PageSummary{}, &DisambiguationError{Title: title, Options: []string{}}
+	}
+	result = PageSummary{
+		Title:       title,
+		Summary:     res.Extract,
+		URL:         res.ContentURLs.Desktop.Page,
+		Description: res.Description,
+		MobileURL:   res.ContentURLs.Mobile.Page,
+	}
+	// Left 0 when absent or malformed, which only rules out revalidation
+	result.Revision, _ = strconv.Atoi(res.Revision)
+	if result.URL == "" {
+		result.URL = projectArticleURL(title, lang, projectFrom(ctx))
+	}
+	if res.Thumbnail != nil {
+		result.Thumbnail = &Image{URL: res.Thumbnail.Source, Width: res.Thumbnail.Width, Height: res.Thumbnail.Height}
+	}
+	if !sameTitle(topic, title) {
+		result.RedirectedFrom = topic
+	}
+	return result, nil
+}
+
+// doRESTRequest performs a single REST API GET of rawURL and decodes the
+// JSON response into out. Redirects to a page's canonical title are
+// followed; a 404 means the page doesn't exist.
+func doRESTRequest(ctx context.Context, rawURL string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "application/json")
+
+	start := time.Now()
+	res, err := upstreamClient.Do(req)
+	elapsed := time.Since(start)
+	upstreamRequestDuration.Observe(elapsed.Seconds())
+	logger.Debug("wikipedia request", "url", rawURL, "latency", elapsed, "error", err)
+	if err != nil {
+		return &UpstreamError{Err: err}
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return fmt.Errorf("%w: %s", ErrPageNotFound, req.URL.Path)
+	default:
+		return &UpstreamError{Err: upstreamStatusError(res)}
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return &UpstreamError{Err: err}
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return decodeError(res, body, err)
+	}
+	return nil
+}
+
+// HistoricalEvent is an entry of Wikipedia's "On this day" feed: what
+// happened, the year (0 for holidays, which recur) and the titles of the
+// articles it links to.
+type HistoricalEvent struct {
+	Year  int      `json:"year,omitempty"`
+	Text  string   `json:"text"`
+	Pages []string `json:"pages"`
+}
+
+// ErrNoFeed is returned for editions that don't publish the "On this day"
+// or featured content feed.
+var ErrNoFeed = errors.New("edition has no such feed")
+
+// OnThisDay returns the "On this day" entries of the given kind
+// ("selected", "events", "births", "deaths" or "holidays") for a date from
+// the REST API's feed, which only some editions publish.
+func (goWikiClient) OnThisDay(ctx context.Context, lang, kind string, month, day int) (events []HistoricalEvent, err error) {
+	ctx, cancel := context.WithTimeout(ctx, currentConfig().WikiTimeout)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "wikipedia rest onthisday", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("wikipedia.lang", lang),
+	))
+	defer func() { endSpan(span, err) }()
+
+	var res map[string][]struct {
+		Text  string `json:"text"`
+		Year  int    `json:"year"`
+		Pages []struct {
+			Title  string `json:"title"`
+			Titles struct {
+				Normalized string `json:"normalized"`
+			} `json:"titles"`
+		} `json:"pages"`
+	}
+	path := fmt.Sprintf("/feed/onthisday/%s/%02d/%02d", kind, month, day)
+	err = upstreamCall(ctx, func() error {
+		return doRESTRequest(ctx, wikiRESTURL(ctx, lang)+path, &res)
+	})
+	if errors.Is(err, ErrPageNotFound) {
+		return nil, fmt.Errorf("%w: %s", ErrNoFeed, lang)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	events = make([]HistoricalEvent, 0, len(res[kind]))
+	for _, e := range res[kind] {
+		event := HistoricalEvent{Year: e.Year, Text: e.Text, Pages: make([]string, 0, len(e.Pages))}
+		for _, p := range e.Pages {
+			event.Pages = append(event.Pages, cmp.Or(p.Titles.Normalized, strings.ReplaceAll(p.Title, "_", " ")))
+		}
+		events = append(events, event)
+	}
+	return events, nil
+}
+
+// FeaturedArticle is the summary of a day's featured article.
+type FeaturedArticle struct {
+	Title       string `json:"title"`
+	Summary     string `json:"summary"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url"`
+	Thumbnail   *Image `json:"thumbnail,omitempty"`
+}
+
+// Featured returns the featured article of date and the titles of the
+// day's most-read articles, most read first, from the REST API's featured
+// content feed. Either is empty when the feed leaves that section out, as
+// it does in editions without featured articles or for days not yet
+// ranked.
+func (goWikiClient) Featured(ctx context.Context, lang string, date time.Time) (article *FeaturedArticle, topRead []string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, currentConfig().WikiTimeout)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "wikipedia rest featured", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("wikipedia.lang", lang),
+	))
+	defer func() { endSpan(span, err) }()
+
+	var res struct {
+		TFA      *restSummary `json:"tfa"`
+		MostRead struct {
+			Articles []struct {
+				Title  string `json:"title"`
+				Titles struct {
+					Normalized string `json:"normalized"`
+				} `json:"titles"`
+			} `json:"articles"`
+		} `json:"mostread"`
+	}
+	path := "/feed/featured/" + date.Format("2006/01/02")
+	err = upstreamCall(ctx, func() error {
+		return doRESTRequest(ctx, wikiRESTURL(ctx, lang)+path, &res)
+	})
+	if errors.Is(err, ErrPageNotFound) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrNoFeed, lang)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if tfa := res.TFA; tfa != nil && tfa.Title != "" {
+		article = &FeaturedArticle{
+			Title:       cmp.Or(tfa.Titles.Normalized, strings.ReplaceAll(tfa.Title, "_", " ")),
+			Summary:     tfa.Extract,
+			Description: tfa.Description,
+			URL:         tfa.ContentURLs.Desktop.Page,
+		}
+		if article.URL == "" {
+			article.URL = articleURL(article.Title, lang)
+		}
+		if tfa.Thumbnail != nil {
+			article.Thumbnail = &Image{URL: tfa.Thumbnail.Source, Width: tfa.Thumbnail.Width, Height: tfa.Thumbnail.Height}
+		}
+	}
+	for _, a := range res.MostRead.Articles {
+		topRead = append(topRead, cmp.Or(a.Titles.Normalized, strings.ReplaceAll(a.Title, "_", " ")))
+	}
+	return article, topRead, nil
+}
+
+// PageviewCount is the number of views an article had in one period of
+// the /pageviews range: a day, or a month starting on Date.
+type PageviewCount struct {
+	Date  string `json:"date"`
+	Views int    `json:"views"`
+}
+
+// Pageviews returns the resolved title of the page for topic and its view
+// counts per day or month ("daily" or "monthly" granularity) from start to
+// end, from the REST API's pageview metrics. Only views by people count,
+// not those by bots and crawlers. A range without recorded views yields an
+// empty list.
+func (c goWikiClient) Pageviews(ctx context.Context, topic, lang, granularity string, start, end time.Time) (title string, views []PageviewCount, err error) {
+	// The metrics are keyed by exact title and don't follow redirects
+	if title, err = c.Resolve(ctx, topic, lang); err != nil {
+		return "", nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, currentConfig().WikiTimeout)
+	defer cancel()
+	ctx, span := tracer.Start(ctx, "wikipedia rest pageviews", trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("wikipedia.lang", lang),
+	))
+	defer func() { endSpan(span, err) }()
+
+	var res struct {
+		Items []struct {
+			Timestamp string `json:"timestamp"` // YYYYMMDDHH
+			Views     int    `json:"views"`
+		} `json:"items"`
+	}
+	path := fmt.Sprintf("/metrics/pageviews/per-article/%s.wikipedia/all-access/user/%s/%s/%s/%s",
+		lang, url.PathEscape(strings.ReplaceAll(title, " ", "_")), granularity, start.Format("20060102"), end.Format("20060102"))
+	err = upstreamCall(ctx, func() error {
+		return doRESTRequest(ctx, wikiRESTURL(ctx, lang)+path, &res)
+	})
+	views = []PageviewCount{}
+	if errors.Is(err, ErrPageNotFound) {
+		return title, views, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	for _, item := range res.Items {
+		day, err := time.Parse("2006010215", item.Timestamp)
+		if err != nil {
+			continue
+		}
+		views = append(views, PageviewCount{Date: day.Format(time.DateOnly), Views: item.Views})
+	}
+	return title, views, nil
+}
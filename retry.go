@@ -0,0 +1,121 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// UpstreamStatusError reports a non-200 response from the Wikipedia API.
+// RetryAfter is set for 429 Too Many Requests, from its Retry-After header.
+type UpstreamStatusError struct {
+	StatusCode int
+	Status     string
+	RetryAfter time.Duration
+}
+
+func (e *UpstreamStatusError) Error() string {
+	return fmt.Sprintf("wikipedia API returned %s", e.Status)
+}
+
+// Bounds on how long a 429 from Wikipedia pauses upstream calls: the
+// pause when it sends no usable Retry-After, and the most a Retry-After
+// is honored for.
+const (
+	defaultUpstreamPause = time.Second
+	maxUpstreamPause     = 5 * time.Minute
+)
+
+// upstreamStatusError builds the error for a non-200 upstream response. A
+// 429 also pauses every upstream call for as long as its Retry-After asks,
+// so one throttled request backs the whole service off.
+func upstreamStatusError(res *http.Response) *UpstreamStatusError {
+	err := &UpstreamStatusError{StatusCode: res.StatusCode, Status: res.Status}
+	if res.StatusCode == http.StatusTooManyRequests {
+		err.RetryAfter = min(cmp.Or(parseRetryAfter(res.Header.Get("Retry-After"), time.Now()), defaultUpstreamPause), maxUpstreamPause)
+		pauseUpstream(err.RetryAfter)
+		logger.Warn("wikipedia is rate limiting us, pausing upstream calls", "retry_after", err.RetryAfter)
+	}
+	return err
+}
+
+// parseRetryAfter reads a Retry-After header, either delay seconds or an
+// HTTP date, as a duration from now. It returns 0 for a missing, invalid
+// or past value.
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(header); err == nil {
+		return max(time.Duration(secs)*time.Second, 0)
+	}
+	if t, err := http.ParseTime(header); err == nil {
+		return max(t.Sub(now), 0)
+	}
+	return 0
+}
+
+// withRetry calls fn until it succeeds, fails with a non-transient error,
+// exhausts WIKI_MAX_RETRIES or ctx ends, backing off exponentially with
+// jitter between attempts. After a 429 it waits for the Retry-After
+// instead, or gives up at once when ctx's deadline comes first. It returns
+// fn's last error.
+func withRetry(ctx context.Context, fn func() error) error {
+	cfg := currentConfig()
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= cfg.MaxRetries || !isTransient(err) {
+			return err
+		}
+		delay := backoff(cfg.RetryBaseDelay, attempt)
+		var status *UpstreamStatusError
+		if errors.As(err, &status) && status.RetryAfter > 0 {
+			if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < status.RetryAfter {
+				return err
+			}
+			delay = status.RetryAfter
+		}
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// backoff returns the delay before retry number attempt+1: base doubled per
+// attempt, jittered to between half and all of that so concurrent callers
+// spread out.
+func backoff(base time.Duration, attempt int) time.Duration {
+	step := base << attempt
+	if step <= 0 {
+		return 0
+	}
+	return step/2 + rand.N(step/2+1)
+}
+
+// isTransient reports whether err is worth retrying: network failures and
+// 429/5xx responses. Deadlines, cancellations, and anything Wikipedia
+// answered definitively (such as a missing page) fail fast.
+func isTransient(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var status *UpstreamStatusError
+	if errors.As(err, &status) {
+		return status.StatusCode == http.StatusTooManyRequests || status.StatusCode >= 500
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, syscall.ECONNRESET)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultSearchLimit = 10
+	maxSearchLimit     = 50
+)
+
+// searchHandler returns a JSON array with the titles of the pages best
+// matching a query, so clients can confirm a title before calling /lookup.
+// GET requests pass the query in the "q" parameter; POST requests send it as
+// the request body. "limit" caps the number of results (default 10, max 50).
+func searchHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Resolve the language edition and result limit
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	limit, ok := intRangeParam(w, r, "limit", defaultSearchLimit, 1, maxSearchLimit)
+	if !ok {
+		return
+	}
+
+	// 2. Read the query from the query string (GET) or the body (POST)
+	query := strings.TrimSpace(r.URL.Query().Get("q"))
+	if r.Method == http.MethodPost {
+		body, err := readBodyText(w, r)
+		if err != nil {
+			writeBodyError(w, r, err)
+			return
+		}
+		query = body
+	}
+	if query == "" {
+		paramError{name: "q", msg: "query is required"}.write(w, r)
+		return
+	}
+	requestInfoFrom(r.Context()).Topic = query
+
+	// 3. Ask Wikipedia for matching titles
+	titles, err := wikiClient.Search(r.Context(), query, lang, limit)
+	if err != nil {
+		writeUpstreamError(w, r, err, query, "search")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, titles)
+}
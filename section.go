@@ -0,0 +1,109 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// SectionResponse is the JSON body returned by /section.
+type SectionResponse struct {
+	Topic   string `json:"topic"`
+	Title   string `json:"title"`
+	Lang    string `json:"lang"`
+	Section string `json:"section"`
+	Text    string `json:"text"`
+}
+
+// sectionHandler returns the text of one section of an article, named by
+// title or anchor in the "title" query parameter, e.g.
+// /section?topic=Python&title=History.
+func sectionHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	section := r.URL.Query().Get("title")
+	if section == "" {
+		writeError(w, r, http.StatusBadRequest, "INVALID_PARAMETER", "section title is required")
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, text, err := wikiClient.Section(r.Context(), topic, lang, section)
+	var missing *SectionNotFoundError
+	if errors.As(err, &missing) {
+		writeError(w, r, http.StatusNotFound, "SECTION_NOT_FOUND", fmt.Sprintf("section %q not found; available sections: %s", section, strings.Join(missing.Available, ", ")))
+		return
+	}
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "section lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, SectionResponse{Topic: topic, Title: title, Lang: lang, Section: section, Text: text})
+}
+
+// SectionTextsResponse is the JSON body returned by /section-texts. Missing
+// lists the requested sections the page doesn't have, in request order,
+// and Available the sections it does have when any are missing.
+type SectionTextsResponse struct {
+	Topic     string            `json:"topic"`
+	Title     string            `json:"title"`
+	Lang      string            `json:"lang"`
+	Sections  map[string]string `json:"sections"`
+	Missing   []string          `json:"missing"`
+	Available []string          `json:"available,omitempty"`
+}
+
+// sectionTextsHandler returns the text of several sections of an article
+// from one fetch of the page, named by title or anchor in the
+// comma-separated "titles" query parameter or in repeated "title"
+// parameters, for titles containing commas. Sections the page lacks are
+// reported in missing rather than failing the request.
+func sectionTextsHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	names := r.URL.Query()["title"]
+	for _, list := range r.URL.Query()["titles"] {
+		names = append(names, strings.Split(list, ",")...)
+	}
+	var sections []string
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" && !slices.Contains(sections, name) {
+			sections = append(sections, name)
+		}
+	}
+	if len(sections) == 0 {
+		paramError{name: "titles", msg: "titles is required, e.g. titles=History,Design"}.write(w, r)
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, texts, available, err := wikiClient.SectionTexts(r.Context(), topic, lang, sections)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "section lookup")
+		return
+	}
+
+	resp := SectionTextsResponse{Topic: topic, Title: title, Lang: lang, Sections: texts, Missing: []string{}}
+	for _, s := range sections {
+		if _, ok := texts[s]; !ok {
+			resp.Missing = append(resp.Missing, s)
+		}
+	}
+	if len(resp.Missing) > 0 {
+		resp.Available = available
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"net/http"
+)
+
+// SectionsResponse is the JSON body returned by /sections.
+type SectionsResponse struct {
+	Topic    string    `json:"topic"`
+	Title    string    `json:"title"`
+	Lang     string    `json:"lang"`
+	Sections []Section `json:"sections"`
+}
+
+// sectionsHandler returns the section headings of an article, in page order
+// and with their nesting level, for building a table of contents.
+func sectionsHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, sections, err := wikiClient.Sections(r.Context(), topic, lang)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "sections lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, SectionsResponse{Topic: topic, Title: title, Lang: lang, Sections: sections})
+}
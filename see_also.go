@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// SeeAlsoResponse is the JSON body returned by /see-also. Section is the
+// heading found, as the page writes it, and empty with no links when the
+// page has no "See also" section.
+type SeeAlsoResponse struct {
+	Topic   string   `json:"topic"`
+	Title   string   `json:"title"`
+	Lang    string   `json:"lang"`
+	Section string   `json:"section"`
+	Links   []string `json:"links"`
+}
+
+// seeAlsoHeadings are the headings each edition gives its "See also"
+// sections, most specific first: where the section also holds external
+// links, as in French, the subsection listing articles comes before it.
+// SEE_ALSO_SECTIONS replaces an edition's list.
+var seeAlsoHeadings = map[string][]string{
+	"ar": {"انظر أيضا", "انظر أيضًا"},
+	"ca": {"Vegeu també"},
+	"cs": {"Související články", "Související"},
+	"da": {"Se også"},
+	"de": {"Siehe auch"},
+	"el": {"Δείτε επίσης"},
+	"en": {"See also"},
+	"es": {"Véase también"},
+	"fa": {"جستارهای وابسته"},
+	"fi": {"Katso myös"},
+	"fr": {"Articles connexes", "Voir aussi"},
+	"he": {"ראו גם"},
+	"hi": {"इन्हें भी देखें"},
+	"hu": {"Kapcsolódó szócikkek", "Lásd még"},
+	"id": {"Lihat pula", "Lihat juga"},
+	"it": {"Voci correlate"},
+	"ja": {"関連項目"},
+	"ko": {"같이 보기"},
+	"nl": {"Zie ook"},
+	"no": {"Se også"},
+	"pl": {"Zobacz też"},
+	"pt": {"Ver também"},
+	"ro": {"Vezi și"},
+	"ru": {"См. также"},
+	"sv": {"Se även"},
+	"tr": {"Ayrıca bakınız"},
+	"uk": {"Див. також"},
+	"vi": {"Xem thêm"},
+	"zh": {"参见", "參見", "参看", "相关条目"},
+}
+
+// parseSeeAlsoSections parses SEE_ALSO_SECTIONS entries of the form
+// lang=Heading into headings by edition, in the order given. A language
+// may be listed more than once to try several headings.
+func parseSeeAlsoSections(entries []string) (map[string][]string, error) {
+	headings := make(map[string][]string)
+	for _, entry := range entries {
+		lang, heading, ok := strings.Cut(entry, "=")
+		lang, heading = strings.TrimSpace(lang), strings.TrimSpace(heading)
+		if !ok || heading == "" {
+			return nil, fmt.Errorf("SEE_ALSO_SECTIONS: %q is not of the form lang=Heading", entry)
+		}
+		if !isSupportedLanguage(lang) {
+			return nil, fmt.Errorf("SEE_ALSO_SECTIONS: unsupported language %q", lang)
+		}
+		headings[lang] = append(headings[lang], heading)
+	}
+	return headings, nil
+}
+
+// seeAlsoHeadingsFor returns the "See also" headings to look for in the
+// lang edition: those SEE_ALSO_SECTIONS gives it, otherwise the built-in
+// ones, falling back to the English heading, which some editions use too.
+func seeAlsoHeadingsFor(lang string) []string {
+	if headings, ok := currentConfig().seeAlsoSections[lang]; ok {
+		return headings
+	}
+	if lang == "en" {
+		return seeAlsoHeadings["en"]
+	}
+	return slices.Concat(seeAlsoHeadings[lang], seeAlsoHeadings["en"])
+}
+
+// seeAlsoHandler returns the articles listed in a page's "See also"
+// section, which editors curate as the most closely related ones.
+func seeAlsoHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, section, links, err := wikiClient.SeeAlso(r.Context(), topic, lang, seeAlsoHeadingsFor(lang))
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "see also lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, SeeAlsoResponse{Topic: topic, Title: title, Lang: lang, Section: section, Links: links})
+}
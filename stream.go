@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// streamHandler fetches a topic's summary and sends it to the client in
+// chunks as Server-Sent Events, flushing after each one. "chunk" selects the
+// granularity: "word" (the default) or "sentence". Each chunk is a "chunk"
+// event whose data is the text including its trailing space, so clients can
+// concatenate them; a final "done" event marks the end. Lookup failures are
+// reported as regular JSON errors before the stream starts.
+func streamHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Resolve the language, chunk granularity and topic
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	chunk, ok := enumParam(w, r, "chunk", "word", "word", "sentence")
+	if !ok {
+		return
+	}
+	split := wordChunks
+	if chunk == "sentence" {
+		split = sentenceChunks
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	// 2. Fetch the summary before committing to an event stream
+	result, err := fetchWikipediaSummary(r.Context(), topic, lang)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "stream lookup")
+		return
+	}
+
+	// 3. Emit the chunks, stopping as soon as the client goes away
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	rc := http.NewResponseController(w)
+	for _, chunk := range split(result.Summary) {
+		if r.Context().Err() != nil {
+			return
+		}
+		writeEvent(w, "chunk", chunk)
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+	writeEvent(w, "done", result.URL)
+	rc.Flush()
+}
+
+// writeEvent writes one Server-Sent Event. Multi-line data is sent as
+// several data lines, which the client rejoins with newlines.
+func writeEvent(w http.ResponseWriter, event, data string) {
+	fmt.Fprintf(w, "event: %s\n", event)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
+
+// wordChunks splits s into words, each but the last followed by a space.
+func wordChunks(s string) []string {
+	words := strings.Fields(s)
+	for i := range len(words) - 1 {
+		words[i] += " "
+	}
+	return words
+}
+
+// sentenceChunks splits s into sentences, each but the last followed by a
+// space.
+func sentenceChunks(s string) []string {
+	var chunks []string
+	start := 0
+	for _, end := range sentenceEnds(s) {
+		if sentence := strings.TrimSpace(s[start:end]); sentence != "" {
+			chunks = append(chunks, sentence+" ")
+		}
+		start = end
+	}
+	if rest := strings.TrimSpace(s[start:]); rest != "" {
+		chunks = append(chunks, rest)
+	} else if n := len(chunks); n > 0 {
+		chunks[n-1] = strings.TrimSuffix(chunks[n-1], " ")
+	}
+	return chunks
+}
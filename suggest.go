@@ -0,0 +1,32 @@
+package main
+
+import "net/http"
+
+// SuggestResponse is the JSON body returned by /suggest. Suggestion is empty
+// when Wikipedia has no correction for the query.
+type SuggestResponse struct {
+	Query      string `json:"query"`
+	Lang       string `json:"lang"`
+	Suggestion string `json:"suggestion"`
+}
+
+// suggestHandler returns Wikipedia's spelling correction for a query, read
+// like /lookup from the "topic" parameter (GET) or the body (POST).
+func suggestHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	query, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	suggestion, err := wikiClient.Suggest(r.Context(), query, lang)
+	if err != nil {
+		writeUpstreamError(w, r, err, query, "suggestion lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, SuggestResponse{Query: query, Lang: lang, Suggestion: suggestion})
+}
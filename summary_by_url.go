@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// summaryByURLHandler returns the summary of the article a Wikipedia URL
+// such as https://en.wikipedia.org/wiki/Go_(programming_language) points
+// to. The edition comes from the URL's subdomain, so there is no "lang"
+// parameter; the response has the same shape as /lookup.
+func summaryByURLHandler(w http.ResponseWriter, r *http.Request) {
+	raw := strings.TrimSpace(r.URL.Query().Get("url"))
+	if raw == "" {
+		writeError(w, r, http.StatusBadRequest, "INVALID_PARAMETER", "url is required, e.g. url=https://en.wikipedia.org/wiki/Go")
+		return
+	}
+	lang, topic, err := parseArticleURL(raw)
+	if err != nil {
+		writeError(w, r, http.StatusBadRequest, "INVALID_PARAMETER", err.Error())
+		return
+	}
+	if !isSupportedLanguage(lang) {
+		writeError(w, r, http.StatusBadRequest, "UNSUPPORTED_LANGUAGE", fmt.Sprintf("unsupported language code %q", lang))
+		return
+	}
+	requestInfoFrom(r.Context()).Topic = topic
+	if !checkTopicAllowed(w, r, topic) {
+		return
+	}
+	recordTopic(topic)
+
+	result, err := fetchWikipediaSummary(r.Context(), topic, lang)
+	var disambig *DisambiguationError
+	if errors.As(err, &disambig) {
+		writeJSON(w, r, http.StatusMultipleChoices, DisambiguationResponse{Topic: topic, Title: disambig.Title, Lang: lang, Options: disambig.Options})
+		return
+	}
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "lookup")
+		return
+	}
+	if strings.TrimSpace(result.Summary) == "" {
+		writeError(w, r, http.StatusNotFound, "NO_SUMMARY", fmt.Sprintf("no summary available for %q", result.Title))
+		return
+	}
+
+	truncated := false
+	if limit := currentConfig().MaxSummaryBytes; limit > 0 {
+		result.Summary, truncated = truncateBytes(result.Summary, limit)
+	}
+	writeCacheableJSON(w, r, LookupResponse{
+		Topic:          topic,
+		Summary:        result.Summary,
+		Lang:           lang,
+		URL:            result.URL,
+		ResolvedTitle:  result.Title,
+		RedirectedFrom: result.RedirectedFrom,
+		Truncated:      truncated,
+	}, "")
+}
+
+// parseArticleURL extracts the edition and the normalized title from an
+// article URL on a language subdomain of wikipedia.org, desktop or mobile
+// (en.m.wikipedia.org). Both /wiki/<title> and /w/index.php?title=<title>
+// are accepted; percent-encoded titles are decoded and any #fragment is
+// ignored.
+func parseArticleURL(raw string) (lang, title string, err error) {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return "", "", fmt.Errorf("url %q is not an http(s) URL", raw)
+	}
+	host := strings.ToLower(u.Hostname())
+	sub, found := strings.CutSuffix(host, ".wikipedia.org")
+	if !found {
+		return "", "", fmt.Errorf("url host %q is not a wikipedia.org domain", u.Hostname())
+	}
+	lang = strings.TrimSuffix(sub, ".m")
+	if lang == "" || lang == "www" || strings.Contains(lang, ".") {
+		return "", "", fmt.Errorf("url host %q names no language edition", u.Hostname())
+	}
+
+	if t, ok := strings.CutPrefix(u.Path, "/wiki/"); ok {
+		title = t
+	} else if u.Path == "/w/index.php" {
+		title = u.Query().Get("title")
+	}
+	if title = normalizeTopic(title); title == "" {
+		return "", "", fmt.Errorf("url %q doesn't point to an article", raw)
+	}
+	return lang, title, nil
+}
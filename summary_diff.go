@@ -0,0 +1,246 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// SummaryDiffResponse is the JSON body returned by /summary-diff. Diff goes
+// word by word from From's summary to To's, and Unified is the same change
+// as a unified diff of their paragraphs, empty when nothing changed.
+type SummaryDiffResponse struct {
+	Topic   string          `json:"topic"`
+	Title   string          `json:"title"`
+	Lang    string          `json:"lang"`
+	From    RevisionSummary `json:"from"`
+	To      RevisionSummary `json:"to"`
+	Changed bool            `json:"changed"`
+	Diff    []DiffOp        `json:"diff"`
+	Unified string          `json:"unified"`
+}
+
+// RevisionSummary is the lead paragraphs of a page as of one revision, one
+// per line.
+type RevisionSummary struct {
+	ID        int       `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	Summary   string    `json:"summary"`
+}
+
+// DiffOp is one run of a word diff. Op is "equal", "delete" or "insert";
+// concatenating the equal and delete runs gives the old text, the equal and
+// insert runs the new one.
+type DiffOp struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+// summaryDiffHandler compares the lead section of a page in the revisions
+// named by the "from" and "to" query parameters, as listed by /history.
+func summaryDiffHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	from, ok := positiveIntParam(w, r, "from")
+	if !ok {
+		return
+	}
+	to, ok := positiveIntParam(w, r, "to")
+	if !ok {
+		return
+	}
+	for _, p := range []struct {
+		name string
+		id   int
+	}{{"from", from}, {"to", to}} {
+		if p.id == 0 {
+			paramError{name: p.name, msg: "from and to are required, as revision IDs such as those listed by /history"}.write(w, r)
+			return
+		}
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, leads, err := wikiClient.RevisionLeads(r.Context(), topic, lang, from, to)
+	var (
+		missing  *RevisionNotFoundError
+		mismatch *RevisionMismatchError
+	)
+	switch {
+	case errors.As(err, &missing):
+		writeError(w, r, http.StatusNotFound, "REVISION_NOT_FOUND", fmt.Sprintf("%v of %q", err, title))
+		return
+	case errors.As(err, &mismatch):
+		name := "to"
+		if mismatch.ID == from {
+			name = "from"
+		}
+		paramError{code: "REVISION_MISMATCH", name: name, msg: err.Error()}.write(w, r)
+		return
+	case err != nil:
+		writeUpstreamError(w, r, err, topic, "summary diff")
+		return
+	}
+
+	resp := SummaryDiffResponse{Topic: topic, Title: title, Lang: lang}
+	resp.From = RevisionSummary{ID: leads[0].ID, Timestamp: leads[0].Timestamp, Summary: leadProse(leads[0].Wikitext)}
+	resp.To = RevisionSummary{ID: leads[1].ID, Timestamp: leads[1].Timestamp, Summary: leadProse(leads[1].Wikitext)}
+	resp.Changed = resp.From.Summary != resp.To.Summary
+	resp.Diff = wordDiff(resp.From.Summary, resp.To.Summary)
+	if resp.Changed {
+		resp.Unified, _ = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(resp.From.Summary + "\n"),
+			B:        difflib.SplitLines(resp.To.Summary + "\n"),
+			FromFile: fmt.Sprintf("revision %d", from),
+			ToFile:   fmt.Sprintf("revision %d", to),
+			Context:  1,
+		})
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+var (
+	// magicWordPattern matches behaviour switches such as __NOTOC__
+	magicWordPattern = regexp.MustCompile(`__[A-Z]+__`)
+	// paragraphBreak matches the blank lines between paragraphs
+	paragraphBreak = regexp.MustCompile(`\n\s*\n`)
+)
+
+// leadProse reduces the wikitext of a lead section to its paragraphs as
+// plain text, one per line. Templates standing on lines of their own, such
+// as infoboxes, hatnotes and short descriptions, are dropped with tables
+// and images; the paragraphs are then reduced like infobox values, so
+// inline templates, references and link markup don't show.
+func leadProse(wikitext string) string {
+	s := stripComments(wikitext)
+	s = magicWordPattern.ReplaceAllString(s, "")
+	s = dropNested(s, "{{", "}}", true)
+	s = dropNested(s, "{|", "|}", true)
+	s = dropFiles(s)
+
+	var paragraphs []string
+	for _, block := range paragraphBreak.Split(s, -1) {
+		if text := wikitextToPlain(block); text != "" {
+			paragraphs = append(paragraphs, text)
+		}
+	}
+	return strings.Join(paragraphs, "\n")
+}
+
+// dropNested removes from s every span from open to its matching close,
+// counting spans nested in it. With standalone set only spans that start a
+// line and end one are removed, leaving those within text alone.
+func dropNested(s, open, close string, standalone bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if strings.HasPrefix(s[i:], open) && (!standalone || i == 0 || s[i-1] == '\n') {
+			if end := matchingClose(s, i, open, close); end >= 0 {
+				rest, _, _ := strings.Cut(s[end:], "\n")
+				if !standalone || strings.TrimSpace(rest) == "" {
+					i = end
+					continue
+				}
+			}
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String()
+}
+
+// dropFiles removes the image links from s, captions included, even when
+// the caption holds links of its own.
+func dropFiles(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if isFileLink(s[i:]) {
+			if end := matchingClose(s, i, "[[", "]]"); end >= 0 {
+				i = end
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+		i++
+	}
+	return b.String()
+}
+
+// isFileLink reports whether s starts with a link embedding an image.
+func isFileLink(s string) bool {
+	for _, prefix := range []string{"[[file:", "[[image:"} {
+		if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchingClose returns the offset just past the close matching the open
+// at s[start:], or -1 when it is never closed.
+func matchingClose(s string, start int, open, close string) int {
+	depth := 0
+	for i := start; i < len(s); {
+		switch {
+		case strings.HasPrefix(s[i:], open):
+			depth++
+			i += len(open)
+		case strings.HasPrefix(s[i:], close):
+			depth--
+			i += len(close)
+			if depth == 0 {
+				return i
+			}
+		default:
+			i++
+		}
+	}
+	return -1
+}
+
+// diffTokenPattern splits text into words, each with the whitespace after
+// it, so the runs of a diff join back into the original text.
+var diffTokenPattern = regexp.MustCompile(`\s*\S+\s*`)
+
+// wordDiff returns the runs of words that stay, go and come between a and
+// b. Runs of each kind are merged, and a replacement is a delete followed
+// by an insert.
+func wordDiff(a, b string) []DiffOp {
+	aw, bw := diffTokenPattern.FindAllString(a, -1), diffTokenPattern.FindAllString(b, -1)
+	// Without autojunk, words frequent in long leads still match
+	m := difflib.NewMatcherWithJunk(aw, bw, false, nil)
+	ops := []DiffOp{}
+	add := func(op string, words []string) {
+		if len(words) == 0 {
+			return
+		}
+		text := strings.Join(words, "")
+		if n := len(ops); n > 0 && ops[n-1].Op == op {
+			ops[n-1].Text += text
+			return
+		}
+		ops = append(ops, DiffOp{Op: op, Text: text})
+	}
+	for _, c := range m.GetOpCodes() {
+		switch c.Tag {
+		case 'e':
+			add("equal", aw[c.I1:c.I2])
+		case 'd':
+			add("delete", aw[c.I1:c.I2])
+		case 'i':
+			add("insert", bw[c.J1:c.J2])
+		case 'r':
+			add("delete", aw[c.I1:c.I2])
+			add("insert", bw[c.J1:c.J2])
+		}
+	}
+	return ops
+}
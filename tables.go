@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// TablesResponse is the JSON body returned by /tables. Total counts every
+// data table on the page; Tables holds all of them, or only the one picked
+// by index.
+type TablesResponse struct {
+	Topic  string      `json:"topic"`
+	Title  string      `json:"title"`
+	Lang   string      `json:"lang"`
+	Total  int         `json:"total"`
+	Tables []WikiTable `json:"tables"`
+}
+
+// WikiTable is one table of a page. Columns lists the row keys in column
+// order, since the row objects themselves are unordered.
+type WikiTable struct {
+	Index   int                 `json:"index"`
+	Caption string              `json:"caption,omitempty"`
+	Columns []string            `json:"columns"`
+	Rows    []map[string]string `json:"rows"`
+}
+
+// tablesHandler returns the data tables of a page as arrays of row objects
+// keyed by column header, or only the table selected by the "index" query
+// parameter. Pages without tables yield an empty list.
+func tablesHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	index, ok := intRangeParam(w, r, "index", -1, 0, math.MaxInt)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, wikitext, err := wikiClient.Wikitext(r.Context(), topic, lang, "")
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "tables lookup")
+		return
+	}
+
+	tables := parseTables(wikitext)
+	total := len(tables)
+	if index >= 0 {
+		if index >= total {
+			writeError(w, r, http.StatusNotFound, "TABLE_NOT_FOUND", fmt.Sprintf("table %d not found; %q has %d tables", index, title, total))
+			return
+		}
+		tables = tables[index : index+1]
+	}
+	writeJSON(w, r, http.StatusOK, TablesResponse{Topic: topic, Title: title, Lang: lang, Total: total, Tables: tables})
+}
+
+// Caps on cell spans. MediaWiki clamps colspan to 1000 as well; rowspans
+// only ever reach the rows the table actually has.
+const (
+	maxColspan = 1000
+	maxRowspan = 65534
+)
+
+var spanPattern = regexp.MustCompile(`(?i)\b(rowspan|colspan)\s*=\s*["']?\s*(\d+)`)
+
+// tableCell is a cell as written in the wikitext, before spans are
+// expanded.
+type tableCell struct {
+	text             string
+	header           bool
+	rowspan, colspan int
+}
+
+// tableBuilder collects the rows of one table while its lines are read.
+type tableBuilder struct {
+	start     int // order in which the table was opened
+	wikitable bool
+	caption   string
+	rows      [][]*tableCell
+	last      *string // caption or cell that continuation lines extend
+}
+
+// parseTables extracts the tables with class "wikitable" from wikitext, the
+// class Wikipedia uses for data tables, leaving out layout tables and
+// navigation boxes. Cell values are reduced to plain text like infobox
+// values. Rowspans and colspans are expanded by repeating the cell's value
+// in every row and column it covers. The column names come from the leading
+// rows made up only of header cells; stacked header rows are joined with
+// " / ", and columns without a header are named "column N". A nested table
+// is returned as a table of its own and left out of the enclosing cell.
+// Tables generated by templates aren't visible in the wikitext and so are
+// never found.
+func parseTables(wikitext string) []WikiTable {
+	wikitext = refPattern.ReplaceAllString(stripComments(wikitext), "")
+
+	var (
+		stack    []*tableBuilder
+		finished []*tableBuilder
+		opened   int
+	)
+	for _, line := range strings.Split(wikitext, "\n") {
+		trimmed := strings.TrimLeft(line, " \t")
+		if attrs, ok := strings.CutPrefix(trimmed, "{|"); ok {
+			stack = append(stack, &tableBuilder{start: opened, wikitable: strings.Contains(strings.ToLower(attrs), "wikitable")})
+			opened++
+			continue
+		}
+		if len(stack) == 0 {
+			continue
+		}
+		t := stack[len(stack)-1]
+		switch {
+		case strings.HasPrefix(trimmed, "|}"):
+			stack = stack[:len(stack)-1]
+			finished = append(finished, t)
+		case strings.HasPrefix(trimmed, "|+"):
+			_, t.caption = cutCellAttrs(trimmed[2:])
+			t.last = &t.caption
+		case strings.HasPrefix(trimmed, "|-"):
+			t.rows = append(t.rows, nil)
+			t.last = nil
+		case strings.HasPrefix(trimmed, "!"):
+			t.addCells(splitCells(trimmed[1:], "!!", "||"), true)
+		case strings.HasPrefix(trimmed, "|"):
+			t.addCells(splitCells(trimmed[1:], "||"), false)
+		case t.last != nil:
+			*t.last += "\n" + line
+		}
+	}
+	// Tables never closed end with the wikitext
+	finished = append(finished, stack...)
+
+	slices.SortFunc(finished, func(a, b *tableBuilder) int { return a.start - b.start })
+	tables := []WikiTable{}
+	for _, t := range finished {
+		if t.wikitable {
+			tables = append(tables, t.build(len(tables)))
+		}
+	}
+	return tables
+}
+
+// addCells appends the cells of one wikitext line to the current row,
+// starting a row if the table has none yet.
+func (t *tableBuilder) addCells(cells []string, header bool) {
+	if len(t.rows) == 0 {
+		t.rows = append(t.rows, nil)
+	}
+	row := &t.rows[len(t.rows)-1]
+	for _, c := range cells {
+		attrs, text := cutCellAttrs(c)
+		cell := &tableCell{text: text, header: header, rowspan: 1, colspan: 1}
+		for _, m := range spanPattern.FindAllStringSubmatch(attrs, -1) {
+			n, _ := strconv.Atoi(m[2])
+			if strings.EqualFold(m[1], "rowspan") {
+				cell.rowspan = min(max(n, 1), maxRowspan)
+			} else {
+				cell.colspan = min(max(n, 1), maxColspan)
+			}
+		}
+		*row = append(*row, cell)
+		t.last = &cell.text
+	}
+}
+
+// build expands the table's spans into a grid and turns it into a
+// WikiTable with the given index.
+func (t *tableBuilder) build(index int) WikiTable {
+	type carry struct {
+		text string
+		left int
+	}
+	var (
+		grid    [][]string
+		headers int // leading grid rows made up only of header cells
+		inHead  = true
+		carries = make(map[int]*carry)
+		width   int
+	)
+	for _, row := range t.rows {
+		if len(row) == 0 {
+			continue
+		}
+		var out []string
+		// fill copies cells spanning down from earlier rows into out
+		fill := func() {
+			for c, ok := carries[len(out)]; ok; c, ok = carries[len(out)] {
+				out = append(out, c.text)
+				if c.left--; c.left == 0 {
+					delete(carries, len(out)-1)
+				}
+			}
+		}
+		allHeaders := true
+		for _, cell := range row {
+			fill()
+			text := wikitextToPlain(cell.text)
+			for range cell.colspan {
+				if cell.rowspan > 1 {
+					carries[len(out)] = &carry{text: text, left: cell.rowspan - 1}
+				}
+				out = append(out, text)
+			}
+			allHeaders = allHeaders && cell.header
+		}
+		// Spans from above may continue past the row's last cell
+		last := -1
+		for col := range carries {
+			last = max(last, col)
+		}
+		for len(out) <= last {
+			if _, ok := carries[len(out)]; ok {
+				fill()
+			} else {
+				out = append(out, "")
+			}
+		}
+		if inHead = inHead && allHeaders; inHead {
+			headers++
+		}
+		grid = append(grid, out)
+		width = max(width, len(out))
+	}
+
+	columns := make([]string, width)
+	seen := make(map[string]int)
+	for col := range columns {
+		var names []string
+		for _, row := range grid[:headers] {
+			if col < len(row) && row[col] != "" && !slices.Contains(names, row[col]) {
+				names = append(names, row[col])
+			}
+		}
+		name := strings.Join(names, " / ")
+		if name == "" {
+			name = fmt.Sprintf("column %d", col+1)
+		}
+		if seen[name]++; seen[name] > 1 {
+			name = fmt.Sprintf("%s (%d)", name, seen[name])
+		}
+		columns[col] = name
+	}
+
+	rows := []map[string]string{}
+	for _, row := range grid[headers:] {
+		obj := make(map[string]string, width)
+		for col, name := range columns {
+			if col < len(row) {
+				obj[name] = row[col]
+			} else {
+				obj[name] = ""
+			}
+		}
+		rows = append(rows, obj)
+	}
+	return WikiTable{Index: index, Caption: wikitextToPlain(t.caption), Columns: columns, Rows: rows}
+}
+
+// splitCells splits a table line on any of the separators, skipping those
+// nested inside a template or a wikilink.
+func splitCells(line string, seps ...string) []string {
+	var (
+		cells []string
+		depth int
+		last  int
+	)
+	for i := 0; i < len(line); i++ {
+		switch {
+		case strings.HasPrefix(line[i:], "{{"), strings.HasPrefix(line[i:], "[["):
+			depth++
+			i++
+		case strings.HasPrefix(line[i:], "}}"), strings.HasPrefix(line[i:], "]]"):
+			depth = max(depth-1, 0)
+			i++
+		case depth == 0 && slices.ContainsFunc(seps, func(sep string) bool { return strings.HasPrefix(line[i:], sep) }):
+			cells = append(cells, line[last:i])
+			i++
+			last = i + 1
+		}
+	}
+	return append(cells, line[last:])
+}
+
+// cutCellAttrs splits a cell into its attributes and its content at the
+// first "|" outside templates and wikilinks, as in `style="..." | text`.
+// Cells without attributes return them empty.
+func cutCellAttrs(cell string) (attrs, content string) {
+	depth := 0
+	for i := 0; i < len(cell); i++ {
+		switch {
+		case strings.HasPrefix(cell[i:], "{{"), strings.HasPrefix(cell[i:], "[["):
+			depth++
+			i++
+		case strings.HasPrefix(cell[i:], "}}"), strings.HasPrefix(cell[i:], "]]"):
+			depth = max(depth-1, 0)
+			i++
+		case cell[i] == '|' && depth == 0:
+			return cell[:i], cell[i+1:]
+		}
+	}
+	return "", cell
+}
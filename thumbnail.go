@@ -0,0 +1,52 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// defaultThumbnailWidth is the /thumbnail width when none is requested.
+const defaultThumbnailWidth = 320
+
+// maxThumbnailWidth caps the requested width; larger images are better
+// fetched from the original URL.
+const maxThumbnailWidth = 4000
+
+// ThumbnailResponse is the JSON body returned by /thumbnail.
+type ThumbnailResponse struct {
+	Topic     string `json:"topic"`
+	Title     string `json:"title"`
+	Lang      string `json:"lang"`
+	Original  Image  `json:"original"`
+	Thumbnail Image  `json:"thumbnail"`
+}
+
+// thumbnailHandler returns a page's lead image scaled to the "width" query
+// parameter, alongside the original. Pages without a lead image yield a 404.
+func thumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	width, ok := intRangeParam(w, r, "width", defaultThumbnailWidth, 1, maxThumbnailWidth)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	title, original, thumb, err := wikiClient.Thumbnail(r.Context(), topic, lang, width)
+	if errors.Is(err, ErrNoImage) {
+		writeError(w, r, http.StatusNotFound, "NO_IMAGE", fmt.Sprintf("%q has no lead image", title))
+		return
+	}
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "thumbnail lookup")
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, ThumbnailResponse{Topic: topic, Title: title, Lang: lang, Original: original, Thumbnail: thumb})
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// topicFilter restricts which topics may be looked up. A topic is allowed
+// when it matches no block pattern and, if allow patterns are configured, at
+// least one of those. A nil filter allows everything.
+type topicFilter struct {
+	allow []*regexp.Regexp
+	block []*regexp.Regexp
+}
+
+// newTopicFilter compiles the allow and block patterns, returning nil when
+// both lists are empty.
+func newTopicFilter(allow, block []string) (*topicFilter, error) {
+	if len(allow) == 0 && len(block) == 0 {
+		return nil, nil
+	}
+	f := &topicFilter{}
+	var err error
+	if f.allow, err = compileTopicPatterns(allow); err != nil {
+		return nil, err
+	}
+	if f.block, err = compileTopicPatterns(block); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// compileTopicPatterns turns topic patterns into case-insensitive regular
+// expressions. A pattern wrapped in slashes, like /^dinosaur/, is a regular
+// expression matched anywhere in the topic; any other is a glob that must
+// match the whole topic, where * matches any run of characters and ? a
+// single one.
+func compileTopicPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, p := range patterns {
+		expr := ""
+		if len(p) >= 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/") {
+			expr = p[1 : len(p)-1]
+		} else {
+			expr = "^" + globToRegexp(normalizeTopic(p)) + "$"
+		}
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid topic pattern %q: %w", p, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// globToRegexp translates the * and ? wildcards of a glob, quoting
+// everything else.
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return b.String()
+}
+
+// Allowed reports whether topic, in normalized form, may be looked up.
+func (f *topicFilter) Allowed(topic string) bool {
+	if f == nil {
+		return true
+	}
+	for _, re := range f.block {
+		if re.MatchString(topic) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, re := range f.allow {
+		if re.MatchString(topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// topicAllowed reports whether the live topic filter allows topic.
+func topicAllowed(topic string) bool {
+	return currentConfig().topics.Allowed(topic)
+}
+
+// checkTopicAllowed writes a 403 TOPIC_BLOCKED error and returns false when
+// the topic filter rejects topic.
+func checkTopicAllowed(w http.ResponseWriter, r *http.Request, topic string) bool {
+	if topicAllowed(topic) {
+		return true
+	}
+	writeUpstreamError(w, r, ErrTopicBlocked, topic, "lookup")
+	return false
+}
+
+// topicPatterns returns the comma-separated patterns in the environment
+// variable key followed by those in the file named by key+"_FILE", one per
+// line; blank lines and lines starting with # are skipped.
+func topicPatterns(key string) ([]string, error) {
+	patterns := envList(key)
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return patterns, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		if line := strings.TrimSpace(sc.Text()); line != "" && !strings.HasPrefix(line, "#") {
+			patterns = append(patterns, line)
+		}
+	}
+	return patterns, sc.Err()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"cmp"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// topicStats counts requested topics for /stats. It is nil when
+// STATS_TOP_N=0.
+var topicStats *topicCounter
+
+// topicCounter is a concurrency-safe frequency counter over a bounded set of
+// topics, using the Space-Saving algorithm: when the set is full, a new topic
+// takes over the least counted entry and inherits its count. Counts of
+// frequent topics are therefore exact or slightly overestimated, never
+// underestimated, and memory stays bounded however many distinct topics
+// arrive.
+type topicCounter struct {
+	mu       sync.Mutex
+	capacity int
+	counts   map[string]uint64
+	total    uint64
+	since    time.Time
+}
+
+// newTopicCounter returns a counter tracking at most capacity topics.
+func newTopicCounter(capacity int) *topicCounter {
+	return &topicCounter{capacity: capacity, counts: make(map[string]uint64, capacity), since: time.Now()}
+}
+
+// Add counts one request for topic.
+func (c *topicCounter) Add(topic string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total++
+	if _, ok := c.counts[topic]; ok || len(c.counts) < c.capacity {
+		c.counts[topic]++
+		return
+	}
+	minTopic, minCount := "", uint64(0)
+	for t, n := range c.counts {
+		if minTopic == "" || n < minCount {
+			minTopic, minCount = t, n
+		}
+	}
+	delete(c.counts, minTopic)
+	c.counts[topic] = minCount + 1
+}
+
+// TopicCount is one entry of the /stats ranking.
+type TopicCount struct {
+	Topic string `json:"topic"`
+	Count uint64 `json:"count"`
+}
+
+// Top returns the n most counted topics, highest first, ties broken
+// alphabetically.
+func (c *topicCounter) Top(n int) []TopicCount {
+	c.mu.Lock()
+	top := make([]TopicCount, 0, len(c.counts))
+	for t, count := range c.counts {
+		top = append(top, TopicCount{Topic: t, Count: count})
+	}
+	c.mu.Unlock()
+
+	slices.SortFunc(top, func(a, b TopicCount) int {
+		if a.Count != b.Count {
+			return cmp.Compare(b.Count, a.Count)
+		}
+		return cmp.Compare(a.Topic, b.Topic)
+	})
+	return top[:min(n, len(top))]
+}
+
+// Reset clears all counts and restarts the counting period.
+func (c *topicCounter) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.counts)
+	c.total = 0
+	c.since = time.Now()
+}
+
+// snapshot returns the total and the start of the counting period.
+func (c *topicCounter) snapshot() (total uint64, since time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total, c.since
+}
+
+// statsTopN is how many topics /stats lists (STATS_TOP_N).
+var statsTopN = 10
+
+// StatsResponse is the JSON body returned by /stats. Total counts every
+// topic request since Since, including topics no longer tracked.
+type StatsResponse struct {
+	Since  time.Time    `json:"since"`
+	Total  uint64       `json:"total"`
+	Topics []TopicCount `json:"topics"`
+}
+
+// recordTopic counts a request for topic, if topic stats are enabled.
+func recordTopic(topic string) {
+	if topicStats != nil {
+		topicStats.Add(topic)
+	}
+}
+
+// statsHandler returns the most-requested topics since startup or the last
+// reset. The optional "limit" query parameter lists fewer than STATS_TOP_N.
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	limit, ok := intRangeParam(w, r, "limit", statsTopN, 1, max(statsTopN, 1))
+	if !ok {
+		return
+	}
+
+	resp := StatsResponse{Topics: []TopicCount{}}
+	if topicStats != nil {
+		resp.Total, resp.Since = topicStats.snapshot()
+		resp.Topics = topicStats.Top(limit)
+	}
+	writeJSON(w, r, http.StatusOK, resp)
+}
+
+// statsResetHandler clears the topic counts and answers 204. Like
+// /admin/cache it needs API_KEYS to be set, so that only holders of a key
+// can wipe the counts.
+func statsResetHandler(w http.ResponseWriter, r *http.Request) {
+	if len(currentConfig().APIKeys) == 0 {
+		writeError(w, r, http.StatusUnauthorized, "UNAUTHORIZED", "/stats/reset requires API_KEYS to be set")
+		return
+	}
+	if topicStats != nil {
+		topicStats.Reset()
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans for upstream Wikipedia calls. It goes through the
+// global provider, so it records nothing until setupTracing installs one.
+var tracer = otel.Tracer("github.com/ruslanmv/wikipedia-agent")
+
+// tracingEnabled reports whether an OTLP endpoint is configured through the
+// standard OTEL_ variables and the SDK isn't disabled. Without one, spans
+// would only be exported to localhost and dropped.
+func tracingEnabled() bool {
+	if strings.EqualFold(os.Getenv("OTEL_SDK_DISABLED"), "true") || os.Getenv("OTEL_TRACES_EXPORTER") == "none" {
+		return false
+	}
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// setupTracing installs the W3C trace-context propagator and, when
+// tracingEnabled, a tracer provider that batches spans to the OTLP/HTTP
+// endpoint. The exporter, sampler and resource read the usual OTEL_
+// variables (endpoint, headers, OTEL_TRACES_SAMPLER, OTEL_SERVICE_NAME, ...).
+// The returned function flushes pending spans and must be called on exit.
+func setupTracing(ctx context.Context) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if !tracingEnabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(
+			attribute.String("service.name", "wikipedia-agent"),
+			attribute.String("service.version", appVersion),
+		),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// traceHandler starts a server span for every request to pattern, continuing
+// the trace from the incoming traceparent header when there is one.
+func traceHandler(pattern string, h http.Handler) http.Handler {
+	return otelhttp.NewHandler(h, pattern, otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+		return r.Method + " " + pattern
+	}))
+}
+
+// traceTransport wraps the upstream transport so every HTTP call to
+// Wikipedia gets a client span. Trace headers aren't forwarded: Wikipedia
+// isn't part of our traces.
+func traceTransport(rt http.RoundTripper) http.RoundTripper {
+	return otelhttp.NewTransport(rt, otelhttp.WithPropagators(propagation.NewCompositeTextMapPropagator()))
+}
+
+// setSpanAttributes adds attributes to the request's server span.
+func setSpanAttributes(r *http.Request, attrs ...attribute.KeyValue) {
+	trace.SpanFromContext(r.Context()).SetAttributes(attrs...)
+}
+
+// endSpan records err, if any, on span and ends it.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
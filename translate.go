@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// TranslateTitleResponse is the JSON body returned by /translate-title.
+// Title is the page's title in the From edition; TranslatedTitle and URL
+// point at its counterpart in the Lang edition.
+type TranslateTitleResponse struct {
+	Topic           string `json:"topic"`
+	Title           string `json:"title"`
+	From            string `json:"from"`
+	Lang            string `json:"lang"`
+	TranslatedTitle string `json:"translated_title"`
+	URL             string `json:"url"`
+}
+
+// translateTitleHandler returns the title of a page in another language
+// edition, for deep links. The topic is resolved in the "from" edition
+// (default DEFAULT_LANG) and "lang" names the required target. Only the
+// interlanguage links are fetched, not the summary; a page with no
+// counterpart in the target edition yields a 404.
+func translateTitleHandler(w http.ResponseWriter, r *http.Request) {
+	// 1. Resolve the source and target editions and the topic
+	from := r.URL.Query().Get("from")
+	if from == "" {
+		from = defaultLang
+	}
+	target := r.URL.Query().Get("lang")
+	if target == "" {
+		paramError{name: "lang", msg: "lang is required, e.g. lang=de"}.write(w, r)
+		return
+	}
+	for _, p := range []struct{ name, lang string }{{"from", from}, {"lang", target}} {
+		if !isSupportedLanguage(p.lang) {
+			paramError{code: "UNSUPPORTED_LANGUAGE", name: p.name, msg: fmt.Sprintf("unsupported language code %q", p.lang)}.write(w, r)
+			return
+		}
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	// 2. Look the target edition up in the page's interlanguage links
+	title, links, err := wikiClient.LangLinks(r.Context(), topic, from)
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "language links lookup")
+		return
+	}
+	links[from] = title
+	translated, ok := links[target]
+	if !ok {
+		writeError(w, r, http.StatusNotFound, "NO_TRANSLATION", fmt.Sprintf("%q has no %s counterpart", title, target))
+		return
+	}
+
+	writeJSON(w, r, http.StatusOK, TranslateTitleResponse{
+		Topic:           topic,
+		Title:           title,
+		From:            from,
+		Lang:            target,
+		TranslatedTitle: translated,
+		URL:             articleURL(translated, target),
+	})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// limitContent applies MAX_CONTENT_BYTES to full article content. Longer
+// content is cut like a summary, reporting truncated, or, unless truncate
+// is set, answered with 413 and code CONTENT_TOO_LARGE, returning ok false.
+func limitContent(w http.ResponseWriter, r *http.Request, s string, truncate bool) (limited string, truncated, ok bool) {
+	limit := currentConfig().MaxContentBytes
+	if limit <= 0 || len(s) <= limit {
+		return s, false, true
+	}
+	if !truncate {
+		writeError(w, r, http.StatusRequestEntityTooLarge, "CONTENT_TOO_LARGE", fmt.Sprintf("content is %d bytes, over the %d byte limit", len(s), limit))
+		return "", false, false
+	}
+	limited, truncated = truncateBytes(s, limit)
+	return limited, truncated, true
+}
+
+// truncateChars cuts s to at most n characters, marking the cut with an
+// ellipsis. It reports whether s was shortened.
+func truncateChars(s string, n int) (string, bool) {
+	if utf8.RuneCountInString(s) <= n {
+		return s, false
+	}
+	return string([]rune(s)[:n]) + "…", true
+}
+
+// truncateBytes cuts s to at most n bytes including the ellipsis marking
+// the cut, breaking at the last word boundary that fits (or mid-word for a
+// single overlong word). A limit too small for the ellipsis gets the
+// whole characters that fit, unmarked. It reports whether s was shortened.
+func truncateBytes(s string, n int) (string, bool) {
+	const ellipsis = "…"
+	if len(s) <= n {
+		return s, false
+	}
+	if n < len(ellipsis) {
+		return s[:runeStartBefore(s, n)], true
+	}
+	cut := runeStartBefore(s, n-len(ellipsis))
+	if i := strings.LastIndexFunc(s[:cut], unicode.IsSpace); i > 0 {
+		cut = i
+	}
+	return strings.TrimRightFunc(s[:cut], unicode.IsSpace) + ellipsis, true
+}
+
+// runeStartBefore returns the largest offset no greater than i at which a
+// character of s starts, so that s[:offset] is valid UTF-8.
+func runeStartBefore(s string, i int) int {
+	for i > 0 && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return i
+}
+
+// truncateSentences keeps the first n sentences of s.
+func truncateSentences(s string, n int) string {
+	ends := sentenceEnds(s)
+	if n > len(ends) {
+		return s
+	}
+	return strings.TrimSpace(s[:ends[n-1]])
+}
+
+// sentenceEnds returns the byte offset just past each sentence in s. A
+// sentence ends at '.', '!' or '?', optionally followed by closing quotes or
+// brackets, and then whitespace and a word that doesn't start in lower case
+// or with a digit. A period after a title such as "Dr." or an initialism
+// such as "U.S." or "F." never ends one.
+func sentenceEnds(s string) []int {
+	var ends []int
+	for i, r := range s {
+		if r != '.' && r != '!' && r != '?' {
+			continue
+		}
+		end := i + utf8.RuneLen(r)
+		for end < len(s) && strings.ContainsRune(sentenceClosers, rune(s[end])) {
+			end++
+		}
+		next, _ := utf8.DecodeRuneInString(s[end:])
+		if end < len(s) && !unicode.IsSpace(next) {
+			continue
+		}
+		following, _ := utf8.DecodeRuneInString(strings.TrimLeftFunc(s[end:], unicode.IsSpace))
+		if unicode.IsLower(following) || unicode.IsDigit(following) {
+			continue
+		}
+		if r == '.' && isAbbreviation(s[:i]) {
+			continue
+		}
+		ends = append(ends, end)
+	}
+	return ends
+}
+
+// sentenceClosers may follow the punctuation that ends a sentence.
+const sentenceClosers = "\"')]"
+
+// sentenceTitles are abbreviations, lower-cased and without their period,
+// that precede a name and so never end a sentence.
+var sentenceTitles = map[string]bool{
+	"mr": true, "mrs": true, "ms": true, "dr": true, "prof": true, "st": true, "mt": true,
+	"gen": true, "col": true, "lt": true, "sgt": true, "rev": true, "fr": true, "sen": true,
+	"gov": true, "pres": true, "no": true, "vol": true, "fig": true,
+}
+
+// isAbbreviation reports whether the word at the end of s, just before a
+// period, is one of sentenceTitles or an initialism made of single letters
+// separated by periods.
+func isAbbreviation(s string) bool {
+	word := s[strings.LastIndexFunc(s, func(r rune) bool { return !unicode.IsLetter(r) && r != '.' })+1:]
+	if sentenceTitles[strings.ToLower(word)] {
+		return true
+	}
+	for _, part := range strings.Split(word, ".") {
+		if utf8.RuneCountInString(part) != 1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	wiki "github.com/trietmn/go-wiki"
+	"github.com/trietmn/go-wiki/models"
+	"github.com/trietmn/go-wiki/utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RateLimitError is returned instead of calling Wikipedia when the upstream
+// limiter (WIKI_RATE_LIMIT requests per second, WIKI_RATE_BURST burst) has
+// no token available, or, with Upstream set, when Wikipedia itself answered
+// 429 Too Many Requests and upstream calls are paused.
+type RateLimitError struct {
+	RetryAfter time.Duration
+	Upstream   bool
+}
+
+func (e *RateLimitError) Error() string {
+	if e.Upstream {
+		return fmt.Sprintf("wikipedia is rate limiting requests, retry in %s", e.RetryAfter)
+	}
+	return fmt.Sprintf("upstream rate limit exceeded, retry in %s", e.RetryAfter)
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds for the
+// Retry-After header.
+func (e *RateLimitError) RetryAfterSeconds() int {
+	return int(math.Ceil(e.RetryAfter.Seconds()))
+}
+
+// upstreamPause holds back every upstream call until a time a 429 from
+// Wikipedia named in its Retry-After.
+var upstreamPause struct {
+	mu    sync.Mutex
+	until time.Time
+}
+
+// pauseUpstream holds back upstream calls for d from now. An earlier pause
+// that lasts longer is kept.
+func pauseUpstream(d time.Duration) {
+	upstreamPause.mu.Lock()
+	defer upstreamPause.mu.Unlock()
+	if until := time.Now().Add(d); until.After(upstreamPause.until) {
+		upstreamPause.until = until
+	}
+}
+
+// takeUpstreamToken claims a token from the upstream limiter without
+// waiting, returning a *RateLimitError when none is available or while a
+// 429 from Wikipedia has upstream calls paused.
+func takeUpstreamToken() error {
+	upstreamPause.mu.Lock()
+	wait := time.Until(upstreamPause.until)
+	upstreamPause.mu.Unlock()
+	if wait > 0 {
+		return &RateLimitError{RetryAfter: wait, Upstream: true}
+	}
+	limiter := currentConfig().limiter
+	if limiter == nil {
+		return nil
+	}
+	res := limiter.Reserve()
+	if delay := res.Delay(); delay > 0 {
+		res.Cancel()
+		return &RateLimitError{RetryAfter: delay}
+	}
+	return nil
+}
+
+// userAgent identifies us in every upstream request (WIKI_USER_AGENT).
+// Wikipedia's API policy asks for a descriptive agent with contact details;
+// generic ones like go-wiki's default may be throttled or blocked.
+var userAgent = defaultUserAgent()
+
+// defaultUserAgent names the application, its version and where to find it.
+func defaultUserAgent() string {
+	return fmt.Sprintf("wikipedia-agent/%s (https://github.com/ruslanmv/wikipedia-agent)", appVersion)
+}
+
+// apiURLTemplate is the MediaWiki action API endpoint (WIKI_API_URL), with
+// {lang} standing for the edition's language code and {project} for the
+// Wikimedia project. Pointing it at a mirror or a mock server needs no
+// go-wiki support: every API call, go-wiki's included, is made by
+// doWikiRequest.
+var apiURLTemplate = "https://{lang}.{project}.org/w/api.php"
+
+// wikiAPIURL returns the API endpoint for the lang edition of project.
+func wikiAPIURL(lang, project string) string {
+	return strings.NewReplacer("{lang}", lang, "{project}", project).Replace(apiURLTemplate)
+}
+
+// parseAPIURLTemplate validates a WIKI_API_URL value: an absolute http(s)
+// URL, optionally containing {lang} and {project}. Without them every
+// edition, or every project, is served by the same endpoint.
+func parseAPIURLTemplate(raw string) (string, error) {
+	u, err := url.Parse(strings.NewReplacer("{lang}", "en", "{project}", defaultProject).Replace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+		return "", fmt.Errorf("%q is not an absolute http(s) URL without a query string", raw)
+	}
+	return raw, nil
+}
+
+// Connection pool settings for upstreamTransport (WIKI_MAX_IDLE_CONNS,
+// WIKI_MAX_IDLE_CONNS_PER_HOST, WIKI_IDLE_CONN_TIMEOUT). Every call goes to
+// the same host, so the per-host limit matters most; the default of 2 in
+// net/http causes constant reconnects under concurrent /batch traffic.
+var (
+	maxIdleConns        = 100
+	maxIdleConnsPerHost = 32
+	idleConnTimeout     = 90 * time.Second
+)
+
+// upstreamTransport is the shared connection pool for Wikipedia API calls.
+// main applies the pool settings to it before serving.
+var upstreamTransport = http.DefaultTransport.(*http.Transport).Clone()
+
+// upstreamClient performs every HTTP request to the Wikipedia API. Deadlines
+// come from the per-call context rather than a client-wide timeout. go-wiki
+// doesn't accept an http.Client, but all of its calls are routed through
+// requestWikiAPI and so share this client's pool, its tracing and its
+// per-host concurrency limit.
+var upstreamClient = &http.Client{Transport: traceTransport(limitHosts(upstreamTransport))}
+
+// configureTransport applies the connection pool settings to
+// upstreamTransport.
+func configureTransport() {
+	upstreamTransport.MaxIdleConns = maxIdleConns
+	upstreamTransport.MaxIdleConnsPerHost = maxIdleConnsPerHost
+	upstreamTransport.IdleConnTimeout = idleConnTimeout
+}
+
+// go-wiki keeps the active language, its response cache and the function it
+// uses for HTTP calls in package-level state. wikiSem grants one caller at a
+// time exclusive use of that state; wikiLang and wikiCtx describe the holder.
+var (
+	wikiSem  = make(chan struct{}, 1)
+	wikiLang = utils.WikiLanguage
+	wikiCtx  = context.Background()
+)
+
+func init() {
+	// Route go-wiki's API calls through our client so they honor wikiCtx.
+	utils.WikiRequester = requestWikiAPI
+}
+
+// withWiki runs fn with exclusive access to go-wiki, pointed at the given
+// language edition. The upstream calls fn makes are bounded by ctx and by
+// WIKI_TIMEOUT, including the time spent waiting for access.
+func withWiki(ctx context.Context, lang string, fn func() error) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, currentConfig().WikiTimeout)
+	defer cancel()
+
+	select {
+	case wikiSem <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() {
+		wikiCtx = context.Background()
+		<-wikiSem
+	}()
+	defer recoverGoWikiPanic(&err)
+
+	wikiCtx = ctx
+	if lang != wikiLang {
+		wiki.SetLanguage(lang)
+		wikiLang = lang
+	}
+	return fn()
+}
+
+// recoverGoWikiPanic turns a panic raised by go-wiki into an
+// *UpstreamError wrapping a *DecodeError in *err. go-wiki indexes into
+// responses without checking their shape, so one it doesn't expect makes
+// it panic. Other panics are re-raised. It must be deferred directly.
+func recoverGoWikiPanic(err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if !panickedInGoWiki() {
+		panic(v)
+	}
+	logger.Debug("go-wiki panicked reading a wikipedia response", "panic", v, "stack", string(debug.Stack()))
+	*err = &UpstreamError{Err: &DecodeError{Err: fmt.Errorf("go-wiki: %v", v)}}
+}
+
+// panickedInGoWiki reports whether the panic being recovered was raised
+// in go-wiki's code rather than ours. Callers must be the function
+// recoverGoWikiPanic defers.
+func panickedInGoWiki() bool {
+	pcs := make([]uintptr, 32)
+	// Skip runtime.Callers, this function and recoverGoWikiPanic
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			return strings.HasPrefix(frame.Function, "github.com/trietmn/go-wiki")
+		}
+		if !more {
+			return false
+		}
+	}
+}
+
+// requestWikiAPI replaces go-wiki's default requester: it issues the API
+// call with upstreamClient under wikiCtx and decodes the JSON result.
+func requestWikiAPI(args map[string]string) (models.RequestResult, error) {
+	var result models.RequestResult
+	if err := callWikiAPI(args, &result); err != nil {
+		return models.RequestResult{}, err
+	}
+	return result, nil
+}
+
+// callWikiAPI calls the MediaWiki action API of the current edition with
+// args under wikiCtx and decodes the JSON response into out, retrying
+// transient failures. It is also used directly for the queries whose fields
+// go-wiki's models don't capture; callers must be inside withWiki. Each
+// call is traced as one span, with the HTTP attempts as its children.
+func callWikiAPI(args map[string]string, out any) (err error) {
+	action := args["action"]
+	if action == "" {
+		action = "query"
+	}
+	ctx, span := tracer.Start(wikiCtx, "wikipedia "+action, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("wikipedia.lang", utils.WikiLanguage),
+		attribute.String("wikipedia.project", projectFrom(wikiCtx)),
+		attribute.String("wikipedia.action", action),
+	))
+	defer func() { endSpan(span, err) }()
+
+	return upstreamCall(ctx, func() error {
+		return doWikiRequest(ctx, wikiAPIURL(utils.WikiLanguage, projectFrom(ctx)), args, out)
+	})
+}
+
+// ErrDeadline is the cause of a context cut short by a deadline the client
+// asked for, such as /lookup's deadline parameter, rather than WIKI_TIMEOUT.
+var ErrDeadline = errors.New("client deadline exceeded")
+
+// upstreamCall makes one logical call to Wikipedia: unless the circuit
+// breaker is open, it runs attempt, a single HTTP request, with retries and
+// an upstream limiter token per try, and reports the outcome to the
+// breaker. Calls cut short by the client's own deadline report no verdict,
+// so tight client deadlines can't open the breaker.
+func upstreamCall(ctx context.Context, attempt func() error) error {
+	if err := upstreamBreaker.Allow(); err != nil {
+		return err
+	}
+	err := withRetry(ctx, func() error {
+		if err := takeUpstreamToken(); err != nil {
+			return err
+		}
+		return attempt()
+	})
+	// A 429 that outlasted the retries is passed on to the client as one,
+	// with the Retry-After Wikipedia gave
+	var status *UpstreamStatusError
+	if errors.As(err, &status) && status.RetryAfter > 0 {
+		err = &RateLimitError{RetryAfter: status.RetryAfter, Upstream: true}
+	}
+	if err != nil && errors.Is(context.Cause(ctx), ErrDeadline) {
+		upstreamBreaker.Done(context.Canceled)
+		return err
+	}
+	upstreamBreaker.Done(err)
+	return err
+}
+
+// doWikiRequest performs a single call to the MediaWiki action API at
+// endpoint for callWikiAPI and callWikidataAPI.
+func doWikiRequest(ctx context.Context, endpoint string, args map[string]string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	q := req.URL.Query()
+	q.Set("format", "json")
+	q.Set("action", "query")
+	for k, v := range args {
+		q.Set(k, v)
+	}
+	req.URL.RawQuery = q.Encode()
+
+	start := time.Now()
+	res, err := upstreamClient.Do(req)
+	elapsed := time.Since(start)
+	upstreamRequestDuration.Observe(elapsed.Seconds())
+	logger.Debug("wikipedia request", "url", req.URL.String(), "latency", elapsed, "error", err)
+	if err != nil {
+		return &UpstreamError{Err: err}
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return &UpstreamError{Err: upstreamStatusError(res)}
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return &UpstreamError{Err: err}
+	}
+
+	// The API reports failures as a 200 with an "error" object
+	var apiErr struct {
+		Error models.RequestError `json:"error"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		return decodeError(res, body, err)
+	}
+	if apiErr.Error.Code != "" {
+		return wikiAPIError(apiErr.Error)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return decodeError(res, body, err)
+	}
+	return nil
+}
+
+// maxLoggedBody caps how much of an undecodable response is logged.
+const maxLoggedBody = 512
+
+// DecodeError is an upstream response that isn't the JSON we expect, such
+// as an HTML error page from a proxy, a truncated body or a changed format.
+// It is wrapped in an *UpstreamError.
+type DecodeError struct {
+	ContentType string
+	Err         error
+}
+
+func (e *DecodeError) Error() string {
+	if e.ContentType == "" {
+		return "undecodable response: " + e.Err.Error()
+	}
+	return fmt.Sprintf("undecodable %s response: %v", e.ContentType, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error { return e.Err }
+
+// decodeError reports that body, the response to res, couldn't be decoded
+// with err, logging the start of it at debug level for diagnosis.
+func decodeError(res *http.Response, body []byte, err error) error {
+	contentType := res.Header.Get("Content-Type")
+	logger.Debug("undecodable wikipedia response", "url", res.Request.URL.String(), "content_type", contentType,
+		"body", strings.ToValidUTF8(string(body[:min(len(body), maxLoggedBody)]), "\uFFFD"), "error", err)
+	return &UpstreamError{Err: &DecodeError{ContentType: contentType, Err: err}}
+}
+
+// UpstreamError wraps a failure talking to the Wikipedia API: a network
+// error, an unexpected status, an unreadable response or an API error.
+type UpstreamError struct {
+	Err error
+}
+
+func (e *UpstreamError) Error() string { return e.Err.Error() }
+func (e *UpstreamError) Unwrap() error { return e.Err }
+
+// wikiAPIError converts an error object from the API into ErrPageNotFound
+// for unknown pages and an *UpstreamError otherwise.
+func wikiAPIError(e models.RequestError) error {
+	switch e.Code {
+	case "missingtitle", "nosuchpageid", "invalidtitle":
+		return fmt.Errorf("%w: %s", ErrPageNotFound, e.Info)
+	}
+	return &UpstreamError{Err: fmt.Errorf("wikipedia API error %s: %s", e.Code, e.Info)}
+}
+
+// isTimeout reports whether err stems from an upstream deadline.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"cmp"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// apiVersions lists the API versions served, oldest first. The last one is
+// what unversioned paths and requests without a versioned Accept type get.
+// Until a v2 changes a response shape, every version is served by the same
+// handlers; a handler that needs to tell them apart reads
+// requestInfo.APIVersion.
+var apiVersions = []string{"v1"}
+
+var (
+	versionPathPattern   = regexp.MustCompile(`^/(v[0-9]+)(/|$)`)
+	versionAcceptPattern = regexp.MustCompile(`^application/vnd\.wikipedia-agent\.(v[0-9]+)\+json$`)
+)
+
+// withAPIVersion picks the API version of a request from a /vN path prefix
+// or, failing that, from an application/vnd.wikipedia-agent.vN+json media
+// type in Accept, and strips the prefix so the unversioned routes serve
+// it. The version is recorded in the request's requestInfo and echoed in
+// the API-Version response header. Unknown versions get 404 for a path and
+// 406 for Accept, as does an Accept type that contradicts the path.
+func withAPIVersion(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		version := apiVersions[len(apiVersions)-1]
+		fromPath := false
+		if m := versionPathPattern.FindStringSubmatch(r.URL.Path); m != nil {
+			if !slices.Contains(apiVersions, m[1]) {
+				writeError(w, r, http.StatusNotFound, "UNSUPPORTED_API_VERSION", fmt.Sprintf("API version %s is not supported; supported versions: %s", m[1], strings.Join(apiVersions, ", ")))
+				return
+			}
+			version, fromPath = m[1], true
+			r = stripVersionPrefix(r, "/"+m[1])
+		}
+
+		if accepted := acceptedAPIVersions(r.Header.Get("Accept")); len(accepted) > 0 {
+			switch {
+			case fromPath && !slices.Contains(accepted, version):
+				writeError(w, r, http.StatusNotAcceptable, "UNSUPPORTED_API_VERSION", fmt.Sprintf("Accept asks for API version %s but the path is for %s", strings.Join(accepted, ", "), version))
+				return
+			case !fromPath:
+				i := slices.IndexFunc(accepted, func(v string) bool { return slices.Contains(apiVersions, v) })
+				if i < 0 {
+					writeError(w, r, http.StatusNotAcceptable, "UNSUPPORTED_API_VERSION", fmt.Sprintf("API version %s is not supported; supported versions: %s", strings.Join(accepted, ", "), strings.Join(apiVersions, ", ")))
+					return
+				}
+				version = accepted[i]
+			}
+		}
+
+		requestInfoFrom(r.Context()).APIVersion = version
+		w.Header().Set("API-Version", version)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acceptedAPIVersions returns the versions named by versioned media types
+// in an Accept header, most preferred first. Types with q=0 are left out.
+func acceptedAPIVersions(accept string) []string {
+	type weighted struct {
+		version string
+		q       float64
+	}
+	var found []weighted
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		m := versionAcceptPattern.FindStringSubmatch(mediaType)
+		if m == nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			found = append(found, weighted{m[1], q})
+		}
+	}
+	// A stable sort keeps the listed order among equal q values
+	slices.SortStableFunc(found, func(a, b weighted) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	versions := make([]string, len(found))
+	for i, f := range found {
+		versions[i] = f.version
+	}
+	return versions
+}
+
+// stripVersionPrefix returns a shallow copy of r with prefix removed from
+// its path, like http.StripPrefix. "/v1" alone becomes "/".
+func stripVersionPrefix(r *http.Request, prefix string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = cmp.Or(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	if r.URL.RawPath != "" {
+		r2.URL.RawPath = cmp.Or(strings.TrimPrefix(r.URL.RawPath, prefix), "/")
+	}
+	return r2
+}
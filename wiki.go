@@ -0,0 +1,1374 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	wiki "github.com/trietmn/go-wiki"
+	"github.com/trietmn/go-wiki/page"
+)
+
+// defaultLang is the Wikipedia edition used when a request doesn't ask for
+// one (DEFAULT_LANG).
+var defaultLang = "en"
+
+// supportedLanguages maps the ISO 639-1 codes we accept to the name of the
+// corresponding Wikipedia edition. go-wiki selects the edition by its
+// subdomain prefix, which for these editions is the ISO code itself.
+var supportedLanguages = map[string]string{
+	"ar": "Arabic",
+	"ca": "Catalan",
+	"cs": "Czech",
+	"da": "Danish",
+	"de": "German",
+	"el": "Greek",
+	"en": "English",
+	"es": "Spanish",
+	"fa": "Persian",
+	"fi": "Finnish",
+	"fr": "French",
+	"he": "Hebrew",
+	"hi": "Hindi",
+	"hu": "Hungarian",
+	"id": "Indonesian",
+	"it": "Italian",
+	"ja": "Japanese",
+	"ko": "Korean",
+	"nl": "Dutch",
+	"no": "Norwegian",
+	"pl": "Polish",
+	"pt": "Portuguese",
+	"ro": "Romanian",
+	"ru": "Russian",
+	"sv": "Swedish",
+	"tr": "Turkish",
+	"uk": "Ukrainian",
+	"vi": "Vietnamese",
+	"zh": "Chinese",
+}
+
+// isSupportedLanguage reports whether lang is a Wikipedia edition we can query.
+func isSupportedLanguage(lang string) bool {
+	_, ok := supportedLanguages[lang]
+	return ok
+}
+
+// ErrPageNotFound is returned when no Wikipedia page matches a topic.
+var ErrPageNotFound = errors.New("page not found")
+
+// ErrTopicRequired is returned for an empty topic, which go-wiki would
+// otherwise send upstream and fail on with a confusing error.
+var ErrTopicRequired = errors.New("topic is required")
+
+// ErrTopicBlocked is returned for pages the TOPIC_ALLOWLIST and
+// TOPIC_BLOCKLIST settings rule out.
+var ErrTopicBlocked = errors.New("topic is not allowed")
+
+// SectionNotFoundError is returned when a page has no section with the
+// requested title. Available lists the section titles the page does have.
+type SectionNotFoundError struct {
+	Section   string
+	Available []string
+}
+
+func (e *SectionNotFoundError) Error() string {
+	return fmt.Sprintf("section %q not found", e.Section)
+}
+
+// RevisionNotFoundError is returned when revision IDs don't exist, or were
+// deleted.
+type RevisionNotFoundError struct {
+	IDs []int
+}
+
+func (e *RevisionNotFoundError) Error() string {
+	ids := make([]string, len(e.IDs))
+	for i, id := range e.IDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	return fmt.Sprintf("no revision %s", strings.Join(ids, " or "))
+}
+
+// RevisionMismatchError is returned when a revision belongs to another page
+// than the one asked for, named by Title.
+type RevisionMismatchError struct {
+	ID    int
+	Page  string
+	Title string
+}
+
+func (e *RevisionMismatchError) Error() string {
+	return fmt.Sprintf("revision %d belongs to %q, not %q", e.ID, e.Title, e.Page)
+}
+
+// DisambiguationError is returned when a topic resolves to a disambiguation
+// page. Options lists the titles of the candidate pages it links to.
+type DisambiguationError struct {
+	Title   string
+	Options []string
+}
+
+func (e *DisambiguationError) Error() string {
+	return fmt.Sprintf("%q may refer to %d pages", e.Title, len(e.Options))
+}
+
+// PageSummary is the result of a summary lookup.
+type PageSummary struct {
+	Title   string // resolved page title
+	Summary string // the lead extract
+	URL     string // canonical article URL
+	Cached  bool   // served from summaryCache
+
+	// RedirectedFrom is the requested topic when it resolved to a page with
+	// a different title, through a redirect or go-wiki's search fallback.
+	RedirectedFrom string
+
+	// Only summaries from the REST API carry these
+	Description string // short description, e.g. "Programming language"
+	Thumbnail   *Image // lead image thumbnail, nil for pages without one
+	MobileURL   string // mobile article URL
+
+	// Revision is the ID of the revision the summary was taken from, 0 when
+	// unknown. It lets expired cache entries be revalidated cheaply.
+	Revision int
+}
+
+// fetchWikipediaSummary returns the first paragraph (the “extract”) for a topic
+// from the Wikipedia edition identified by lang, consulting summaryCache
+// before going upstream. Missing pages are cached too, so repeated lookups
+// of a typo don't reach Wikipedia. Ambiguous topics yield a
+// *DisambiguationError, and topics outside the article namespace a
+// *NotArticleError without a request.
+func fetchWikipediaSummary(ctx context.Context, topic, lang string) (PageSummary, error) {
+	if err := checkArticleTopic(topic); err != nil {
+		return PageSummary{}, err
+	}
+	return allowResolved(cachedSummary(ctx, cacheKey{topic: topic, lang: lang, project: projectFrom(ctx)}, func(ctx context.Context) (PageSummary, error) {
+		return wikiClient.Summary(ctx, topic, lang)
+	}))
+}
+
+// fetchRESTSummary is fetchWikipediaSummary through the REST API's
+// /page/summary endpoint. Its summaries are cached apart from go-wiki's,
+// since they carry more fields.
+func fetchRESTSummary(ctx context.Context, topic, lang string) (PageSummary, error) {
+	if err := checkArticleTopic(topic); err != nil {
+		return PageSummary{}, err
+	}
+	return allowResolved(cachedSummary(ctx, cacheKey{topic: topic, lang: lang, project: projectFrom(ctx), rest: true}, func(ctx context.Context) (PageSummary, error) {
+		return wikiClient.RESTSummary(ctx, topic, lang)
+	}))
+}
+
+// fetchSummaryByID is fetchWikipediaSummary for a page identified by its
+// numeric page ID rather than its title.
+func fetchSummaryByID(ctx context.Context, id int, lang string) (PageSummary, error) {
+	return allowResolved(cachedSummary(ctx, cacheKey{pageID: id, lang: lang, project: projectFrom(ctx)}, func(ctx context.Context) (PageSummary, error) {
+		return wikiClient.SummaryByID(ctx, id, lang)
+	}))
+}
+
+// allowResolved rejects a summary whose resolved title the topic filter
+// blocks, so redirects, search matches and page IDs can't reach a blocked
+// page.
+func allowResolved(summary PageSummary, err error) (PageSummary, error) {
+	if err == nil && !topicAllowed(summary.Title) {
+		return PageSummary{}, fmt.Errorf("%w: %q", ErrTopicBlocked, summary.Title)
+	}
+	return summary, err
+}
+
+type noCacheKey struct{}
+
+// withNoCache returns a copy of ctx under which cachedSummary skips the
+// cache read and always fetches, still storing what it fetched, for
+// clients that know the cached copy is stale.
+func withNoCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noCacheKey{}, true)
+}
+
+// cachedSummary returns the summary cached under key, calling fetch and
+// caching its result, or its ErrPageNotFound, on a miss or when ctx comes
+// from withNoCache. Concurrent misses for the same key share one fetch
+// through sharedFetch. Nothing is cached once the context fetch runs under
+// is done: the fetch may have been cut short.
+func cachedSummary(ctx context.Context, key cacheKey, fetch func(context.Context) (PageSummary, error)) (PageSummary, error) {
+	if summaryCache != nil && ctx.Value(noCacheKey{}) == nil {
+		if summary, negative, ok := summaryCache.Get(key); ok {
+			if negative {
+				return PageSummary{}, fmt.Errorf("%w: %s", ErrPageNotFound, key)
+			}
+			summary.Cached = true
+			return summary, nil
+		}
+	}
+
+	return sharedFetch(ctx, key, func(ctx context.Context) (PageSummary, error) {
+		if summary, ok := revalidate(ctx, key); ok {
+			return summary, nil
+		}
+		summary, err := fetch(ctx)
+		if summaryCache == nil || ctx.Err() != nil {
+			return summary, err
+		}
+		if errors.Is(err, ErrPageNotFound) {
+			summaryCache.PutNegative(key)
+		}
+		if err != nil {
+			return PageSummary{}, err
+		}
+		summaryCache.Put(key, summary)
+		return summary, nil
+	})
+}
+
+// revalidate returns the expired summary cached under key, marked cached,
+// when the page it came from is still at the same revision, and stores it
+// afresh. That costs one cheap query instead of a full fetch. Requests
+// from withNoCache always refetch.
+func revalidate(ctx context.Context, key cacheKey) (PageSummary, bool) {
+	if summaryCache == nil || ctx.Value(noCacheKey{}) != nil {
+		return PageSummary{}, false
+	}
+	stale, ok := summaryCache.Stale(key)
+	if !ok {
+		return PageSummary{}, false
+	}
+	title, revision, err := wikiClient.LatestRevision(ctx, stale.Title, key.lang)
+	switch {
+	case err != nil:
+		// Full fetches retry with their own error handling
+		cacheRevalidationsTotal.WithLabelValues("failed").Inc()
+		return PageSummary{}, false
+	case title != stale.Title || revision != stale.Revision:
+		cacheRevalidationsTotal.WithLabelValues("changed").Inc()
+		return PageSummary{}, false
+	}
+	cacheRevalidationsTotal.WithLabelValues("unchanged").Inc()
+	if ctx.Err() == nil {
+		summaryCache.Put(key, stale)
+	}
+	stale.Cached = true
+	return stale, true
+}
+
+// Summary loads the summary for topic straight from Wikipedia.
+func (goWikiClient) Summary(ctx context.Context, topic, lang string) (result PageSummary, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		result, err = summarizePage(p, lang, projectFrom(ctx))
+		if err == nil && !sameTitle(topic, p.Title) {
+			result.RedirectedFrom = topic
+		}
+		return err
+	})
+	return result, err
+}
+
+// sameTitle reports whether two titles name the same page, given that
+// Wikipedia capitalizes the first letter of every title.
+func sameTitle(a, b string) bool {
+	ra, na := utf8.DecodeRuneInString(a)
+	rb, nb := utf8.DecodeRuneInString(b)
+	return unicode.ToUpper(ra) == unicode.ToUpper(rb) && a[na:] == b[nb:]
+}
+
+// SummaryByID loads the summary for the page with the given ID straight
+// from Wikipedia.
+func (goWikiClient) SummaryByID(ctx context.Context, id int, lang string) (result PageSummary, err error) {
+	err = withPageID(ctx, id, lang, func(p *page.WikipediaPage) error {
+		result, err = summarizePage(p, lang, projectFrom(ctx))
+		return err
+	})
+	return result, err
+}
+
+// LatestRevision returns the resolved title of the page titled title and
+// the ID of its latest revision, in one cheap query that doesn't load the
+// page. Like Resolve it doesn't fall back to a search.
+func (goWikiClient) LatestRevision(ctx context.Context, title, lang string) (resolved string, revision int, err error) {
+	if title == "" {
+		return "", 0, ErrTopicRequired
+	}
+	err = withWiki(ctx, lang, func() error {
+		var res struct {
+			Query struct {
+				Pages map[string]struct {
+					Title     string  `json:"title"`
+					LastRevID int     `json:"lastrevid"`
+					Missing   *string `json:"missing"`
+					Invalid   *string `json:"invalid"`
+				} `json:"pages"`
+			} `json:"query"`
+		}
+		if err := callWikiAPI(map[string]string{
+			"prop":      "info",
+			"redirects": "1",
+			"titles":    title,
+		}, &res); err != nil {
+			return err
+		}
+		for _, pg := range res.Query.Pages {
+			if pg.Missing == nil && pg.Invalid == nil {
+				resolved, revision = pg.Title, pg.LastRevID
+				return nil
+			}
+		}
+		return fmt.Errorf("%w: %q", ErrPageNotFound, title)
+	})
+	return resolved, revision, err
+}
+
+// summarizePage builds the PageSummary for a loaded page of the lang
+// edition of project. It asks for the extract itself rather than through
+// go-wiki's GetSummary, so the same query yields the page's latest
+// revision.
+func summarizePage(p *page.WikipediaPage, lang, project string) (PageSummary, error) {
+	// go-wiki doesn't fail on disambiguation pages; it lists their
+	// candidates instead, which we surface as a typed error
+	if len(p.Disambiguation) > 0 {
+		return PageSummary{}, &DisambiguationError{Title: p.Title, Options: p.Disambiguation}
+	}
+	var res struct {
+		Query struct {
+			Pages map[string]struct {
+				Extract   string `json:"extract"`
+				LastRevID int    `json:"lastrevid"`
+			} `json:"pages"`
+		} `json:"query"`
+	}
+	if err := callWikiAPI(map[string]string{
+		"prop":        "extracts|info",
+		"explaintext": "",
+		"exintro":     "",
+		"titles":      p.Title,
+	}, &res); err != nil {
+		return PageSummary{}, err
+	}
+	result := PageSummary{Title: p.Title, URL: p.URL}
+	for _, pg := range res.Query.Pages {
+		result.Summary, result.Revision = pg.Extract, pg.LastRevID
+	}
+	if result.URL == "" {
+		result.URL = projectArticleURL(p.Title, lang, project)
+	}
+	return result, nil
+}
+
+// fetchBestMatchSummary returns the summary of the top search result for
+// query, for topics that don't name a page exactly, loaded with fetch.
+func fetchBestMatchSummary(ctx context.Context, query, lang string, fetch func(ctx context.Context, topic, lang string) (PageSummary, error)) (PageSummary, error) {
+	titles, err := wikiClient.Search(ctx, query, lang, 1)
+	if err != nil {
+		return PageSummary{}, err
+	}
+	if len(titles) == 0 {
+		return PageSummary{}, fmt.Errorf("%w: %q", ErrPageNotFound, query)
+	}
+	return fetch(ctx, titles[0], lang)
+}
+
+// articleURL builds the URL of the article titled title on the lang edition.
+func articleURL(title, lang string) string {
+	return projectArticleURL(title, lang, defaultProject)
+}
+
+// projectArticleURL is articleURL for a page of another Wikimedia project.
+func projectArticleURL(title, lang, project string) string {
+	return fmt.Sprintf("https://%s.%s.org/wiki/%s", lang, project, url.PathEscape(strings.ReplaceAll(title, " ", "_")))
+}
+
+// Search returns up to limit page titles matching query in the
+// Wikipedia edition identified by lang.
+func (goWikiClient) Search(ctx context.Context, query, lang string, limit int) (titles []string, err error) {
+	err = withWiki(ctx, lang, func() error {
+		titles, _, err = wiki.Search(query, limit, false)
+		return err
+	})
+	return titles, err
+}
+
+// Random returns up to n random article titles from the Wikipedia
+// edition identified by lang.
+func (goWikiClient) Random(ctx context.Context, lang string, n int) (titles []string, err error) {
+	err = withWiki(ctx, lang, func() error {
+		titles, err = wiki.GetRandom(n)
+		return err
+	})
+	return titles, err
+}
+
+// Resolve returns the canonical title of the page for topic, after title
+// normalization and redirects, without loading the page itself. Unlike the
+// lookups made through go-wiki it doesn't fall back to a search, so only
+// titles that really exist resolve.
+func (goWikiClient) Resolve(ctx context.Context, topic, lang string) (title string, err error) {
+	if topic == "" {
+		return "", ErrTopicRequired
+	}
+	err = withWiki(ctx, lang, func() error {
+		title, err = resolveTitle(topic)
+		return err
+	})
+	return title, err
+}
+
+// TitleResolution describes how a topic maps to a page title. Title is the
+// canonical title of the page it names, after MediaWiki's title
+// normalization (a capitalized first letter, spaces for underscores) and
+// any redirect. RedirectedFrom is the normalized topic when it was a
+// redirect, and Fragment the section the redirect points to, if any.
+type TitleResolution struct {
+	Title          string
+	RedirectedFrom string
+	Fragment       string
+}
+
+// Normalize reports how topic resolves to a page title without loading the
+// page. Like Resolve it doesn't fall back to a search.
+func (goWikiClient) Normalize(ctx context.Context, topic, lang string) (res TitleResolution, err error) {
+	if topic == "" {
+		return TitleResolution{}, ErrTopicRequired
+	}
+	err = withWiki(ctx, lang, func() error {
+		res, err = normalizeTitle(topic)
+		return err
+	})
+	return res, err
+}
+
+// resolveTitle does the work of Resolve; callers must be inside withWiki.
+func resolveTitle(topic string) (string, error) {
+	res, err := normalizeTitle(topic)
+	return res.Title, err
+}
+
+// normalizeTitle does the work of Normalize; callers must be inside
+// withWiki.
+func normalizeTitle(topic string) (TitleResolution, error) {
+	type redirect struct {
+		From       string `json:"from"`
+		ToFragment string `json:"tofragment"`
+	}
+	var res struct {
+		Query struct {
+			Redirects []redirect `json:"redirects"`
+			Pages     map[string]struct {
+				Title   string  `json:"title"`
+				Missing *string `json:"missing"`
+				Invalid *string `json:"invalid"`
+			} `json:"pages"`
+		} `json:"query"`
+	}
+	if err := callWikiAPI(map[string]string{
+		"prop":      "info",
+		"redirects": "1",
+		"titles":    topic,
+	}, &res); err != nil {
+		return TitleResolution{}, err
+	}
+	for _, pg := range res.Query.Pages {
+		if pg.Missing != nil || pg.Invalid != nil {
+			continue
+		}
+		out := TitleResolution{Title: pg.Title}
+		if n := len(res.Query.Redirects); n > 0 {
+			out.RedirectedFrom = res.Query.Redirects[0].From
+			out.Fragment = res.Query.Redirects[n-1].ToFragment
+		}
+		return out, nil
+	}
+	return TitleResolution{}, fmt.Errorf("%w: %q", ErrPageNotFound, topic)
+}
+
+// Disambiguation reports whether topic names a disambiguation page and, if
+// so, lists its candidates the way /lookup does, without loading the
+// page's content. Like Resolve it doesn't fall back to a search.
+func (goWikiClient) Disambiguation(ctx context.Context, topic, lang string) (title string, options []string, err error) {
+	if topic == "" {
+		return "", nil, ErrTopicRequired
+	}
+	err = withWiki(ctx, lang, func() error {
+		var res struct {
+			Query struct {
+				Pages map[string]struct {
+					Title     string            `json:"title"`
+					Missing   *string           `json:"missing"`
+					Invalid   *string           `json:"invalid"`
+					PageProps map[string]string `json:"pageprops"`
+				} `json:"pages"`
+			} `json:"query"`
+		}
+		if err := callWikiAPI(map[string]string{
+			"prop":      "pageprops",
+			"ppprop":    "disambiguation",
+			"redirects": "1",
+			"titles":    topic,
+		}, &res); err != nil {
+			return err
+		}
+		disambiguation := false
+		for _, pg := range res.Query.Pages {
+			if pg.Missing == nil && pg.Invalid == nil {
+				title = pg.Title
+				_, disambiguation = pg.PageProps["disambiguation"]
+			}
+		}
+		if title == "" {
+			return fmt.Errorf("%w: %q", ErrPageNotFound, topic)
+		}
+		if !disambiguation {
+			return nil
+		}
+		// go-wiki collects the candidates while loading a disambiguation
+		// page; a page listing none is still one
+		p, err := page.MakeWikipediaPage(-1, title, "", false)
+		if err != nil {
+			return err
+		}
+		options = p.Disambiguation
+		if options == nil {
+			options = []string{}
+		}
+		return nil
+	})
+	return title, options, err
+}
+
+// withPage loads the page for topic and runs fn on it while holding go-wiki,
+// so fn may call the page's lazy getters. Disambiguation pages are passed to
+// fn like any other page.
+func withPage(ctx context.Context, topic, lang string, fn func(p *page.WikipediaPage) error) error {
+	if topic == "" {
+		return ErrTopicRequired
+	}
+	return withWiki(ctx, lang, func() error {
+		p, err := wiki.GetPage(topic, -1, false, false)
+		if err != nil {
+			if isMissingPage(err) {
+				return fmt.Errorf("%w: %q", ErrPageNotFound, topic)
+			}
+			return err
+		}
+		return fn(&p)
+	})
+}
+
+// withPageID is withPage for the page with the given numeric ID.
+func withPageID(ctx context.Context, id int, lang string, fn func(p *page.WikipediaPage) error) error {
+	return withWiki(ctx, lang, func() error {
+		p, err := wiki.GetPage("", id, false, false)
+		// The API flags unknown IDs as missing but go-wiki only notices
+		// missing titles; either way the page comes back without one
+		if (err != nil && isMissingPage(err)) || (err == nil && p.Title == "") {
+			return fmt.Errorf("%w: page id %d", ErrPageNotFound, id)
+		}
+		if err != nil {
+			return err
+		}
+		return fn(&p)
+	})
+}
+
+// isMissingPage reports whether err is go-wiki's plain-text report of a
+// page that doesn't exist.
+func isMissingPage(err error) bool {
+	msg := err.Error()
+	return msg == "page not exist" || msg == "missing"
+}
+
+// Content returns the resolved title and full plain-text content of
+// the page for topic.
+func (goWikiClient) Content(ctx context.Context, topic, lang string) (title, content string, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		content, err = p.GetContent()
+		return err
+	})
+	return title, content, err
+}
+
+// Wikitext returns the resolved title of the page for topic and the raw
+// wikitext of its current revision, or only that of the named section when
+// section isn't "". go-wiki only exposes the rendered plain text, so this
+// queries the revisions prop directly.
+func (goWikiClient) Wikitext(ctx context.Context, topic, lang, section string) (title, wikitext string, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		args := map[string]string{
+			"prop":    "revisions",
+			"rvprop":  "content",
+			"rvslots": "main",
+			"titles":  p.Title,
+		}
+		if section != "" {
+			index, err := sectionIndex(p.Title, section)
+			if err != nil {
+				return err
+			}
+			args["rvsection"] = index
+		}
+		var res struct {
+			Query struct {
+				Pages map[string]struct {
+					Revisions []struct {
+						Slots struct {
+							Main struct {
+								Content string `json:"*"`
+							} `json:"main"`
+						} `json:"slots"`
+					} `json:"revisions"`
+				} `json:"pages"`
+			} `json:"query"`
+		}
+		if err := callWikiAPI(args, &res); err != nil {
+			return err
+		}
+		for _, pg := range res.Query.Pages {
+			if len(pg.Revisions) > 0 {
+				wikitext = pg.Revisions[0].Slots.Main.Content
+			}
+		}
+		return nil
+	})
+	return title, wikitext, err
+}
+
+// HTML returns the resolved title of the page for topic and its rendered
+// HTML, or only that of the named section when section isn't "". It uses
+// the parse API rather than go-wiki's GetHTML, which can't render a single
+// section and relies on the deprecated rvparse option.
+func (goWikiClient) HTML(ctx context.Context, topic, lang, section string) (title, html string, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		args := map[string]string{
+			"action":             "parse",
+			"prop":               "text",
+			"page":               p.Title,
+			"disableeditsection": "1",
+			"disabletoc":         "1",
+		}
+		if section != "" {
+			index, err := sectionIndex(p.Title, section)
+			if err != nil {
+				return err
+			}
+			args["section"] = index
+		}
+		var res struct {
+			Parse struct {
+				Text struct {
+					HTML string `json:"*"`
+				} `json:"text"`
+			} `json:"parse"`
+		}
+		if err := callWikiAPI(args, &res); err != nil {
+			return err
+		}
+		html = res.Parse.Text.HTML
+		return nil
+	})
+	return title, html, err
+}
+
+// sectionIndex returns the parse API index of the section of page titled
+// section, or a *SectionNotFoundError listing the available ones.
+func sectionIndex(page, section string) (string, error) {
+	var res struct {
+		Parse struct {
+			Sections []struct {
+				Line   string `json:"line"`
+				Anchor string `json:"anchor"`
+				Index  string `json:"index"`
+			} `json:"sections"`
+		} `json:"parse"`
+	}
+	if err := callWikiAPI(map[string]string{"action": "parse", "prop": "sections", "page": page}, &res); err != nil {
+		return "", err
+	}
+	available := make([]string, 0, len(res.Parse.Sections))
+	for _, s := range res.Parse.Sections {
+		if s.Line == section || s.Anchor == strings.ReplaceAll(section, " ", "_") {
+			return s.Index, nil
+		}
+		available = append(available, s.Line)
+	}
+	return "", &SectionNotFoundError{Section: section, Available: available}
+}
+
+// Section is one heading from a page's table of contents. Level 2 is a
+// top-level section ("== Heading =="), 3 a subsection, and so on.
+type Section struct {
+	Title string `json:"title"`
+	Level int    `json:"level"`
+}
+
+// Sections returns the resolved title and table of contents of the page
+// for topic. go-wiki's GetSectionList drops the nesting level, so this asks
+// the parse API directly.
+func (goWikiClient) Sections(ctx context.Context, topic, lang string) (title string, sections []Section, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		res, err := requestWikiAPI(map[string]string{
+			"action": "parse",
+			"prop":   "sections",
+			"page":   p.Title,
+		})
+		if err != nil {
+			return err
+		}
+
+		raw, _ := res.Parse["sections"].([]any)
+		sections = make([]Section, 0, len(raw))
+		for _, v := range raw {
+			s, _ := v.(map[string]any)
+			line, _ := s["line"].(string)
+			level, _ := s["level"].(string)
+			n, _ := strconv.Atoi(level)
+			sections = append(sections, Section{Title: line, Level: n})
+		}
+		return nil
+	})
+	return title, sections, err
+}
+
+// Section returns the resolved page title and the text of the section
+// named section, by title or anchor. A missing section yields a
+// *SectionNotFoundError.
+func (goWikiClient) Section(ctx context.Context, topic, lang, section string) (title, text string, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		texts, available, err := pageSections(p, []string{section})
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if text, ok = texts[section]; !ok {
+			return &SectionNotFoundError{Section: section, Available: available}
+		}
+		return nil
+	})
+	return title, text, err
+}
+
+// SectionTexts returns the resolved page title and the text of each of the
+// named sections, keyed by the name as given, from a single fetch of the
+// page. Names matching no section are left out of texts; available then
+// lists the section titles the page does have.
+func (goWikiClient) SectionTexts(ctx context.Context, topic, lang string, sections []string) (title string, texts map[string]string, available []string, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		texts, available, err = pageSections(p, sections)
+		return err
+	})
+	return title, texts, available, err
+}
+
+// pageSections extracts the named sections of p, keyed by the name as
+// given. A name matches a section by its title or by its anchor, the title
+// with underscores for spaces. go-wiki keeps the page's content and table of
+// contents once loaded, so the page is fetched only once however many
+// sections are asked for. available is the page's section titles.
+func pageSections(p *page.WikipediaPage, names []string) (texts map[string]string, available []string, err error) {
+	available, err = p.GetSectionList()
+	if err != nil {
+		return nil, nil, err
+	}
+	texts = make(map[string]string, len(names))
+	for _, name := range names {
+		i := slices.IndexFunc(available, func(s string) bool {
+			return s == name || strings.ReplaceAll(s, " ", "_") == name
+		})
+		if i < 0 {
+			continue
+		}
+		if texts[name], err = p.GetSection(available[i]); err != nil {
+			return nil, nil, err
+		}
+	}
+	return texts, available, nil
+}
+
+// Links returns the resolved title of the page for topic and the titles
+// of the articles it links to.
+func (goWikiClient) Links(ctx context.Context, topic, lang string) (title string, links []string, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		links, err = p.GetLink()
+		return err
+	})
+	return title, links, err
+}
+
+// References returns the resolved title of the page for topic and the
+// external URLs it links to, including its cited sources.
+func (goWikiClient) References(ctx context.Context, topic, lang string) (title string, references []string, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		references, err = p.GetReference()
+		return err
+	})
+	return title, references, err
+}
+
+// SeeAlso returns the resolved title of the page for topic, the first of
+// its sections whose heading is one of headings, compared ignoring case,
+// and the existing articles linked from that section, in the order the
+// API lists them. Without such a section, section is "" and links empty.
+func (goWikiClient) SeeAlso(ctx context.Context, topic, lang string, headings []string) (title, section string, links []string, err error) {
+	links = []string{}
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		type tocEntry struct {
+			Line  string `json:"line"`
+			Index string `json:"index"`
+		}
+		var sections struct {
+			Parse struct {
+				Sections []tocEntry `json:"sections"`
+			} `json:"parse"`
+		}
+		if err := callWikiAPI(map[string]string{"action": "parse", "prop": "sections", "page": p.Title}, &sections); err != nil {
+			return err
+		}
+		index := ""
+		for _, heading := range headings {
+			if i := slices.IndexFunc(sections.Parse.Sections, func(s tocEntry) bool {
+				return strings.EqualFold(strings.TrimSpace(s.Line), heading)
+			}); i >= 0 {
+				section, index = sections.Parse.Sections[i].Line, sections.Parse.Sections[i].Index
+				break
+			}
+		}
+		if index == "" {
+			return nil
+		}
+
+		var res struct {
+			Parse struct {
+				Links []struct {
+					NS     int     `json:"ns"`
+					Exists *string `json:"exists"`
+					Title  string  `json:"*"`
+				} `json:"links"`
+			} `json:"parse"`
+		}
+		if err := callWikiAPI(map[string]string{
+			"action":  "parse",
+			"prop":    "links",
+			"page":    p.Title,
+			"section": index,
+		}, &res); err != nil {
+			return err
+		}
+		for _, l := range res.Parse.Links {
+			if l.NS == 0 && l.Exists != nil {
+				links = append(links, l.Title)
+			}
+		}
+		return nil
+	})
+	return title, section, links, err
+}
+
+// Images returns the resolved title of the page for topic and the URLs
+// of the images it uses.
+func (goWikiClient) Images(ctx context.Context, topic, lang string) (title string, images []string, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		images, err = p.GetImagesURL()
+		return err
+	})
+	return title, images, err
+}
+
+// LeadImage returns the resolved title of the page for topic and the
+// URL of its lead image, or "" when it has none. go-wiki doesn't expose the
+// PageImages API, so this queries it directly.
+func (goWikiClient) LeadImage(ctx context.Context, topic, lang string) (title, image string, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		var res struct {
+			Query struct {
+				Pages map[string]struct {
+					Original struct {
+						Source string `json:"source"`
+					} `json:"original"`
+				} `json:"pages"`
+			} `json:"query"`
+		}
+		if err := callWikiAPI(map[string]string{
+			"prop":   "pageimages",
+			"piprop": "original",
+			"titles": p.Title,
+		}, &res); err != nil {
+			return err
+		}
+		for _, pg := range res.Query.Pages {
+			image = pg.Original.Source
+		}
+		return nil
+	})
+	return title, image, err
+}
+
+// ErrNoImage is returned for pages without a lead image.
+var ErrNoImage = errors.New("page has no lead image")
+
+// Image is a lead image rendition with its pixel dimensions.
+type Image struct {
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Thumbnail returns the resolved title of the page for topic, its lead
+// image and a rendition of that image scaled to width pixels. Wikipedia
+// never upscales, so narrow originals come back at their own size.
+func (goWikiClient) Thumbnail(ctx context.Context, topic, lang string, width int) (title string, original, thumb Image, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		type rendition struct {
+			Source string `json:"source"`
+			Width  int    `json:"width"`
+			Height int    `json:"height"`
+		}
+		var res struct {
+			Query struct {
+				Pages map[string]struct {
+					Original  *rendition `json:"original"`
+					Thumbnail *rendition `json:"thumbnail"`
+				} `json:"pages"`
+			} `json:"query"`
+		}
+		if err := callWikiAPI(map[string]string{
+			"prop":        "pageimages",
+			"piprop":      "original|thumbnail",
+			"pithumbsize": strconv.Itoa(width),
+			"titles":      p.Title,
+		}, &res); err != nil {
+			return err
+		}
+		for _, pg := range res.Query.Pages {
+			if pg.Original != nil && pg.Thumbnail != nil {
+				original = Image{URL: pg.Original.Source, Width: pg.Original.Width, Height: pg.Original.Height}
+				thumb = Image{URL: pg.Thumbnail.Source, Width: pg.Thumbnail.Width, Height: pg.Thumbnail.Height}
+				return nil
+			}
+		}
+		return ErrNoImage
+	})
+	return title, original, thumb, err
+}
+
+// ErrNoCoordinates is returned for pages that aren't geotagged.
+var ErrNoCoordinates = errors.New("page has no coordinates")
+
+// Coordinates returns the resolved title of the page for topic and its
+// primary latitude and longitude. go-wiki's GetCoordinate panics on pages
+// without coordinates, so this queries the coordinates prop directly.
+func (goWikiClient) Coordinates(ctx context.Context, topic, lang string) (title string, lat, lon float64, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		var res struct {
+			Query struct {
+				Pages map[string]struct {
+					Coordinates []struct {
+						Lat float64 `json:"lat"`
+						Lon float64 `json:"lon"`
+					} `json:"coordinates"`
+				} `json:"pages"`
+			} `json:"query"`
+		}
+		if err := callWikiAPI(map[string]string{
+			"prop":      "coordinates",
+			"coprimary": "primary",
+			"titles":    p.Title,
+		}, &res); err != nil {
+			return err
+		}
+		for _, pg := range res.Query.Pages {
+			if len(pg.Coordinates) > 0 {
+				lat, lon = pg.Coordinates[0].Lat, pg.Coordinates[0].Lon
+				return nil
+			}
+		}
+		return ErrNoCoordinates
+	})
+	return title, lat, lon, err
+}
+
+// Description returns the resolved title of the page for topic and its
+// short description, e.g. "Programming language", or "" when it has none.
+// go-wiki doesn't expose descriptions, so this queries the description prop
+// directly.
+func (goWikiClient) Description(ctx context.Context, topic, lang string) (title, description string, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		var res struct {
+			Query struct {
+				Pages map[string]struct {
+					Description string `json:"description"`
+				} `json:"pages"`
+			} `json:"query"`
+		}
+		if err := callWikiAPI(map[string]string{
+			"prop":   "description",
+			"titles": p.Title,
+		}, &res); err != nil {
+			return err
+		}
+		for _, pg := range res.Query.Pages {
+			description = pg.Description
+		}
+		return nil
+	})
+	return title, description, err
+}
+
+// NearbyPage is a geotagged article found by Nearby, with its distance in
+// metres from the searched point.
+type NearbyPage struct {
+	Title    string  `json:"title"`
+	Lat      float64 `json:"lat"`
+	Lon      float64 `json:"lon"`
+	Distance float64 `json:"distance"`
+}
+
+// Nearby returns up to limit articles within radius metres of lat/lon,
+// closest first. go-wiki's GeoSearch drops the coordinates and distances,
+// so this queries the geosearch list directly.
+func (goWikiClient) Nearby(ctx context.Context, lang string, lat, lon float64, radius, limit int) (pages []NearbyPage, err error) {
+	err = withWiki(ctx, lang, func() error {
+		var res struct {
+			Query struct {
+				GeoSearch []struct {
+					Title string  `json:"title"`
+					Lat   float64 `json:"lat"`
+					Lon   float64 `json:"lon"`
+					Dist  float64 `json:"dist"`
+				} `json:"geosearch"`
+			} `json:"query"`
+		}
+		if err := callWikiAPI(map[string]string{
+			"list":        "geosearch",
+			"gscoord":     fmt.Sprintf("%g|%g", lat, lon),
+			"gsradius":    strconv.Itoa(radius),
+			"gslimit":     strconv.Itoa(limit),
+			"gsnamespace": "0",
+		}, &res); err != nil {
+			return err
+		}
+		pages = make([]NearbyPage, 0, len(res.Query.GeoSearch))
+		for _, g := range res.Query.GeoSearch {
+			pages = append(pages, NearbyPage{Title: g.Title, Lat: g.Lat, Lon: g.Lon, Distance: g.Dist})
+		}
+		return nil
+	})
+	return pages, err
+}
+
+// RelatedPage is an article found by Related, with its short description
+// when it has one.
+type RelatedPage struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+}
+
+// Related returns the resolved title of the page for topic and up to limit
+// articles related to it, most related first. The REST API's related-pages
+// endpoint has been retired; this runs the CirrusSearch "morelike:" query
+// that replaced it, which ranks articles by shared text and links.
+func (goWikiClient) Related(ctx context.Context, topic, lang string, limit int) (title string, pages []RelatedPage, err error) {
+	if topic == "" {
+		return "", nil, ErrTopicRequired
+	}
+	err = withWiki(ctx, lang, func() error {
+		if title, err = resolveTitle(topic); err != nil {
+			return err
+		}
+		type result struct {
+			Title       string `json:"title"`
+			Index       int    `json:"index"`
+			Description string `json:"description"`
+		}
+		var res struct {
+			Query struct {
+				Pages map[string]result `json:"pages"`
+			} `json:"query"`
+		}
+		if err := callWikiAPI(map[string]string{
+			"generator":    "search",
+			"gsrsearch":    "morelike:" + title,
+			"gsrlimit":     strconv.Itoa(limit),
+			"gsrnamespace": "0",
+			"prop":         "description",
+		}, &res); err != nil {
+			return err
+		}
+		// Generator results come keyed by page ID; index is the search rank
+		ranked := slices.SortedFunc(maps.Values(res.Query.Pages), func(a, b result) int {
+			return a.Index - b.Index
+		})
+		pages = make([]RelatedPage, 0, len(ranked))
+		for _, pg := range ranked {
+			pages = append(pages, RelatedPage{Title: pg.Title, Description: pg.Description})
+		}
+		return nil
+	})
+	return title, pages, err
+}
+
+// LangLinks returns the resolved title of the page for topic and the titles
+// of the equivalent pages in other editions, keyed by language code. go-wiki
+// doesn't expose interlanguage links, so this queries the langlinks prop
+// directly.
+func (goWikiClient) LangLinks(ctx context.Context, topic, lang string) (title string, links map[string]string, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		var res struct {
+			Query struct {
+				Pages map[string]struct {
+					LangLinks []struct {
+						Lang  string `json:"lang"`
+						Title string `json:"*"`
+					} `json:"langlinks"`
+				} `json:"pages"`
+			} `json:"query"`
+		}
+		if err := callWikiAPI(map[string]string{
+			"prop":    "langlinks",
+			"lllimit": "max",
+			"titles":  p.Title,
+		}, &res); err != nil {
+			return err
+		}
+		links = make(map[string]string)
+		for _, pg := range res.Query.Pages {
+			for _, ll := range pg.LangLinks {
+				links[ll.Lang] = ll.Title
+			}
+		}
+		return nil
+	})
+	return title, links, err
+}
+
+// Categories returns the resolved title of the page for topic and the
+// names of the categories it belongs to, without the namespace prefix.
+// go-wiki's GetCategory only strips the English "Category:" prefix, so the
+// raw titles are fetched and trimmed here for every edition.
+func (goWikiClient) Categories(ctx context.Context, topic, lang string) (title string, categories []string, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		raw, err := p.ContinuedQuery(map[string]string{
+			"action":  "query",
+			"prop":    "categories",
+			"cllimit": "max",
+		})
+		if err != nil && len(raw) == 0 {
+			return err
+		}
+		categories = make([]string, 0, len(raw))
+		for _, v := range raw {
+			full, _ := v.(string)
+			_, name, found := strings.Cut(full, ":")
+			if !found {
+				name = full
+			}
+			categories = append(categories, name)
+		}
+		return nil
+	})
+	return title, categories, err
+}
+
+// CategoryMember is one page listed in a category. Type is "page",
+// "subcat" or "file".
+type CategoryMember struct {
+	Title string `json:"title"`
+	Type  string `json:"type"`
+}
+
+// CategoryMembers returns up to limit members of the category named
+// category (without its namespace prefix), starting at the continuation
+// token cont, and the token for the next batch ("" after the last). go-wiki
+// has no category member listing, so this queries the categorymembers list
+// directly. An unknown category simply has no members.
+func (goWikiClient) CategoryMembers(ctx context.Context, category, lang string, limit int, cont string) (members []CategoryMember, next string, err error) {
+	err = withWiki(ctx, lang, func() error {
+		var res struct {
+			Continue struct {
+				CMContinue string `json:"cmcontinue"`
+			} `json:"continue"`
+			Query struct {
+				CategoryMembers []CategoryMember `json:"categorymembers"`
+			} `json:"query"`
+		}
+		args := map[string]string{
+			"list":    "categorymembers",
+			"cmtitle": "Category:" + category,
+			"cmprop":  "title|type",
+			"cmlimit": strconv.Itoa(limit),
+		}
+		if cont != "" {
+			args["cmcontinue"] = cont
+		}
+		if err := callWikiAPI(args, &res); err != nil {
+			return err
+		}
+		members = res.Query.CategoryMembers
+		if members == nil {
+			members = []CategoryMember{}
+		}
+		next = res.Continue.CMContinue
+		return nil
+	})
+	return members, next, err
+}
+
+// Suggest returns Wikipedia's "did you mean" correction for query, or
+// "" when it has none.
+func (goWikiClient) Suggest(ctx context.Context, query, lang string) (suggestion string, err error) {
+	err = withWiki(ctx, lang, func() error {
+		suggestion, err = wiki.Suggest(query)
+		return err
+	})
+	return suggestion, err
+}
+
+// Revision is the metadata of one edit to a page, as listed by History.
+// User is empty when the editor's name is hidden; Size is the page's
+// length in bytes after the edit.
+type Revision struct {
+	ID        int       `json:"id"`
+	Timestamp time.Time `json:"timestamp"`
+	User      string    `json:"user"`
+	Comment   string    `json:"comment"`
+	Size      int       `json:"size"`
+	Minor     bool      `json:"minor"`
+}
+
+// RevisionLead is the lead section of a page as of one revision, in
+// wikitext, as returned by RevisionLeads.
+type RevisionLead struct {
+	ID        int
+	Timestamp time.Time
+	Wikitext  string
+}
+
+// RevisionLeads returns the resolved title of the page for topic and its
+// lead section as of each of the revisions ids, in the same order, fetched
+// in one revisions query. Unknown IDs yield a *RevisionNotFoundError and
+// revisions of other pages a *RevisionMismatchError.
+func (goWikiClient) RevisionLeads(ctx context.Context, topic, lang string, ids ...int) (title string, leads []RevisionLead, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		revids := make([]string, len(ids))
+		for i, id := range ids {
+			revids[i] = strconv.Itoa(id)
+		}
+		var res struct {
+			Query struct {
+				Pages map[string]struct {
+					PageID    int    `json:"pageid"`
+					Title     string `json:"title"`
+					Revisions []struct {
+						RevID     int       `json:"revid"`
+						Timestamp time.Time `json:"timestamp"`
+						Slots     struct {
+							Main struct {
+								Content string `json:"*"`
+							} `json:"main"`
+						} `json:"slots"`
+					} `json:"revisions"`
+				} `json:"pages"`
+			} `json:"query"`
+		}
+		if err := callWikiAPI(map[string]string{
+			"prop":      "revisions",
+			"rvprop":    "ids|timestamp|content",
+			"rvslots":   "main",
+			"rvsection": "0",
+			"revids":    strings.Join(revids, "|"),
+		}, &res); err != nil {
+			return err
+		}
+
+		found := make(map[int]RevisionLead, len(ids))
+		for _, pg := range res.Query.Pages {
+			for _, rev := range pg.Revisions {
+				if pg.PageID != p.PageID {
+					return &RevisionMismatchError{ID: rev.RevID, Page: p.Title, Title: pg.Title}
+				}
+				found[rev.RevID] = RevisionLead{ID: rev.RevID, Timestamp: rev.Timestamp, Wikitext: rev.Slots.Main.Content}
+			}
+		}
+		var missing []int
+		for _, id := range ids {
+			lead, ok := found[id]
+			if !ok {
+				missing = append(missing, id)
+			}
+			leads = append(leads, lead)
+		}
+		if len(missing) > 0 {
+			return &RevisionNotFoundError{IDs: missing}
+		}
+		return nil
+	})
+	return title, leads, err
+}
+
+// History returns the resolved title of the page for topic and the metadata
+// of its limit most recent revisions, newest first, without their content.
+func (goWikiClient) History(ctx context.Context, topic, lang string, limit int) (title string, revisions []Revision, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		var res struct {
+			Query struct {
+				Pages map[string]struct {
+					Revisions []struct {
+						RevID     int       `json:"revid"`
+						Timestamp time.Time `json:"timestamp"`
+						User      string    `json:"user"`
+						Comment   string    `json:"comment"`
+						Size      int       `json:"size"`
+						Minor     *string   `json:"minor"`
+					} `json:"revisions"`
+				} `json:"pages"`
+			} `json:"query"`
+		}
+		if err := callWikiAPI(map[string]string{
+			"prop":    "revisions",
+			"rvprop":  "ids|timestamp|user|comment|size|flags",
+			"rvlimit": strconv.Itoa(limit),
+			"titles":  p.Title,
+		}, &res); err != nil {
+			return err
+		}
+		revisions = []Revision{}
+		for _, pg := range res.Query.Pages {
+			for _, rev := range pg.Revisions {
+				revisions = append(revisions, Revision{
+					ID:        rev.RevID,
+					Timestamp: rev.Timestamp,
+					User:      rev.User,
+					Comment:   rev.Comment,
+					Size:      rev.Size,
+					Minor:     rev.Minor != nil,
+				})
+			}
+		}
+		return nil
+	})
+	return title, revisions, err
+}
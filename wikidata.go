@@ -0,0 +1,219 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"github.com/trietmn/go-wiki/page"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultWikidataAPIURL is Wikidata's action API (WIKIDATA_API_URL).
+const defaultWikidataAPIURL = "https://www.wikidata.org/w/api.php"
+
+// ErrNoEntity is returned for pages that aren't linked to a Wikidata item.
+var ErrNoEntity = errors.New("page has no Wikidata item")
+
+// maxWikidataIDs is the most item IDs wbgetentities accepts at once.
+const maxWikidataIDs = 50
+
+// maxEntityTypes caps the "instance of" values labelled, to what one
+// wbgetentities call takes.
+const maxEntityTypes = maxWikidataIDs
+
+// Entity is the Wikidata item a page is linked to: its ID, such as "Q42",
+// the page's short description, and what the item is an instance of (P31).
+type Entity struct {
+	ID          string
+	Description string
+	Types       []EntityType
+}
+
+// EntityType is one "instance of" value of an item, such as Q5 "human".
+// Label is in the requested language, or in a fallback language when the
+// item has no label in it, and empty when it has none at all.
+type EntityType struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+// Entity returns the resolved title of the page for topic and the
+// Wikidata item it is linked to, with labels in lang. The description is
+// the page's own short description, or the item's when the page has none.
+// Only an item's best "instance of" values are listed: the preferred ones
+// if any, otherwise all but the deprecated ones.
+func (goWikiClient) Entity(ctx context.Context, topic, lang string) (title string, entity Entity, err error) {
+	err = withPage(ctx, topic, lang, func(p *page.WikipediaPage) error {
+		title = p.Title
+		var res struct {
+			Query struct {
+				Pages map[string]struct {
+					Description string `json:"description"`
+					PageProps   struct {
+						WikibaseItem string `json:"wikibase_item"`
+					} `json:"pageprops"`
+				} `json:"pages"`
+			} `json:"query"`
+		}
+		if err := callWikiAPI(map[string]string{
+			"prop":   "pageprops|description",
+			"ppprop": "wikibase_item",
+			"titles": p.Title,
+		}, &res); err != nil {
+			return err
+		}
+		for _, pg := range res.Query.Pages {
+			entity.ID, entity.Description = pg.PageProps.WikibaseItem, pg.Description
+		}
+		return nil
+	})
+	if err != nil {
+		return title, Entity{}, err
+	}
+	if entity.ID == "" {
+		return title, Entity{}, ErrNoEntity
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, currentConfig().WikiTimeout)
+	defer cancel()
+	type snak struct {
+		SnakType  string `json:"snaktype"`
+		DataValue struct {
+			Value struct {
+				ID string `json:"id"`
+			} `json:"value"`
+		} `json:"datavalue"`
+	}
+	var item struct {
+		Entities map[string]struct {
+			Missing      *string `json:"missing"`
+			Descriptions map[string]struct {
+				Value string `json:"value"`
+			} `json:"descriptions"`
+			Claims struct {
+				P31 []struct {
+					MainSnak snak   `json:"mainsnak"`
+					Rank     string `json:"rank"`
+				} `json:"P31"`
+			} `json:"claims"`
+		} `json:"entities"`
+	}
+	if err := callWikidataAPI(ctx, map[string]string{
+		"action":    "wbgetentities",
+		"ids":       entity.ID,
+		"props":     "descriptions|claims",
+		"languages": lang,
+	}, &item); err != nil {
+		return title, Entity{}, err
+	}
+	found, ok := item.Entities[entity.ID]
+	if !ok || found.Missing != nil {
+		return title, Entity{}, ErrNoEntity
+	}
+	if entity.Description == "" {
+		entity.Description = found.Descriptions[lang].Value
+	}
+
+	var preferred, normal []string
+	for _, claim := range found.Claims.P31 {
+		if claim.MainSnak.SnakType != "value" || claim.MainSnak.DataValue.Value.ID == "" {
+			continue
+		}
+		switch claim.Rank {
+		case "preferred":
+			preferred = append(preferred, claim.MainSnak.DataValue.Value.ID)
+		case "normal":
+			normal = append(normal, claim.MainSnak.DataValue.Value.ID)
+		}
+	}
+	ids := normal
+	if len(preferred) > 0 {
+		ids = preferred
+	}
+	ids = ids[:min(len(ids), maxEntityTypes)]
+	entity.Types = make([]EntityType, 0, len(ids))
+	if len(ids) == 0 {
+		return title, entity, nil
+	}
+
+	var labels struct {
+		Entities map[string]struct {
+			Labels map[string]struct {
+				Value string `json:"value"`
+			} `json:"labels"`
+		} `json:"entities"`
+	}
+	if err := callWikidataAPI(ctx, map[string]string{
+		"action":           "wbgetentities",
+		"ids":              strings.Join(ids, "|"),
+		"props":            "labels",
+		"languages":        lang,
+		"languagefallback": "1",
+	}, &labels); err != nil {
+		return title, Entity{}, err
+	}
+	for _, id := range ids {
+		entity.Types = append(entity.Types, EntityType{ID: id, Label: labels.Entities[id].Labels[lang].Value})
+	}
+	return title, entity, nil
+}
+
+// Sitelinks returns the titles of the lang edition's articles about the
+// Wikidata items ids, keyed by item ID. Items without an article there map
+// to "", and items that don't exist are left out. A redirected item is
+// reported under the ID asked for.
+func (goWikiClient) Sitelinks(ctx context.Context, ids []string, lang string) (titles map[string]string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, currentConfig().WikiTimeout)
+	defer cancel()
+
+	site := strings.ReplaceAll(lang, "-", "_") + "wiki"
+	titles = make(map[string]string, len(ids))
+	for chunk := range slices.Chunk(ids, maxWikidataIDs) {
+		var res struct {
+			Entities map[string]struct {
+				Missing   *string `json:"missing"`
+				Redirects struct {
+					From string `json:"from"`
+				} `json:"redirects"`
+				Sitelinks map[string]struct {
+					Title string `json:"title"`
+				} `json:"sitelinks"`
+			} `json:"entities"`
+		}
+		if err := callWikidataAPI(ctx, map[string]string{
+			"action":     "wbgetentities",
+			"ids":        strings.Join(chunk, "|"),
+			"props":      "sitelinks",
+			"sitefilter": site,
+		}, &res); err != nil {
+			return nil, err
+		}
+		for id, item := range res.Entities {
+			if item.Missing != nil {
+				continue
+			}
+			titles[cmp.Or(item.Redirects.From, id)] = item.Sitelinks[site].Title
+		}
+	}
+	return titles, nil
+}
+
+// callWikidataAPI calls Wikidata's action API (WIKIDATA_API_URL) with args
+// and decodes the JSON response into out. Wikidata is run alongside
+// Wikipedia, so its calls share the retries, the upstream limiter and the
+// circuit breaker with Wikipedia's.
+func callWikidataAPI(ctx context.Context, args map[string]string, out any) (err error) {
+	ctx, span := tracer.Start(ctx, "wikidata "+args["action"], trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(
+		attribute.String("wikipedia.action", args["action"]),
+	))
+	defer func() { endSpan(span, err) }()
+
+	endpoint := currentConfig().WikidataAPIURL
+	return upstreamCall(ctx, func() error {
+		return doWikiRequest(ctx, endpoint, args, out)
+	})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// wikitextHandler returns the raw wikitext source of an article, or just the
+// section named by the "section" query parameter, as plain text for tools
+// that parse templates themselves.
+func wikitextHandler(w http.ResponseWriter, r *http.Request) {
+	lang, ok := requestLang(w, r)
+	if !ok {
+		return
+	}
+	section := r.URL.Query().Get("section")
+	truncate, ok := boolParam(w, r, "truncate", true)
+	if !ok {
+		return
+	}
+	topic, ok := requireTopic(w, r)
+	if !ok {
+		return
+	}
+
+	_, wikitext, err := wikiClient.Wikitext(r.Context(), topic, lang, section)
+	var missing *SectionNotFoundError
+	if errors.As(err, &missing) {
+		writeError(w, r, http.StatusNotFound, "SECTION_NOT_FOUND", fmt.Sprintf("section %q not found; available sections: %s", section, strings.Join(missing.Available, ", ")))
+		return
+	}
+	if err != nil {
+		writeUpstreamError(w, r, err, topic, "wikitext lookup")
+		return
+	}
+
+	wikitext, truncated, ok := limitContent(w, r, wikitext, truncate)
+	if !ok {
+		return
+	}
+	if truncated {
+		w.Header().Set("X-Content-Truncated", "true")
+	}
+	writeCacheable(w, r, "text/plain; charset=utf-8", []byte(wikitext))
+}
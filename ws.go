@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/coder/websocket/wsjson"
+)
+
+// WebSocket session timing. A lookup starts wsDebounce after its message
+// arrives, so a burst of keystrokes costs one upstream call; the connection
+// is pinged every wsPingInterval and dropped when the pong takes longer than
+// wsPingTimeout.
+const (
+	wsDebounce     = 150 * time.Millisecond
+	wsPingInterval = 30 * time.Second
+	wsPingTimeout  = 10 * time.Second
+	wsWriteTimeout = 10 * time.Second
+)
+
+// WSRequest is a message sent by the client on /ws. Lang defaults to
+// DEFAULT_LANG.
+type WSRequest struct {
+	Topic string `json:"topic"`
+	Lang  string `json:"lang,omitempty"`
+}
+
+// WSResponse is the message sent back for a WSRequest: the summary, the
+// candidate pages for a disambiguation page, or an error with the same code
+// the HTTP endpoints would use.
+type WSResponse struct {
+	Topic     string   `json:"topic"`
+	Lang      string   `json:"lang"`
+	Title     string   `json:"title,omitempty"`
+	Summary   string   `json:"summary,omitempty"`
+	URL       string   `json:"url,omitempty"`
+	Truncated bool     `json:"truncated,omitempty"`
+	Options   []string `json:"options,omitempty"`
+	Error     string   `json:"error,omitempty"`
+	Code      string   `json:"code,omitempty"`
+}
+
+// wsShutdown is cancelled when the server shuts down. http.Server doesn't
+// track hijacked connections, so open sessions watch it to close cleanly.
+var wsShutdown, closeWebSockets = context.WithCancel(context.Background())
+
+// wsHandler upgrades the request to a WebSocket and answers each WSRequest
+// with a WSResponse. A new message supersedes the previous one: its pending
+// or in-flight lookup is cancelled and never answered. Browsers from other
+// origins are accepted only when CORS_ALLOWED_ORIGINS allows them.
+func wsHandler(w http.ResponseWriter, r *http.Request) {
+	// The hijacked connection keeps the server's read and write deadlines,
+	// which a long-lived session would run into
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(time.Time{})
+	rc.SetWriteDeadline(time.Time{})
+
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{OriginPatterns: wsOriginPatterns(currentConfig().CORSOrigins)})
+	if err != nil {
+		// Accept has already answered the request
+		logger.Debug("websocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(currentConfig().MaxBodyBytes)
+
+	// The request context can't be relied on after the upgrade; keep its
+	// values (request ID, trace) but not its cancellation
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	defer cancel()
+	stop := context.AfterFunc(wsShutdown, func() {
+		conn.Close(websocket.StatusGoingAway, "server shutting down")
+	})
+	defer stop()
+	go wsKeepalive(ctx, conn)
+
+	s := &wsSession{conn: conn}
+	for {
+		_, data, err := conn.Read(ctx)
+		if err != nil {
+			if status := websocket.CloseStatus(err); status != websocket.StatusNormalClosure && status != websocket.StatusGoingAway {
+				logger.Debug("websocket session ended", "error", err)
+			}
+			return
+		}
+		var req WSRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			s.reply(ctx, 0, WSResponse{Error: "message must be a JSON object with a topic", Code: "INVALID_BODY"})
+			continue
+		}
+		s.start(ctx, req)
+	}
+}
+
+// wsOriginPatterns converts CORS_ALLOWED_ORIGINS entries, which are full
+// origins, to the host patterns websocket.Accept matches.
+func wsOriginPatterns(origins []string) []string {
+	var patterns []string
+	for _, o := range origins {
+		if o == "*" {
+			return []string{"*"}
+		}
+		if u, err := url.Parse(o); err == nil && u.Host != "" {
+			patterns = append(patterns, u.Host)
+		}
+	}
+	return patterns
+}
+
+// wsKeepalive pings the client until ctx ends, closing the connection when
+// a pong doesn't arrive in time.
+func wsKeepalive(ctx context.Context, conn *websocket.Conn) {
+	t := time.NewTicker(wsPingInterval)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+		pingCtx, cancel := context.WithTimeout(ctx, wsPingTimeout)
+		err := conn.Ping(pingCtx)
+		cancel()
+		if err != nil {
+			logger.Debug("websocket ping failed", "error", err)
+			conn.CloseNow()
+			return
+		}
+	}
+}
+
+// wsSession tracks the latest lookup of one connection. gen numbers the
+// requests, so an answer is only sent if no newer request arrived meanwhile.
+type wsSession struct {
+	conn   *websocket.Conn
+	mu     sync.Mutex
+	gen    uint64
+	cancel context.CancelFunc
+}
+
+// start cancels the previous lookup and runs req after wsDebounce.
+func (s *wsSession) start(ctx context.Context, req WSRequest) {
+	s.mu.Lock()
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.gen++
+	gen := s.gen
+	lookupCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	go func() {
+		defer cancel()
+		select {
+		case <-time.After(wsDebounce):
+		case <-lookupCtx.Done():
+			return
+		}
+		s.reply(ctx, gen, wsLookup(lookupCtx, req))
+	}()
+}
+
+// reply writes resp unless a request newer than gen has arrived. gen 0
+// always writes.
+func (s *wsSession) reply(ctx context.Context, gen uint64, resp WSResponse) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if gen != 0 && gen != s.gen {
+		return
+	}
+	writeCtx, cancel := context.WithTimeout(ctx, wsWriteTimeout)
+	defer cancel()
+	if err := wsjson.Write(writeCtx, s.conn, resp); err != nil {
+		logger.Debug("websocket write failed", "error", err)
+	}
+}
+
+// wsLookup fetches the summary for one WSRequest, applying the same checks
+// and limits as /lookup.
+func wsLookup(ctx context.Context, req WSRequest) WSResponse {
+	resp := WSResponse{Topic: normalizeTopic(req.Topic), Lang: req.Lang}
+	if resp.Lang == "" {
+		resp.Lang = defaultLang
+	}
+	if !isSupportedLanguage(resp.Lang) {
+		resp.Error, resp.Code = fmt.Sprintf("unsupported language code %q", resp.Lang), "UNSUPPORTED_LANGUAGE"
+		return resp
+	}
+
+	var err error
+	switch {
+	case resp.Topic == "":
+		err = ErrTopicRequired
+	case !topicAllowed(resp.Topic):
+		err = ErrTopicBlocked
+	}
+	var result PageSummary
+	if err == nil {
+		result, err = fetchWikipediaSummary(ctx, resp.Topic, resp.Lang)
+	}
+	var disambig *DisambiguationError
+	if errors.As(err, &disambig) {
+		resp.Title, resp.Options = disambig.Title, disambig.Options
+		return resp
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			logUpstreamError(err, resp.Topic, "websocket lookup")
+		}
+		_, resp.Code, resp.Error = classifyUpstreamError(err, resp.Topic, "lookup")
+		return resp
+	}
+	if result.Summary == "" {
+		resp.Error, resp.Code = fmt.Sprintf("no summary available for %q", result.Title), "NO_SUMMARY"
+		return resp
+	}
+
+	resp.Title, resp.Summary, resp.URL = result.Title, result.Summary, result.URL
+	if limit := currentConfig().MaxSummaryBytes; limit > 0 {
+		resp.Summary, resp.Truncated = truncateBytes(resp.Summary, limit)
+	}
+	return resp
+}